@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Acknowledgement records that someone has picked up a site's alert. It
+// stops reminders and is cleared when the site's status changes.
+type Acknowledgement struct {
+	Status string    `json:"status"` // the status that was acknowledged
+	By     string    `json:"by"`
+	Note   string    `json:"note,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// acknowledgeHandler acknowledges a site's current alert, or removes the
+// acknowledgement when the action is "unacknowledge"
+func acknowledgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	url := r.FormValue("url")
+	if url == "" {
+		http.Error(w, "Missing site URL", http.StatusBadRequest)
+		return
+	}
+
+	remove := r.FormValue("action") == "unacknowledge"
+	by := strings.TrimSpace(r.FormValue("by"))
+	if by == "" {
+		// A proxy in front of the dashboard may have authenticated the user
+		by, _, _ = r.BasicAuth()
+	}
+	if by == "" && !remove {
+		http.Error(w, "Enter who is acknowledging the alert", http.StatusBadRequest)
+		return
+	}
+
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		LogError("Error loading notification state for acknowledgement: %v", err)
+		http.Error(w, "Error loading notification state", http.StatusInternalServerError)
+		return
+	}
+
+	history, exists := state.NotificationHistory[url]
+	if remove {
+		history.Acknowledgement = nil
+		LogInfo("Acknowledgement removed for %s", url)
+	} else {
+		if !exists || !isAlertStatus(history.LastStatus) {
+			http.Error(w, "Site has no alert to acknowledge", http.StatusConflict)
+			return
+		}
+		history.Acknowledgement = &Acknowledgement{
+			Status: history.LastStatus,
+			By:     by,
+			Note:   strings.TrimSpace(r.FormValue("note")),
+			At:     time.Now(),
+		}
+		LogInfo("%s alert for %s acknowledged by %s", history.LastStatus, url, by)
+	}
+	state.NotificationHistory[url] = history
+
+	err = saveNotificationState(state)
+	if err != nil {
+		LogError("Error saving notification state for acknowledgement: %v", err)
+		http.Error(w, "Error saving notification state", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/results", http.StatusSeeOther)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postAcknowledge(t *testing.T, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/results/acknowledge", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	acknowledgeHandler(w, r)
+	return w
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"critical": true, "warning": true}}
+	useFakeNotifiers(t, fake)
+
+	start := time.Now().Add(-48 * time.Hour)
+	err := saveNotificationState(NotificationState{
+		NotificationHistory: map[string]NotificationHistory{
+			"critical.example.com": {LastStatus: "critical", LastScan: start, LastNotified: start},
+			"fine.example.com":     {LastStatus: "normal", LastScan: start},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+
+	if w := postAcknowledge(t, url.Values{"url": {"fine.example.com"}, "by": {"Sam"}}); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a site without an alert, got %d", w.Code)
+	}
+	if w := postAcknowledge(t, url.Values{"url": {"critical.example.com"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", w.Code)
+	}
+	w := postAcknowledge(t, url.Values{"url": {"critical.example.com"}, "by": {" Sam "}, "note": {"Renewal ticket OPS-12"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected a redirect, got %d: %s", w.Code, w.Body.String())
+	}
+
+	state, _ := loadNotificationState()
+	ack := state.NotificationHistory["critical.example.com"].Acknowledgement
+	if ack == nil || ack.By != "Sam" || ack.Note != "Renewal ticket OPS-12" || ack.Status != "critical" {
+		t.Fatalf("Unexpected acknowledgement %+v", ack)
+	}
+
+	// The results page shows who picked it up
+	setupMinimalSettingsFile(t)
+	if err := saveSites([]Site{{URL: "critical.example.com", Enabled: true}}); err != nil {
+		t.Fatalf("Failed to save sites: %v", err)
+	}
+	results := ScanResults{LastScan: time.Now(), Results: []CertResult{{URL: "critical.example.com", Name: "Critical", DaysLeft: 3, LastCheck: time.Now()}}}
+	writeTestResults(t, results)
+	page := httptest.NewRecorder()
+	resultsHandler(page, httptest.NewRequest("GET", "/results", nil))
+	if !strings.Contains(page.Body.String(), "Acknowledged by Sam") {
+		t.Error("Expected the results page to show the acknowledgement")
+	}
+
+	// No reminders while acknowledged
+	var settings Settings
+	settings.Reminders.CriticalHours = 24
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	if err := processReminders(results, settings, time.Now()); err != nil {
+		t.Fatalf("processReminders returned error: %v", err)
+	}
+	if len(fake.received) != 0 {
+		t.Errorf("Expected no reminders for an acknowledged alert, got %+v", fake.received)
+	}
+
+	// A status change clears it
+	results.Results[0].DaysLeft = 20
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+	state, _ = loadNotificationState()
+	if state.NotificationHistory["critical.example.com"].Acknowledgement != nil {
+		t.Error("Expected the acknowledgement to be cleared when the status changed")
+	}
+}
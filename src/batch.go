@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NotificationBatch is the notifications of one severity from one scan, sent
+// to a channel as a single message
+type NotificationBatch struct {
+	Severity      string         `json:"severity"`
+	Route         string         `json:"route,omitempty"` // route whose recipients the batch goes to, if it overrides the channel's
+	Notifications []Notification `json:"notifications"`
+}
+
+// batchNotifier is implemented by channels that can list several sites in one
+// message. Channels without it are sent each notification separately.
+type batchNotifier interface {
+	SendBatch(batch NotificationBatch, settings Settings) (int, error)
+}
+
+// batchTitles start the title of a batched message, before the site count
+var batchTitles = map[string]string{
+	"warning":  "SSL Certificate Warning",
+	"critical": "SSL Certificate Critical",
+	"error":    "SSL Certificate Check Failing",
+	"change":   "SSL Certificate Changed",
+	"resolved": "SSL Certificate Resolved",
+}
+
+// batchTitle is the subject or title of a batched message, e.g.
+// "SSL Certificate Warning: 30 sites"
+func batchTitle(batch NotificationBatch) string {
+	return fmt.Sprintf("%s: %d sites", batchTitles[batch.Severity], len(batch.Notifications))
+}
+
+// batchLine describes one site of a batch on a single line
+func batchLine(notification Notification) string {
+	result := notification.Result
+	if change := notification.Change; change != nil {
+		return fmt.Sprintf("%s (%s): %s, now issued by %s and expiring %s",
+			change.Name, change.URL, describeCertChange(*change), change.NewIssuer, change.NewExpiry.Format("2006-01-02"))
+	}
+	return digestLine(result)
+}
+
+// batchText lists the sites of a batch one per line, for the text channels
+func batchText(batch NotificationBatch) string {
+	lines := make([]string, 0, len(batch.Notifications))
+	for _, notification := range batch.Notifications {
+		lines = append(lines, "- "+batchLine(notification))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// batchEmailBody renders a batch as an HTML table of its sites
+func batchEmailBody(batch NotificationBatch, settings Settings) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "<h2>%s</h2>\n<table>\n", html.EscapeString(batchTitle(batch)))
+	body.WriteString("<tr><th align=\"left\">Site</th><th align=\"left\">URL</th><th align=\"left\">Days left</th><th align=\"left\">Expiry date</th><th align=\"left\">Details</th></tr>\n")
+	for _, notification := range batch.Notifications {
+		result := notification.Result
+		daysLeft, expiry, details := "", "", result.Error
+		if change := notification.Change; change != nil {
+			expiry = change.NewExpiry.Format("2006-01-02")
+			details = fmt.Sprintf("%s, issuer %s → %s", describeCertChange(*change), change.OldIssuer, change.NewIssuer)
+		} else if result.Error == "" {
+			daysLeft = fmt.Sprintf("%d", result.DaysLeft)
+			expiry = result.ExpiryDate.Format("2006-01-02")
+		}
+		fmt.Fprintf(&body, "<tr><td>%s</td><td><a href=\"%s\">%s</a></td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(result.Name), html.EscapeString(siteDashboardURL(settings, result.URL)), html.EscapeString(result.URL),
+			daysLeft, expiry, html.EscapeString(details))
+	}
+	body.WriteString("</table>\n")
+
+	fmt.Fprintf(&body, "<p><a href=\"%s/results\">Open the dashboard</a></p>\n", html.EscapeString(dashboardURL(settings)))
+	return body.String()
+}
+
+// batchJSON is the body webhooks receive for a batch
+func batchJSON(batch NotificationBatch) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Event string `json:"event"`
+		NotificationBatch
+	}{"batch", batch}, "", "  ")
+}
+
+// batchNotifications groups a scan's notifications into batches for one
+// channel, by severity and by the route recipients they go to. Sites are
+// listed soonest expiry first.
+func batchNotifications(notifier Notifier, notifications []Notification, settings Settings) []NotificationBatch {
+	var batches []NotificationBatch
+	index := make(map[string]int)
+	for _, notification := range notifications {
+		route, routed := findRoute(settings, notification.Result.Route)
+		if _, wanted := channelSettingsFor(notifier, notification, route, routed, settings); !wanted {
+			continue
+		}
+
+		batch := NotificationBatch{Severity: notification.Severity}
+		if routed && route.Recipients[notifier.Name()] != "" {
+			batch.Route = route.Name
+		}
+		key := batch.Severity + "\x00" + batch.Route
+		i, exists := index[key]
+		if !exists {
+			i = len(batches)
+			index[key] = i
+			batches = append(batches, batch)
+		}
+		batches[i].Notifications = append(batches[i].Notifications, notification)
+	}
+
+	for _, batch := range batches {
+		sites := batch.Notifications
+		sort.SliceStable(sites, func(i, j int) bool {
+			if sites[i].Result.DaysLeft != sites[j].Result.DaysLeft {
+				return sites[i].Result.DaysLeft < sites[j].Result.DaysLeft
+			}
+			return sites[i].Result.Name < sites[j].Result.Name
+		})
+	}
+	return batches
+}
+
+// batchSettings is the settings a batch is sent with, pointing the channel at
+// the batch's route recipients if it has them
+func batchSettings(notifier Notifier, batch NotificationBatch, settings Settings) Settings {
+	if batch.Route == "" {
+		return settings
+	}
+	route, routed := findRoute(settings, batch.Route)
+	if !routed {
+		return settings
+	}
+	return routedSettings(route, notifier, settings)
+}
+
+// deliverBatch sends a batch through one channel and records the outcome for
+// every site in it, like deliverNotification
+func deliverBatch(notifier Notifier, batch NotificationBatch, settings Settings, attempt int) error {
+	sender := notifier.(batchNotifier)
+	LogInfo("Sending %s %s notification for %d sites", notifier.Label(), batch.Severity, len(batch.Notifications))
+
+	status, err := sender.SendBatch(batch, settings)
+	recordNotificationSend(notifier.Name(), err)
+	for _, notification := range batch.Notifications {
+		record := deliveryRecordFor(notifier, notification, settings, attempt, status, err)
+		record.Batch = len(batch.Notifications)
+		recordDelivery(record)
+	}
+	if err != nil {
+		LogError("Error sending %s %s notification for %d sites: %v", notifier.Label(), batch.Severity, len(batch.Notifications), err)
+		return err
+	}
+
+	LogInfo("Successfully sent %s %s notification for %d sites", notifier.Label(), batch.Severity, len(batch.Notifications))
+	return nil
+}
+
+// notifyBatched sends a scan's notifications as one message per channel and
+// severity, listing every site the channel would otherwise have been sent
+// separately. A batch of one site is sent as a normal notification, so it
+// keeps its template and threading. Returns how many sends succeeded and the
+// message references of single notifications, by site.
+func notifyBatched(notifications []Notification, settings Settings) (int, map[string]map[string]string) {
+	sent := 0
+	references := make(map[string]map[string]string)
+	for _, notifier := range notifiers {
+		_, canBatch := notifier.(batchNotifier)
+		for _, batch := range batchNotifications(notifier, notifications, settings) {
+			channelSettings := batchSettings(notifier, batch, settings)
+
+			if canBatch && len(batch.Notifications) > 1 {
+				err := deliverBatch(notifier, batch, channelSettings, 1)
+				if err != nil {
+					queueBatchDelivery(notifier, batch, err, time.Now())
+					continue
+				}
+				sent++
+				continue
+			}
+
+			for _, notification := range batch.Notifications {
+				reference, err := deliverNotification(notifier, notification, channelSettings, 1)
+				if err != nil {
+					queueDelivery(notifier, notification, err, time.Now())
+					continue
+				}
+				sent++
+				if reference != "" {
+					url := notification.Result.URL
+					if references[url] == nil {
+						references[url] = make(map[string]string)
+					}
+					references[url][notifier.Name()] = reference
+				}
+			}
+		}
+	}
+	return sent, references
+}
+
+// scanNotifier sends the notifications from one scan. When batching is on they
+// are collected and sent together by flush, otherwise straight away. Held
+// notifications are queued one by one either way, and released separately.
+type scanNotifier struct {
+	state    *NotificationState
+	holds    notificationHolds
+	settings Settings
+	pending  []Notification
+}
+
+func newScanNotifier(state *NotificationState, holds notificationHolds, settings Settings) *scanNotifier {
+	return &scanNotifier{state: state, holds: holds, settings: settings}
+}
+
+// notify sends or holds a notification, or keeps it for the batch
+func (s *scanNotifier) notify(notification Notification) (int, map[string]string) {
+	if s.settings.BatchNotifications && s.holds.reason(notification) == "" {
+		s.pending = append(s.pending, notification)
+		return 0, nil
+	}
+	return notifyOrHold(s.state, s.holds, notification, s.settings)
+}
+
+// flush sends the batched notifications, keeping the references of alerts
+// that were sent on their own in the sites' history
+func (s *scanNotifier) flush() int {
+	if len(s.pending) == 0 {
+		return 0
+	}
+	LogInfo("Sending %d notifications in batches", len(s.pending))
+	sent, references := notifyBatched(s.pending, s.settings)
+	s.pending = nil
+
+	for url, siteReferences := range references {
+		history, exists := s.state.NotificationHistory[url]
+		if exists && isAlertStatus(history.LastStatus) {
+			history.AlertReferences = mergeAlertReferences(history.AlertReferences, siteReferences)
+			s.state.NotificationHistory[url] = history
+		}
+	}
+	return sent
+}
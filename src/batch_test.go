@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchFakeNotifier records the batches it was asked to send
+type batchFakeNotifier struct {
+	*fakeNotifier
+	batches []NotificationBatch
+}
+
+func (f *batchFakeNotifier) SendBatch(batch NotificationBatch, settings Settings) (int, error) {
+	f.batches = append(f.batches, batch)
+	return 0, f.sendErr
+}
+
+func batchSites(batch NotificationBatch) string {
+	var urls []string
+	for _, notification := range batch.Notifications {
+		urls = append(urls, notification.Result.URL)
+	}
+	return strings.Join(urls, " ")
+}
+
+func TestProcessNotificationsBatched(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	batching := &batchFakeNotifier{fakeNotifier: &fakeNotifier{name: "batching", enabled: map[string]bool{"warning": true, "critical": true}}}
+	single := &fakeNotifier{name: "single", enabled: map[string]bool{"warning": true, "critical": true}}
+	useFakeNotifiers(t, single)
+	registerNotifier(batching)
+
+	var settings Settings
+	settings.BatchNotifications = true
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	results := ScanResults{LastScan: time.Now(), Results: []CertResult{
+		{URL: "b.example.com", Name: "B", DaysLeft: 20},
+		{URL: "c.example.com", Name: "C", DaysLeft: 12},
+		{URL: "a.example.com", Name: "A", DaysLeft: 20},
+		{URL: "down.example.com", Name: "Down", DaysLeft: 3},
+	}}
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+
+	if len(batching.batches) != 1 {
+		t.Fatalf("Expected one batch, got %d", len(batching.batches))
+	}
+	if batch := batching.batches[0]; batch.Severity != "warning" || batchSites(batch) != "c.example.com a.example.com b.example.com" {
+		t.Errorf("Expected the warnings batched soonest expiry first, got %s: %s", batch.Severity, batchSites(batch))
+	}
+	// A severity with one site is sent normally
+	if len(batching.received) != 1 || batching.received[0].Result.URL != "down.example.com" {
+		t.Errorf("Expected the lone critical alert sent on its own, got %+v", batching.received)
+	}
+	// Channels that can't batch still get every site
+	if len(single.received) != 4 {
+		t.Errorf("Expected 4 separate sends on the channel without batching, got %d", len(single.received))
+	}
+
+	records, _ := loadDeliveries()
+	batched := 0
+	for _, record := range records {
+		if record.Channel == "batching" && record.Batch == 3 {
+			batched++
+		}
+	}
+	if batched != 3 {
+		t.Errorf("Expected a delivery logged for each site in the batch, got %d", batched)
+	}
+}
+
+func TestBatchNotificationsByRouteRecipients(t *testing.T) {
+	batching := &batchFakeNotifier{fakeNotifier: &fakeNotifier{name: "batching", enabled: map[string]bool{"warning": true}}}
+
+	var settings Settings
+	settings.Routes = []NotificationRoute{
+		{Name: "marketing", Channels: []string{"batching"}, Recipients: map[string]string{"batching": "marketing@example.com"}},
+		{Name: "platform", Channels: []string{"batching"}},
+		{Name: "silent", Channels: []string{"other"}},
+	}
+
+	warning := func(url, route string) Notification {
+		return Notification{Severity: "warning", Result: CertResult{URL: url, Route: route, DaysLeft: 20}}
+	}
+	batches := batchNotifications(batching, []Notification{
+		warning("campaign.example.com", "marketing"),
+		warning("api.example.com", "platform"),
+		warning("www.example.com", ""),
+		warning("blog.example.com", "silent"),
+		warning("promo.example.com", "marketing"),
+	}, settings)
+
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(batches))
+	}
+	// Routes without their own recipients share the channel's batch
+	if batches[0].Route != "marketing" || batchSites(batches[0]) != "campaign.example.com promo.example.com" {
+		t.Errorf("Unexpected route batch: %q %s", batches[0].Route, batchSites(batches[0]))
+	}
+	if batches[1].Route != "" || batchSites(batches[1]) != "api.example.com www.example.com" {
+		t.Errorf("Unexpected channel batch: %q %s", batches[1].Route, batchSites(batches[1]))
+	}
+}
+
+func TestFailedBatchIsRetried(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	batching := &batchFakeNotifier{fakeNotifier: &fakeNotifier{name: "batching", enabled: map[string]bool{"error": true}, sendErr: errors.New("down")}}
+	useFakeNotifiers(t)
+	registerNotifier(batching)
+
+	failing := func(url string) Notification {
+		return Notification{Severity: "error", Result: CertResult{URL: url, Error: "connection refused"}}
+	}
+	sent, _ := notifyBatched([]Notification{failing("a.example.com"), failing("b.example.com")}, Settings{})
+	if sent != 0 {
+		t.Errorf("Expected nothing sent, got %d", sent)
+	}
+
+	entries, err := loadOutbox()
+	if err != nil {
+		t.Fatalf("Error loading outbox: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Batch == nil || len(entries[0].Batch.Notifications) != 2 {
+		t.Fatalf("Expected the batch queued as one entry, got %+v", entries)
+	}
+
+	batching.sendErr = nil
+	if err := processOutbox(Settings{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(batching.batches) != 2 || batchSites(batching.batches[1]) != "a.example.com b.example.com" {
+		t.Errorf("Expected the batch resent whole, got %d batches", len(batching.batches))
+	}
+	if len(batching.received) != 0 {
+		t.Errorf("Expected no single sends, got %d", len(batching.received))
+	}
+	if entries, _ := loadOutbox(); len(entries) != 0 {
+		t.Errorf("Expected the outbox to be empty after the retry, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Kinds of certificate change
+const (
+	changeRenewed       = "renewed"        // same issuer and key, later expiry
+	changeRekeyed       = "rekeyed"        // same issuer, new key
+	changeIssuerChanged = "issuer_changed" // issued by a different CA
+	changeReplaced      = "replaced"       // same issuer, but the expiry didn't move forward
+)
+
+// SeenCertificate is the leaf certificate last seen for a site
+type SeenCertificate struct {
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	PublicKeySHA256   string    `json:"public_key_sha256,omitempty"`
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	SeenAt            time.Time `json:"seen_at"`
+	// Fingerprints of every leaf the site's nodes have served since the last
+	// change, so nodes serving different certificates aren't a change
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// knownFingerprints is every leaf fingerprint the site has served
+func (c SeenCertificate) knownFingerprints() map[string]bool {
+	known := make(map[string]bool, len(c.Fingerprints)+1)
+	if c.FingerprintSHA256 != "" {
+		known[c.FingerprintSHA256] = true
+	}
+	for _, fingerprint := range c.Fingerprints {
+		known[fingerprint] = true
+	}
+	return known
+}
+
+type CertChange struct {
+	URL            string    `json:"url"`
+	Name           string    `json:"name"`
+	DetectedAt     time.Time `json:"detected_at"`
+	Kind           string    `json:"kind"`
+	Unexpected     bool      `json:"unexpected"`
+	OldFingerprint string    `json:"old_fingerprint"`
+	NewFingerprint string    `json:"new_fingerprint"`
+	OldExpiry      time.Time `json:"old_expiry"`
+	NewExpiry      time.Time `json:"new_expiry"`
+	OldIssuer      string    `json:"old_issuer"`
+	NewIssuer      string    `json:"new_issuer"`
+}
+
+type CertificateState struct {
+	Certificates map[string]SeenCertificate `json:"certificates"` // keyed by site URL
+	Changes      []CertChange               `json:"changes"`
+}
+
+func getCertificateStateFilePath() string {
+	return filepath.Join(dataDirPath, "certificates.json")
+}
+
+func loadCertificateState() (CertificateState, error) {
+	var state CertificateState
+	state.Certificates = make(map[string]SeenCertificate)
+	filePath := getCertificateStateFilePath()
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		// No certificates seen yet
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, err
+	}
+
+	err = json.Unmarshal(data, &state)
+	if state.Certificates == nil {
+		state.Certificates = make(map[string]SeenCertificate)
+	}
+	return state, err
+}
+
+func saveCertificateState(state CertificateState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getCertificateStateFilePath(), data, 0644)
+}
+
+func seenCertificate(certificate CertDetails, seenAt time.Time) SeenCertificate {
+	return SeenCertificate{
+		FingerprintSHA256: certificate.FingerprintSHA256,
+		PublicKeySHA256:   certificate.PublicKeySHA256,
+		Subject:           certificate.Subject,
+		Issuer:            certificate.Issuer,
+		ExpiryDate:        certificate.NotAfter,
+		SeenAt:            seenAt,
+	}
+}
+
+// servedCertificates returns each different leaf certificate the site's
+// nodes served, the one the result reports first
+func servedCertificates(result CertResult) []SeenCertificate {
+	served := []SeenCertificate{seenCertificate(*result.Certificate, result.LastCheck)}
+	seen := map[string]bool{result.Certificate.FingerprintSHA256: true}
+	for _, address := range result.Addresses {
+		if address.Certificate == nil || seen[address.Certificate.FingerprintSHA256] {
+			continue
+		}
+		seen[address.Certificate.FingerprintSHA256] = true
+		served = append(served, seenCertificate(*address.Certificate, result.LastCheck))
+	}
+	return served
+}
+
+// addFingerprints appends the fingerprints of served certificates that aren't
+// in the list yet
+func addFingerprints(fingerprints []string, served []SeenCertificate) []string {
+	known := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		known[fingerprint] = true
+	}
+	for _, certificate := range served {
+		if !known[certificate.FingerprintSHA256] {
+			known[certificate.FingerprintSHA256] = true
+			fingerprints = append(fingerprints, certificate.FingerprintSHA256)
+		}
+	}
+	return fingerprints
+}
+
+// classifyCertChange works out what kind of change took place and whether it
+// looks like routine renewal. Anything that isn't a like-for-like renewal from
+// the same CA with a later expiry is treated as unexpected.
+func classifyCertChange(old SeenCertificate, new SeenCertificate) (string, bool) {
+	switch {
+	case old.Issuer != new.Issuer:
+		return changeIssuerChanged, true
+	case !new.ExpiryDate.After(old.ExpiryDate):
+		return changeReplaced, true
+	case old.Subject != new.Subject:
+		return changeReplaced, true
+	case old.PublicKeySHA256 != "" && old.PublicKeySHA256 != new.PublicKeySHA256:
+		return changeRekeyed, false
+	default:
+		return changeRenewed, false
+	}
+}
+
+// detectCertificateChanges compares the leaf certificates each site's nodes
+// served with the ones seen before, records any changes and returns them.
+// Recorded changes are kept for retentionDays.
+func detectCertificateChanges(results ScanResults, retentionDays int) ([]CertChange, error) {
+	state, err := loadCertificateState()
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate state: %w", err)
+	}
+
+	var changes []CertChange
+
+	for _, result := range results.Results {
+		if result.Error != "" || result.Certificate == nil {
+			continue
+		}
+
+		served := servedCertificates(result)
+		previous, exists := state.Certificates[result.URL]
+		known := previous.knownFingerprints()
+		var current *SeenCertificate
+		for i := range served {
+			if !known[served[i].FingerprintSHA256] {
+				current = &served[i]
+				break
+			}
+		}
+
+		seen := served[0]
+		if !exists || current == nil {
+			// Keep earlier fingerprints too, a scan may not reach every node
+			seen.Fingerprints = addFingerprints(previous.Fingerprints, served)
+			state.Certificates[result.URL] = seen
+			continue
+		}
+		seen.Fingerprints = addFingerprints(nil, served)
+		state.Certificates[result.URL] = seen
+
+		kind, unexpected := classifyCertChange(previous, *current)
+		change := CertChange{
+			URL:            result.URL,
+			Name:           result.Name,
+			DetectedAt:     results.LastScan,
+			Kind:           kind,
+			Unexpected:     unexpected,
+			OldFingerprint: previous.FingerprintSHA256,
+			NewFingerprint: current.FingerprintSHA256,
+			OldExpiry:      previous.ExpiryDate,
+			NewExpiry:      current.ExpiryDate,
+			OldIssuer:      previous.Issuer,
+			NewIssuer:      current.Issuer,
+		}
+		changes = append(changes, change)
+
+		LogInfo("Certificate for %s %s (expiry %s -> %s, unexpected: %v)", result.URL, kind,
+			previous.ExpiryDate.Format("2006-01-02"), current.ExpiryDate.Format("2006-01-02"), unexpected)
+	}
+
+	state.Changes = append(state.Changes, changes...)
+
+	cutoff := results.LastScan.AddDate(0, 0, -retentionDays)
+	kept := state.Changes[:0]
+	for _, change := range state.Changes {
+		if !change.DetectedAt.Before(cutoff) {
+			kept = append(kept, change)
+		}
+	}
+	state.Changes = kept
+
+	err = saveCertificateState(state)
+	if err != nil {
+		return changes, fmt.Errorf("error saving certificate state: %w", err)
+	}
+
+	return changes, nil
+}
+
+// changesForSite returns the recorded changes for one site, newest first
+func changesForSite(state CertificateState, url string) []CertChange {
+	var siteChanges []CertChange
+	for i := len(state.Changes) - 1; i >= 0; i-- {
+		if state.Changes[i].URL == url {
+			siteChanges = append(siteChanges, state.Changes[i])
+		}
+	}
+	return siteChanges
+}
+
+// describeCertChange is a one line summary of a certificate change
+func describeCertChange(change CertChange) string {
+	switch change.Kind {
+	case changeIssuerChanged:
+		return "is now issued by a different CA"
+	case changeRekeyed:
+		return "was re-keyed"
+	case changeRenewed:
+		return "was renewed"
+	default:
+		return "was replaced with a certificate that does not expire later"
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyCertChange(t *testing.T) {
+	expiry := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := SeenCertificate{
+		FingerprintSHA256: "AA",
+		PublicKeySHA256:   "K1",
+		Subject:           "CN=example.com",
+		Issuer:            "CN=R11,O=Let's Encrypt,C=US",
+		ExpiryDate:        expiry,
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(c *SeenCertificate)
+		kind       string
+		unexpected bool
+	}{
+		{"renewed", func(c *SeenCertificate) { c.ExpiryDate = expiry.AddDate(0, 3, 0) }, changeRenewed, false},
+		{"rekeyed", func(c *SeenCertificate) {
+			c.ExpiryDate = expiry.AddDate(0, 3, 0)
+			c.PublicKeySHA256 = "K2"
+		}, changeRekeyed, false},
+		{"issuer changed", func(c *SeenCertificate) {
+			c.ExpiryDate = expiry.AddDate(1, 0, 0)
+			c.Issuer = "CN=Other CA"
+		}, changeIssuerChanged, true},
+		{"earlier expiry", func(c *SeenCertificate) { c.ExpiryDate = expiry.AddDate(0, -1, 0) }, changeReplaced, true},
+		{"subject changed", func(c *SeenCertificate) {
+			c.ExpiryDate = expiry.AddDate(0, 3, 0)
+			c.Subject = "CN=other.example.com"
+		}, changeReplaced, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := old
+			current.FingerprintSHA256 = "BB"
+			tt.modify(&current)
+
+			kind, unexpected := classifyCertChange(old, current)
+			if kind != tt.kind || unexpected != tt.unexpected {
+				t.Errorf("classifyCertChange = (%q, %v), want (%q, %v)", kind, unexpected, tt.kind, tt.unexpected)
+			}
+		})
+	}
+}
+
+func changeTestResult(fingerprint string, issuer string, expiry time.Time) CertResult {
+	return CertResult{
+		URL:        "example.com",
+		Name:       "Example",
+		ExpiryDate: expiry,
+		LastCheck:  time.Now(),
+		Certificate: &CertDetails{
+			Subject:           "CN=example.com",
+			Issuer:            issuer,
+			NotAfter:          expiry,
+			FingerprintSHA256: fingerprint,
+			PublicKeySHA256:   "K1",
+		},
+	}
+}
+
+func TestDetectCertificateChanges(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	expiry := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+
+	// First sighting records the certificate without reporting a change
+	changes, err := detectCertificateChanges(ScanResults{
+		LastScan: time.Now(),
+		Results:  []CertResult{changeTestResult("AA", "CN=CA", expiry)},
+	}, 90)
+	if err != nil {
+		t.Fatalf("detectCertificateChanges returned error: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Expected no changes on first scan, got %d", len(changes))
+	}
+
+	// Same certificate again, and a failed scan which must not overwrite the record
+	failed := CertResult{URL: "example.com", Name: "Example", Error: "connection refused"}
+	changes, err = detectCertificateChanges(ScanResults{LastScan: time.Now(), Results: []CertResult{failed}}, 90)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes for a failed scan, got %d (err %v)", len(changes), err)
+	}
+
+	// Renewal from the same CA
+	renewed := expiry.Add(60 * 24 * time.Hour)
+	changes, err = detectCertificateChanges(ScanResults{
+		LastScan: time.Now(),
+		Results:  []CertResult{changeTestResult("BB", "CN=CA", renewed)},
+	}, 90)
+	if err != nil {
+		t.Fatalf("detectCertificateChanges returned error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Kind != changeRenewed || changes[0].Unexpected {
+		t.Fatalf("Expected one expected renewal, got %+v", changes)
+	}
+	if changes[0].OldFingerprint != "AA" || changes[0].NewFingerprint != "BB" {
+		t.Errorf("Unexpected fingerprints in change: %+v", changes[0])
+	}
+
+	// Swapped for a certificate from a different CA
+	changes, err = detectCertificateChanges(ScanResults{
+		LastScan: time.Now(),
+		Results:  []CertResult{changeTestResult("CC", "CN=Other CA", renewed)},
+	}, 90)
+	if err != nil {
+		t.Fatalf("detectCertificateChanges returned error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Kind != changeIssuerChanged || !changes[0].Unexpected {
+		t.Fatalf("Expected one unexpected issuer change, got %+v", changes)
+	}
+
+	state, err := loadCertificateState()
+	if err != nil {
+		t.Fatalf("loadCertificateState returned error: %v", err)
+	}
+	if state.Certificates["example.com"].FingerprintSHA256 != "CC" {
+		t.Errorf("Expected latest fingerprint CC to be stored, got %q", state.Certificates["example.com"].FingerprintSHA256)
+	}
+
+	history := changesForSite(state, "example.com")
+	if len(history) != 2 || history[0].NewFingerprint != "CC" || history[1].NewFingerprint != "BB" {
+		t.Errorf("Expected two changes newest first, got %+v", history)
+	}
+}
+
+func TestDetectCertificateChangesAcrossNodes(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	expiry := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	first := changeTestResult("AA", "CN=CA", expiry)
+	second := changeTestResult("BB", "CN=CA", expiry)
+
+	// Round-robin nodes serving two certificates, reported in either order
+	balanced := func(reported CertResult, nodes ...CertResult) CertResult {
+		for i, node := range nodes {
+			reported.Addresses = append(reported.Addresses, AddressResult{
+				Address:           fmt.Sprintf("192.0.2.%d", i+1),
+				FingerprintSHA256: node.Certificate.FingerprintSHA256,
+				Certificate:       node.Certificate,
+			})
+		}
+		return reported
+	}
+	scan := func(result CertResult) []CertChange {
+		t.Helper()
+		changes, err := detectCertificateChanges(ScanResults{LastScan: time.Now(), Results: []CertResult{result}}, 90)
+		if err != nil {
+			t.Fatalf("detectCertificateChanges returned error: %v", err)
+		}
+		return changes
+	}
+
+	scan(balanced(first, first, second))
+	if changes := scan(balanced(second, first, second)); len(changes) != 0 {
+		t.Errorf("Expected no change when the other node is reported, got %+v", changes)
+	}
+	// One node unreachable for a scan
+	if changes := scan(balanced(first, first)); len(changes) != 0 {
+		t.Errorf("Expected no change when a node is missing, got %+v", changes)
+	}
+	if changes := scan(balanced(second, first, second)); len(changes) != 0 {
+		t.Errorf("Expected no change when the node is back, got %+v", changes)
+	}
+
+	// One node renewed, the reported one still the soonest to expire
+	renewed := changeTestResult("CC", "CN=CA", expiry.Add(60*24*time.Hour))
+	changes := scan(balanced(first, first, renewed))
+	if len(changes) != 1 || changes[0].NewFingerprint != "CC" || changes[0].Kind != changeRenewed {
+		t.Fatalf("Expected the renewed node to be detected, got %+v", changes)
+	}
+	if changes := scan(balanced(first, first, renewed)); len(changes) != 0 {
+		t.Errorf("Expected the renewal to be reported once, got %+v", changes)
+	}
+}
+
+func TestDetectCertificateChangesPrunesOldChanges(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	now := time.Now()
+	err := saveCertificateState(CertificateState{
+		Certificates: map[string]SeenCertificate{},
+		Changes: []CertChange{
+			{URL: "example.com", DetectedAt: now.AddDate(0, 0, -40), NewFingerprint: "old"},
+			{URL: "example.com", DetectedAt: now.AddDate(0, 0, -10), NewFingerprint: "recent"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error saving certificate state: %v", err)
+	}
+
+	if _, err := detectCertificateChanges(ScanResults{LastScan: now}, 30); err != nil {
+		t.Fatalf("detectCertificateChanges returned error: %v", err)
+	}
+	state, _ := loadCertificateState()
+	if len(state.Changes) != 1 || state.Changes[0].NewFingerprint != "recent" {
+		t.Errorf("Expected only the change inside the retention period to be kept, got %+v", state.Changes)
+	}
+}
+
+func TestResultDetailHandlerShowsChanges(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	setupMinimalSettingsFile(t)
+	result := changeTestResult("BB", "CN=Other CA", time.Now().Add(90*24*time.Hour))
+	writeTestResults(t, ScanResults{LastScan: time.Now(), Results: []CertResult{result}})
+
+	err := saveCertificateState(CertificateState{
+		Certificates: map[string]SeenCertificate{},
+		Changes: []CertChange{{
+			URL:            "example.com",
+			Name:           "Example",
+			DetectedAt:     time.Now(),
+			Kind:           changeIssuerChanged,
+			Unexpected:     true,
+			OldFingerprint: "AA",
+			NewFingerprint: "BB",
+			OldIssuer:      "CN=CA",
+			NewIssuer:      "CN=Other CA",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to save certificate state: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/results/site?url="+url.QueryEscape("example.com"), nil)
+	w := httptest.NewRecorder()
+	resultDetailHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{"Certificate Changes", "Issuer changed", "change-badge unexpected"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected detail page to contain %q", expected)
+		}
+	}
+}
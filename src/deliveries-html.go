@@ -0,0 +1,216 @@
+package main
+
+const deliveriesTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>SSL Monitor - Notifications</title>
+    <style>
+        :root {
+            --bg-color: #f5f5f5;
+            --text-color: #333;
+            --text-secondary: #666;
+            --card-bg: white;
+            --border-color: #dee2e6;
+            --header-bg: #f8f9fa;
+            --input-bg: white;
+            --nav-bg: #007cba;
+            --nav-hover-bg: #005a8b;
+            --shadow: rgba(0,0,0,0.1);
+        }
+
+        @media (prefers-color-scheme: dark) {
+            :root {
+                --bg-color: #1a1a1a;
+                --text-color: #e0e0e0;
+                --text-secondary: #b0b0b0;
+                --card-bg: #2d2d2d;
+                --border-color: #404040;
+                --header-bg: #3a3a3a;
+                --input-bg: #3a3a3a;
+                --nav-bg: #0066a3;
+                --nav-hover-bg: #004d7a;
+                --shadow: rgba(0,0,0,0.3);
+            }
+        }
+
+        body {
+            font-family: Arial, sans-serif;
+            margin: 40px;
+            background-color: var(--bg-color);
+            color: var(--text-color);
+        }
+        .nav {
+            margin-bottom: 20px;
+        }
+        .nav a {
+            background: var(--nav-bg);
+            color: white;
+            padding: 8px 16px;
+            text-decoration: none;
+            border-radius: 4px;
+            margin-right: 10px;
+        }
+        .nav a:hover {
+            background: var(--nav-hover-bg);
+        }
+        .nav a.active {
+            background: var(--nav-hover-bg);
+        }
+        .header, .card {
+            background: var(--card-bg);
+            padding: 20px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            box-shadow: 0 2px 4px var(--shadow);
+        }
+        h1 {
+            margin: 0 0 10px 0;
+        }
+        .subtitle {
+            color: var(--text-secondary);
+            font-size: 14px;
+        }
+        .filters {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px;
+            align-items: flex-end;
+        }
+        .filters label {
+            display: block;
+            font-size: 12px;
+            color: var(--text-secondary);
+            margin-bottom: 4px;
+        }
+        .filters input, .filters select {
+            padding: 6px;
+            border: 1px solid var(--border-color);
+            border-radius: 4px;
+            background: var(--input-bg);
+            color: var(--text-color);
+        }
+        .filters button {
+            background: var(--nav-bg);
+            color: white;
+            border: none;
+            padding: 7px 14px;
+            border-radius: 4px;
+            cursor: pointer;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        th {
+            text-align: left;
+            padding: 10px;
+            background: var(--header-bg);
+            border-bottom: 1px solid var(--border-color);
+        }
+        td {
+            padding: 10px;
+            border-bottom: 1px solid var(--border-color);
+            vertical-align: top;
+        }
+        .sent {
+            color: #28a745;
+        }
+        .failed, .error-message {
+            color: #dc3545;
+        }
+        .error-message {
+            font-style: italic;
+            word-break: break-all;
+        }
+    </style>
+</head>
+<body>
+    <div class="nav">
+        <a href="/results">Results</a>
+        <a href="/sites">Sites</a>
+        <a href="/notifications" class="active">Notifications</a>
+        <a href="/settings">Settings</a>
+    </div>
+
+    <div class="header">
+        <h1>Notifications</h1>
+        <div class="subtitle">Every attempt to deliver an alert or digest, newest first. Failed deliveries are retried from the <a href="/outbox">delivery queue</a>. Also available as <a href="/notifications.json?{{.Query}}">JSON</a>.</div>
+    </div>
+
+    <div class="card">
+        <form method="GET" action="/notifications" class="filters">
+            <div>
+                <label>Site</label>
+                <input type="text" name="site" value="{{.Filter.Site}}" placeholder="URL or name">
+            </div>
+            <div>
+                <label>Channel</label>
+                <select name="channel">
+                    <option value="">All</option>
+                    {{range .Channels}}<option value="{{.}}"{{if eq . $.Filter.Channel}} selected{{end}}>{{.}}</option>{{end}}
+                </select>
+            </div>
+            <div>
+                <label>Severity</label>
+                <select name="severity">
+                    <option value="">All</option>
+                    {{range .Severities}}<option value="{{.}}"{{if eq . $.Filter.Severity}} selected{{end}}>{{.}}</option>{{end}}
+                </select>
+            </div>
+            <div>
+                <label>Result</label>
+                <select name="result">
+                    <option value="">All</option>
+                    <option value="sent"{{if eq .Filter.Result "sent"}} selected{{end}}>Sent</option>
+                    <option value="failed"{{if eq .Filter.Result "failed"}} selected{{end}}>Failed</option>
+                </select>
+            </div>
+            <div>
+                <label>Show</label>
+                <input type="number" name="limit" value="{{.Filter.Limit}}" min="1">
+            </div>
+            <div>
+                <button type="submit">Filter</button>
+            </div>
+        </form>
+    </div>
+
+    <div class="card">
+        {{if .Deliveries}}
+        <p class="subtitle">Showing {{len .Deliveries}} of {{.Total}} matching deliveries.</p>
+        <table>
+            <thead>
+                <tr>
+                    <th>Time</th>
+                    <th>Site</th>
+                    <th>Severity</th>
+                    <th>Channel</th>
+                    <th>Recipient</th>
+                    <th>Attempt</th>
+                    <th>Result</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Deliveries}}
+                <tr>
+                    <td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+                    <td>{{if .URL}}{{.Name}}<div class="subtitle">{{.URL}}</div>{{else}}&mdash;{{end}}</td>
+                    <td>{{.Severity}}{{if .Reminder}} (reminder {{.Reminder}}){{end}}{{if .Batch}}<div class="subtitle">batch of {{.Batch}} sites</div>{{end}}</td>
+                    <td>{{.Channel}}</td>
+                    <td>{{.Recipient}}</td>
+                    <td>{{.Attempt}}</td>
+                    <td>
+                        {{if .Success}}<span class="sent">Sent{{if .Status}} ({{.Status}}){{end}}</span>{{else}}<span class="failed">Failed{{if .Status}} ({{.Status}}){{end}}</span>
+                        <div class="error-message">{{.Error}}</div>{{end}}
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{else}}
+        <p class="subtitle">No deliveries match.</p>
+        {{end}}
+    </div>
+</body>
+</html>`
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDeliveryLimit is how many deliveries the log page and endpoint
+// return unless asked for more
+const defaultDeliveryLimit = 200
+
+// DeliveryRecord is one attempt to send a notification or digest through a
+// channel
+type DeliveryRecord struct {
+	Time      time.Time `json:"time"`
+	Channel   string    `json:"channel"`
+	URL       string    `json:"url,omitempty"` // empty for digests
+	Name      string    `json:"name,omitempty"`
+	Severity  string    `json:"severity"` // a notification severity or "digest"
+	Reminder  int       `json:"reminder,omitempty"`
+	Recipient string    `json:"recipient,omitempty"`
+	Attempt   int       `json:"attempt"`         // 1 for the first send, higher for outbox retries
+	Batch     int       `json:"batch,omitempty"` // sites listed in the same message, when batched
+	Success   bool      `json:"success"`
+	Status    int       `json:"status,omitempty"` // HTTP or SMTP status the server answered with
+	Error     string    `json:"error,omitempty"`
+}
+
+// deliveryLogMutex serializes appends, which come from scans, reminders and
+// retries running side by side
+var deliveryLogMutex sync.Mutex
+
+// The delivery log is a JSON lines file like the scan history, so each send
+// only has to append to it
+func getDeliveryLogFilePath() string {
+	return filepath.Join(dataDirPath, "deliveries.jsonl")
+}
+
+// urlHost reduces a webhook URL, whose path is often a secret, to its host
+func urlHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// responseStatus finds the server's status code in a send error, for senders
+// that fail before they can return it
+func responseStatus(err error) int {
+	var statusErr *statusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code
+	}
+	return 0
+}
+
+func deliveryRecordFor(notifier Notifier, notification Notification, settings Settings, attempt int, status int, err error) DeliveryRecord {
+	record := DeliveryRecord{
+		Time:     time.Now(),
+		Channel:  notifier.Name(),
+		URL:      notification.Result.URL,
+		Name:     notification.Result.Name,
+		Severity: notification.Severity,
+		Reminder: notification.Reminder,
+		Attempt:  attempt,
+		Success:  err == nil,
+		Status:   status,
+	}
+	if recipient, ok := notifier.(recipientNotifier); ok {
+		record.Recipient = recipient.Recipient(settings)
+	}
+	if err != nil {
+		if record.Status == 0 {
+			record.Status = responseStatus(err)
+		}
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// recordDelivery appends a delivery to the log. A log that can't be written
+// mustn't stop notifications, so errors are only logged.
+func recordDelivery(record DeliveryRecord) {
+	deliveryLogMutex.Lock()
+	defer deliveryLogMutex.Unlock()
+
+	file, err := os.OpenFile(getDeliveryLogFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		LogError("Error opening delivery log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(record); err != nil {
+		LogError("Error writing delivery log: %v", err)
+	}
+}
+
+func loadDeliveries() ([]DeliveryRecord, error) {
+	file, err := os.Open(getDeliveryLogFilePath())
+	if err != nil {
+		// Nothing sent yet
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []DeliveryRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record DeliveryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			LogWarning("Skipping unreadable delivery log entry: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// pruneDeliveries drops deliveries older than the cutoff, rewriting the log
+// only when something has expired
+func pruneDeliveries(cutoff time.Time) error {
+	deliveryLogMutex.Lock()
+	defer deliveryLogMutex.Unlock()
+
+	records, err := loadDeliveries()
+	if err != nil {
+		return err
+	}
+
+	kept := records[:0]
+	for _, record := range records {
+		if !record.Time.Before(cutoff) {
+			kept = append(kept, record)
+		}
+	}
+	if len(kept) == len(records) {
+		return nil
+	}
+
+	LogDebug("Pruning %d delivery log entries older than %s", len(records)-len(kept), cutoff.Format("2006-01-02"))
+
+	tempPath := getDeliveryLogFilePath() + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range kept {
+		if err = encoder.Encode(record); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, getDeliveryLogFilePath())
+}
+
+// DeliveryFilter narrows the delivery log. Empty fields match everything.
+type DeliveryFilter struct {
+	Channel  string `json:"channel,omitempty"`
+	Site     string `json:"site,omitempty"` // part of the site URL or name, any case
+	Severity string `json:"severity,omitempty"`
+	Result   string `json:"result,omitempty"` // "sent" or "failed"
+	Limit    int    `json:"limit"`
+}
+
+func parseDeliveryFilter(query url.Values) DeliveryFilter {
+	filter := DeliveryFilter{
+		Channel:  query.Get("channel"),
+		Site:     strings.TrimSpace(query.Get("site")),
+		Severity: query.Get("severity"),
+		Result:   query.Get("result"),
+		Limit:    defaultDeliveryLimit,
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	return filter
+}
+
+func (f DeliveryFilter) matches(record DeliveryRecord) bool {
+	if f.Channel != "" && record.Channel != f.Channel {
+		return false
+	}
+	if f.Severity != "" && record.Severity != f.Severity {
+		return false
+	}
+	if (f.Result == "sent" && !record.Success) || (f.Result == "failed" && record.Success) {
+		return false
+	}
+	if f.Site != "" {
+		site := strings.ToLower(f.Site)
+		if !strings.Contains(strings.ToLower(record.URL), site) && !strings.Contains(strings.ToLower(record.Name), site) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterDeliveries returns the matching deliveries newest first, up to the
+// filter's limit, and how many matched in all
+func filterDeliveries(records []DeliveryRecord, filter DeliveryFilter) ([]DeliveryRecord, int) {
+	matched := []DeliveryRecord{}
+	total := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		if !filter.matches(records[i]) {
+			continue
+		}
+		total++
+		if len(matched) < filter.Limit {
+			matched = append(matched, records[i])
+		}
+	}
+	return matched, total
+}
+
+// DeliveryLog is the filtered log served as JSON
+type DeliveryLog struct {
+	Filter     DeliveryFilter   `json:"filter"`
+	Total      int              `json:"total"` // matching deliveries, before the limit
+	Deliveries []DeliveryRecord `json:"deliveries"`
+}
+
+// DeliveriesPageData is the delivery log page
+type DeliveriesPageData struct {
+	DeliveryLog
+	Channels   []string
+	Severities []string
+	Query      string // the filter, for the JSON link
+}
+
+func loadDeliveryLog(r *http.Request) (DeliveryLog, error) {
+	records, err := loadDeliveries()
+	if err != nil {
+		return DeliveryLog{}, err
+	}
+	filter := parseDeliveryFilter(r.URL.Query())
+	deliveries, total := filterDeliveries(records, filter)
+	return DeliveryLog{Filter: filter, Total: total, Deliveries: deliveries}, nil
+}
+
+// deliveriesHandler shows the delivery log, filtered by the query parameters
+// channel, site, severity, result and limit
+func deliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryLog, err := loadDeliveryLog(r)
+	if err != nil {
+		LogError("Error loading delivery log: %v", err)
+		http.Error(w, "Error loading delivery log", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := DeliveriesPageData{
+		DeliveryLog: deliveryLog,
+		Severities:  append(append([]string{}, notificationSeverities...), "digest"),
+		Query:       r.URL.RawQuery,
+	}
+	for _, notifier := range notifiers {
+		pageData.Channels = append(pageData.Channels, notifier.Name())
+	}
+
+	parsedTemplate := template.Must(template.New("deliveries").Parse(deliveriesTemplate))
+	parsedTemplate.Execute(w, pageData)
+}
+
+// deliveriesJSONHandler returns the same filtered log as JSON
+func deliveriesJSONHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryLog, err := loadDeliveryLog(r)
+	if err != nil {
+		LogError("Error loading delivery log: %v", err)
+		http.Error(w, "Error loading delivery log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveryLog)
+}
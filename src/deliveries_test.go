@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveriesAreLogged(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	working := &fakeNotifier{name: "working", enabled: map[string]bool{"critical": true}}
+	failing := &fakeNotifier{name: "failing", enabled: map[string]bool{"critical": true}, sendErr: &statusCodeError{service: "failing", StatusCode: 503}}
+	useFakeNotifiers(t, working, failing)
+
+	notifyAll(Notification{Severity: "critical", Reminder: 2, Result: CertResult{URL: "example.com", Name: "Example"}}, Settings{})
+
+	records, err := loadDeliveries()
+	if err != nil {
+		t.Fatalf("Error loading delivery log: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", len(records))
+	}
+	sent, failed := records[0], records[1]
+	if sent.Channel != "working" || !sent.Success || sent.URL != "example.com" || sent.Name != "Example" ||
+		sent.Severity != "critical" || sent.Reminder != 2 || sent.Attempt != 1 || sent.Error != "" {
+		t.Errorf("Unexpected successful delivery: %+v", sent)
+	}
+	if failed.Channel != "failing" || failed.Success || failed.Status != 503 || failed.Error != "failing returned status code: 503" {
+		t.Errorf("Unexpected failed delivery: %+v", failed)
+	}
+
+	// The outbox retry is logged as a second attempt
+	failing.sendErr = nil
+	if err := processOutbox(Settings{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	records, _ = loadDeliveries()
+	if len(records) != 3 || records[2].Attempt != 2 || !records[2].Success {
+		t.Errorf("Expected the retry to be logged as a successful second attempt, got %+v", records)
+	}
+}
+
+func TestDeliveryStatusIsLoggedOnSuccess(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	// Teams workflows answer 202
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var settings Settings
+	settings.Notifications.Webhook.EnabledWarning = true
+	settings.Notifications.Webhook.URL = server.URL
+	if sent := notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "example.com", DaysLeft: 20}}, settings); sent != 1 {
+		t.Fatalf("Expected the webhook to be sent, got %d sent", sent)
+	}
+
+	records, err := loadDeliveries()
+	if err != nil {
+		t.Fatalf("Error loading delivery log: %v", err)
+	}
+	if len(records) != 1 || !records[0].Success || records[0].Status != http.StatusAccepted {
+		t.Errorf("Expected a successful delivery with status 202, got %+v", records)
+	}
+
+	w := httptest.NewRecorder()
+	deliveriesHandler(w, httptest.NewRequest("GET", "/notifications", nil))
+	if !strings.Contains(w.Body.String(), "Sent (202)") {
+		t.Error("Expected the page to show the status of the successful delivery")
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{&statusCodeError{service: "ntfy", StatusCode: 429}, 429},
+		{fmt.Errorf("recipient ops@example.com rejected: %w", &textproto.Error{Code: 550, Msg: "mailbox unavailable"}), 550},
+		{fmt.Errorf("dial tcp: connection refused"), 0},
+	}
+	for _, tt := range tests {
+		if got := responseStatus(tt.err); got != tt.status {
+			t.Errorf("responseStatus(%v): expected %d, got %d", tt.err, tt.status, got)
+		}
+	}
+
+	var settings Settings
+	settings.Notifications.Webhook.URL = "https://hooks.example.com/services/T000/B000/secret"
+	if recipient := (webhookNotifier{}).Recipient(settings); recipient != "hooks.example.com" {
+		t.Errorf("Expected webhook recipients to be reduced to the host, got %q", recipient)
+	}
+}
+
+func TestFilterDeliveries(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	records := []DeliveryRecord{
+		{Time: start, Channel: "email", URL: "shop.example.com", Name: "Shop", Severity: "warning", Success: true},
+		{Time: start.Add(time.Minute), Channel: "ntfy", URL: "shop.example.com", Name: "Shop", Severity: "warning", Error: "timeout"},
+		{Time: start.Add(2 * time.Minute), Channel: "email", URL: "api.example.com", Name: "API", Severity: "critical", Success: true},
+		{Time: start.Add(3 * time.Minute), Channel: "email", Severity: "digest", Success: true},
+	}
+
+	tests := []struct {
+		name   string
+		filter DeliveryFilter
+		times  []int // minutes after start, newest first
+		total  int
+	}{
+		{"everything", DeliveryFilter{Limit: 10}, []int{3, 2, 1, 0}, 4},
+		{"channel", DeliveryFilter{Channel: "email", Limit: 10}, []int{3, 2, 0}, 3},
+		{"site by name", DeliveryFilter{Site: "shop", Limit: 10}, []int{1, 0}, 2},
+		{"site by url", DeliveryFilter{Site: "API.example", Limit: 10}, []int{2}, 1},
+		{"severity", DeliveryFilter{Severity: "digest", Limit: 10}, []int{3}, 1},
+		{"failed", DeliveryFilter{Result: "failed", Limit: 10}, []int{1}, 1},
+		{"sent", DeliveryFilter{Result: "sent", Site: "shop", Limit: 10}, []int{0}, 1},
+		{"limit", DeliveryFilter{Limit: 2}, []int{3, 2}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, total := filterDeliveries(records, tt.filter)
+			if total != tt.total {
+				t.Errorf("Expected %d matches, got %d", tt.total, total)
+			}
+			if len(matched) != len(tt.times) {
+				t.Fatalf("Expected %d deliveries, got %d", len(tt.times), len(matched))
+			}
+			for i, minutes := range tt.times {
+				if !matched[i].Time.Equal(start.Add(time.Duration(minutes) * time.Minute)) {
+					t.Errorf("Delivery %d: expected the one at +%dm, got %s", i, minutes, matched[i].Time)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliveriesHandlers(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	recordDelivery(DeliveryRecord{Time: time.Now(), Channel: "email", URL: "ok.example.com", Severity: "warning", Recipient: "ops@example.com", Attempt: 1, Success: true})
+	recordDelivery(DeliveryRecord{Time: time.Now(), Channel: "ntfy", URL: "down.example.com", Severity: "critical", Attempt: 1, Status: 502, Error: "ntfy returned status code: 502"})
+
+	w := httptest.NewRecorder()
+	deliveriesHandler(w, httptest.NewRequest("GET", "/notifications?result=failed", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "down.example.com") || !strings.Contains(body, "Failed (502)") {
+		t.Error("Expected the page to show the failed delivery and its status")
+	}
+	if strings.Contains(body, "ok.example.com") {
+		t.Error("Expected the page to leave out deliveries that don't match the filter")
+	}
+
+	w = httptest.NewRecorder()
+	deliveriesJSONHandler(w, httptest.NewRequest("GET", "/notifications.json?channel=email", nil))
+	var deliveryLog DeliveryLog
+	if err := json.Unmarshal(w.Body.Bytes(), &deliveryLog); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if deliveryLog.Total != 1 || len(deliveryLog.Deliveries) != 1 || deliveryLog.Deliveries[0].Recipient != "ops@example.com" {
+		t.Errorf("Unexpected JSON delivery log: %+v", deliveryLog)
+	}
+	if deliveryLog.Filter.Channel != "email" || deliveryLog.Filter.Limit != defaultDeliveryLimit {
+		t.Errorf("Expected the filter to be echoed back, got %+v", deliveryLog.Filter)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Digest frequencies
+const (
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// digestCheckInterval is how often the digest schedule is checked. A digest
+// is only sent within an hour of its scheduled time, so one missed while the
+// monitor was down is skipped rather than sent late.
+const digestCheckInterval = 10 * time.Minute
+
+// DigestSettings schedules a summary of every monitored certificate
+type DigestSettings struct {
+	Enabled   bool     `json:"enabled"`
+	Frequency string   `json:"frequency"`          // "daily" or "weekly"
+	Weekday   int      `json:"weekday"`            // 0 is Sunday, for weekly digests
+	Hour      int      `json:"hour"`               // hour of the day, in server time
+	EmailTo   string   `json:"email_to,omitempty"` // recipients, empty uses the email channel's
+	Channels  []string `json:"channels,omitempty"` // other channels that also get the digest
+}
+
+// Digest is a summary of the latest scan results grouped by status
+type Digest struct {
+	Generated time.Time     `json:"generated"`
+	LastScan  time.Time     `json:"last_scan"`
+	Groups    []DigestGroup `json:"groups"`
+}
+
+// DigestGroup lists the sites in one status, soonest expiry first
+type DigestGroup struct {
+	Status  string       `json:"status"` // "error", "critical", "warning" or "normal"
+	Label   string       `json:"label"`
+	Results []CertResult `json:"results"`
+}
+
+// digestNotifier is implemented by channels that can deliver a digest
+type digestNotifier interface {
+	SendDigest(digest Digest, settings Settings) (int, error)
+}
+
+var digestStatuses = []struct {
+	status string
+	label  string
+}{
+	{"error", "Errors"},
+	{"critical", "Critical"},
+	{"warning", "Warning"},
+	{"normal", "OK"},
+}
+
+// buildDigest groups the results by status. Failed checks are listed as
+// errors whatever the scan error threshold.
+func buildDigest(results ScanResults, settings Settings, now time.Time) Digest {
+	byStatus := make(map[string][]CertResult)
+	for _, result := range results.Results {
+		status := "error"
+		if result.Error == "" {
+			status = determineCurrentStatus(result.DaysLeft, settings)
+		}
+		byStatus[status] = append(byStatus[status], result)
+	}
+
+	digest := Digest{Generated: now, LastScan: results.LastScan}
+	for _, group := range digestStatuses {
+		sites := byStatus[group.status]
+		if len(sites) == 0 {
+			continue
+		}
+		sort.SliceStable(sites, func(i, j int) bool {
+			if sites[i].DaysLeft != sites[j].DaysLeft {
+				return sites[i].DaysLeft < sites[j].DaysLeft
+			}
+			return sites[i].Name < sites[j].Name
+		})
+		digest.Groups = append(digest.Groups, DigestGroup{Status: group.status, Label: group.label, Results: sites})
+	}
+	return digest
+}
+
+// Summary counts the sites in each group, e.g. "1 critical, 12 ok"
+func (d Digest) Summary() string {
+	if len(d.Groups) == 0 {
+		return "no sites monitored"
+	}
+	var parts []string
+	for _, group := range d.Groups {
+		status := group.Status
+		if status == "normal" {
+			status = "ok"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", len(group.Results), status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WorstStatus is the most urgent status with any sites in it
+func (d Digest) WorstStatus() string {
+	if len(d.Groups) == 0 {
+		return "normal"
+	}
+	return d.Groups[0].Status
+}
+
+// digestLine describes one site on a single line for the text channels
+func digestLine(result CertResult) string {
+	if result.Error != "" {
+		return fmt.Sprintf("%s (%s): %s", result.Name, result.URL, result.Error)
+	}
+	return fmt.Sprintf("%s (%s): %d days, expires %s", result.Name, result.URL, result.DaysLeft, result.ExpiryDate.Format("2006-01-02"))
+}
+
+// digestText lists every site that needs attention, one per line. Sites that
+// are fine are only counted.
+func digestText(digest Digest) string {
+	lines := []string{fmt.Sprintf("Certificates: %s.", digest.Summary())}
+	for _, group := range digest.Groups {
+		if group.Status == "normal" {
+			continue
+		}
+		lines = append(lines, "", group.Label+":")
+		for _, result := range group.Results {
+			lines = append(lines, "- "+digestLine(result))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func digestSubject(digest Digest, settings Settings) string {
+	frequency := "Weekly"
+	if settings.Digest.Frequency == digestDaily {
+		frequency = "Daily"
+	}
+	return fmt.Sprintf("%s SSL Certificate Digest: %s", frequency, digest.Summary())
+}
+
+// digestEmailBody renders the digest as an HTML table per status
+func digestEmailBody(digest Digest, settings Settings) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "<h2>SSL Certificate Digest</h2>\n<p>%d sites as of the scan at %s: %s.</p>\n",
+		countDigestSites(digest), digest.LastScan.Format("2006-01-02 15:04"), html.EscapeString(digest.Summary()))
+
+	for _, group := range digest.Groups {
+		fmt.Fprintf(&body, "<h3>%s (%d)</h3>\n<table>\n", html.EscapeString(group.Label), len(group.Results))
+		body.WriteString("<tr><th align=\"left\">Site</th><th align=\"left\">URL</th><th align=\"left\">Days left</th><th align=\"left\">Expiry date</th><th align=\"left\">Error</th></tr>\n")
+		for _, result := range group.Results {
+			daysLeft, expiry := "", ""
+			if result.Error == "" {
+				daysLeft = fmt.Sprintf("%d", result.DaysLeft)
+				expiry = result.ExpiryDate.Format("2006-01-02")
+			}
+			fmt.Fprintf(&body, "<tr><td>%s</td><td><a href=\"%s\">%s</a></td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(result.Name), html.EscapeString(siteDashboardURL(settings, result.URL)), html.EscapeString(result.URL),
+				daysLeft, expiry, html.EscapeString(result.Error))
+		}
+		body.WriteString("</table>\n")
+	}
+
+	fmt.Fprintf(&body, "<p><a href=\"%s/results\">Open the dashboard</a></p>\n", html.EscapeString(dashboardURL(settings)))
+	return body.String()
+}
+
+func countDigestSites(digest Digest) int {
+	count := 0
+	for _, group := range digest.Groups {
+		count += len(group.Results)
+	}
+	return count
+}
+
+// lastDigestTime is the most recent scheduled digest time at or before now
+func lastDigestTime(digestSettings DigestSettings, now time.Time) time.Time {
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), digestSettings.Hour, 0, 0, 0, now.Location())
+	if scheduled.After(now) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	if digestSettings.Frequency == digestDaily {
+		return scheduled
+	}
+
+	daysBack := (int(scheduled.Weekday()) - digestSettings.Weekday + 7) % 7
+	return scheduled.AddDate(0, 0, -daysBack)
+}
+
+// digestDue reports whether a scheduled digest hasn't been sent yet
+func digestDue(digestSettings DigestSettings, lastSent time.Time, now time.Time) bool {
+	if !digestSettings.Enabled {
+		return false
+	}
+	scheduled := lastDigestTime(digestSettings, now)
+	return lastSent.Before(scheduled) && now.Sub(scheduled) < time.Hour
+}
+
+// sendDigest delivers the digest by email and through the extra channels,
+// returning how many sends succeeded
+func sendDigest(digest Digest, settings Settings) int {
+	sent := 0
+	for _, notifier := range notifiers {
+		if notifier.Name() != "email" && !containsString(settings.Digest.Channels, notifier.Name()) {
+			continue
+		}
+		sender, ok := notifier.(digestNotifier)
+		if !ok {
+			LogWarning("%s can't send digests, skipping", notifier.Label())
+			continue
+		}
+
+		LogInfo("Sending %s digest", notifier.Label())
+		status, err := sender.SendDigest(digest, settings)
+		if errors.Is(err, errIncompleteSettings) {
+			LogWarning("%s digest not sent: %v", notifier.Label(), err)
+			continue
+		}
+		recordNotificationSend(notifier.Name(), err)
+		record := deliveryRecordFor(notifier, Notification{Severity: "digest"}, settings, 1, status, err)
+		if notifier.Name() == "email" && settings.Digest.EmailTo != "" {
+			record.Recipient = settings.Digest.EmailTo
+		}
+		recordDelivery(record)
+		if err != nil {
+			LogError("Error sending %s digest: %v", notifier.Label(), err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// processDigest sends the digest if one is due and records when it went out
+func processDigest(settings Settings, now time.Time) error {
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		return fmt.Errorf("error loading notification state: %w", err)
+	}
+	if !digestDue(settings.Digest, state.LastDigest, now) {
+		return nil
+	}
+
+	results, err := loadResults()
+	if err != nil {
+		return fmt.Errorf("error loading results: %w", err)
+	}
+
+	digest := buildDigest(results, settings, now)
+	sent := sendDigest(digest, settings)
+	LogInfo("Digest processing complete. Sent %d digests (%s)", sent, digest.Summary())
+
+	state.LastDigest = now
+	return saveNotificationState(state)
+}
+
+// runDigestSchedule sends digests on schedule until the process exits
+func runDigestSchedule(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settings, err := loadSettings()
+		if err != nil {
+			LogError("Error loading settings for digest: %v", err)
+			continue
+		}
+		err = processDigest(settings, time.Now())
+		if err != nil {
+			LogError("Error processing digest: %v", err)
+		}
+	}
+}
+
+// sendDigestHandler sends the digest straight away, for trying the settings out
+func sendDigestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		LogError("Error loading settings for digest: %v", err)
+		http.Error(w, "Error loading settings", http.StatusInternalServerError)
+		return
+	}
+	results, err := loadResults()
+	if err != nil {
+		LogError("Error loading results for digest: %v", err)
+		http.Error(w, "Error loading results", http.StatusInternalServerError)
+		return
+	}
+
+	digest := buildDigest(results, settings, time.Now())
+	sent := sendDigest(digest, settings)
+	if sent == 0 {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "Digest was not sent, check the email settings and the logs")
+		return
+	}
+	fmt.Fprintf(w, "Digest sent through %d channel(s): %s", sent, digest.Summary())
+}
+
+// parseDigestForm copies the digest fields from the settings form
+func parseDigestForm(r *http.Request, settings *Settings) {
+	if _, ok := r.Form["digest_frequency"]; !ok {
+		return
+	}
+
+	digest := &settings.Digest
+	digest.Enabled = r.FormValue("digest_enabled") == "on"
+	digest.Frequency = digestWeekly
+	if r.FormValue("digest_frequency") == digestDaily {
+		digest.Frequency = digestDaily
+	}
+	if weekday := parseInt(r.FormValue("digest_weekday")); weekday >= 0 && weekday <= 6 {
+		digest.Weekday = weekday
+	}
+	if hour := parseInt(r.FormValue("digest_hour")); hour >= 0 && hour <= 23 {
+		digest.Hour = hour
+	}
+	digest.EmailTo = strings.TrimSpace(r.FormValue("digest_email_to"))
+	digest.Channels = parseChannelList(r.FormValue("digest_channels"))
+
+	LogDebug("Updated digest: enabled=%v, %s at %02d:00", digest.Enabled, digest.Frequency, digest.Hour)
+}
+
+// digestJSON is the body webhooks receive for a digest
+func digestJSON(digest Digest) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Event string `json:"event"`
+		Digest
+	}{"digest", digest}, "", "  ")
+}
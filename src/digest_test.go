@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func digestTestSettings() Settings {
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	settings.Dashboard.URL = "https://ssl.example.com"
+	return settings
+}
+
+var digestTestResults = ScanResults{
+	LastScan: time.Date(2025, 1, 6, 7, 30, 0, 0, time.UTC),
+	Results: []CertResult{
+		{URL: "fine.example.com", Name: "Fine", DaysLeft: 80},
+		{URL: "soon.example.com", Name: "Soon", DaysLeft: 20},
+		{URL: "sooner.example.com", Name: "Sooner", DaysLeft: 10},
+		{URL: "down.example.com", Name: "Down", Error: "connection refused"},
+		{URL: "urgent.example.com", Name: "Urgent <b>", DaysLeft: 2},
+	},
+}
+
+func TestBuildDigest(t *testing.T) {
+	digest := buildDigest(digestTestResults, digestTestSettings(), time.Now())
+
+	var groups []string
+	for _, group := range digest.Groups {
+		var names []string
+		for _, result := range group.Results {
+			names = append(names, result.Name)
+		}
+		groups = append(groups, group.Status+":"+strings.Join(names, ","))
+	}
+	expected := "error:Down critical:Urgent <b> warning:Sooner,Soon normal:Fine"
+	if strings.Join(groups, " ") != expected {
+		t.Errorf("Expected groups %q, got %q", expected, strings.Join(groups, " "))
+	}
+	if digest.Summary() != "1 error, 1 critical, 2 warning, 1 ok" {
+		t.Errorf("Unexpected summary %q", digest.Summary())
+	}
+	if digest.WorstStatus() != "error" {
+		t.Errorf("Expected worst status error, got %s", digest.WorstStatus())
+	}
+
+	text := digestText(digest)
+	if !strings.Contains(text, "- Down (down.example.com): connection refused") || strings.Contains(text, "Fine") {
+		t.Errorf("Text digest should list sites needing attention only:\n%s", text)
+	}
+
+	body := digestEmailBody(digest, digestTestSettings())
+	for _, expected := range []string{"<h3>Warning (2)</h3>", "Urgent &lt;b&gt;", "https://ssl.example.com/results/site?url=fine.example.com", "<td>80</td>"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected email body to contain %q", expected)
+		}
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	// Monday 6 January 2025
+	monday9 := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	weekly := DigestSettings{Enabled: true, Frequency: digestWeekly, Weekday: int(time.Monday), Hour: 9}
+	daily := DigestSettings{Enabled: true, Frequency: digestDaily, Hour: 9}
+
+	tests := []struct {
+		name     string
+		settings DigestSettings
+		lastSent time.Time
+		now      time.Time
+		due      bool
+	}{
+		{"weekly at the scheduled hour", weekly, time.Time{}, monday9.Add(5 * time.Minute), true},
+		{"weekly before the hour", weekly, time.Time{}, monday9.Add(-5 * time.Minute), false},
+		{"weekly already sent", weekly, monday9.Add(time.Minute), monday9.Add(20 * time.Minute), false},
+		{"weekly on another day", weekly, time.Time{}, monday9.AddDate(0, 0, 1), false},
+		{"weekly missed by more than an hour", weekly, time.Time{}, monday9.Add(3 * time.Hour), false},
+		{"weekly sent last week", weekly, monday9.AddDate(0, 0, -7), monday9.Add(10 * time.Minute), true},
+		{"daily next day", daily, monday9.Add(time.Minute), monday9.AddDate(0, 0, 1).Add(time.Minute), true},
+		{"disabled", DigestSettings{Frequency: digestDaily, Hour: 9}, time.Time{}, monday9, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if due := digestDue(tt.settings, tt.lastSent, tt.now); due != tt.due {
+				t.Errorf("Expected due=%v, got %v", tt.due, due)
+			}
+		})
+	}
+}
+
+func TestProcessDigest(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	if err := saveResults(digestTestResults); err != nil {
+		t.Fatalf("Error saving results: %v", err)
+	}
+
+	var emails []map[string]interface{}
+	postmark := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var email map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&email)
+		emails = append(emails, email)
+	}))
+	defer postmark.Close()
+	originalURL := postmarkAPIURL
+	postmarkAPIURL = postmark.URL
+	defer func() { postmarkAPIURL = originalURL }()
+
+	slack, slackPayload := startChatServer(t, http.StatusOK)
+
+	settings := digestTestSettings()
+	settings.Notifications.Email = EmailSettings{ServerToken: "token", From: "monitor@example.com", To: "ops@example.com"}
+	settings.Notifications.Slack.WebhookURL = slack.URL
+	settings.Digest = DigestSettings{
+		Enabled:   true,
+		Frequency: digestWeekly,
+		Weekday:   int(time.Monday),
+		Hour:      9,
+		EmailTo:   "management@example.com",
+		Channels:  []string{"slack"},
+	}
+
+	now := time.Date(2025, 1, 6, 9, 10, 0, 0, time.Local)
+	if err := processDigest(settings, now); err != nil {
+		t.Fatalf("processDigest returned error: %v", err)
+	}
+
+	if len(emails) != 1 {
+		t.Fatalf("Expected one digest email, got %d", len(emails))
+	}
+	if emails[0]["To"] != "management@example.com" {
+		t.Errorf("Expected the digest recipients, got %v", emails[0]["To"])
+	}
+	if subject, _ := emails[0]["Subject"].(string); !strings.HasPrefix(subject, "Weekly SSL Certificate Digest") {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	if len(*slackPayload) == 0 {
+		t.Error("Expected the digest to be posted to Slack too")
+	}
+
+	// The next check in the same hour doesn't send it again
+	if err := processDigest(settings, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("processDigest returned error: %v", err)
+	}
+	if len(emails) != 1 {
+		t.Errorf("Expected the digest to be sent once, got %d", len(emails))
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultHistoryRetentionDays = 90
+
+// HistoryEntry is the outcome of checking one site in one scan
+type HistoryEntry struct {
+	URL               string    `json:"url"`
+	Time              time.Time `json:"time"`
+	DaysLeft          int       `json:"days_left"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	VerifyErrorType   string    `json:"verify_error_type,omitempty"`
+	HandshakeMs       int64     `json:"handshake_ms"`
+}
+
+type SiteHistory struct {
+	URL           string         `json:"url"`
+	Name          string         `json:"name"`
+	RetentionDays int            `json:"retention_days"`
+	Entries       []HistoryEntry `json:"entries"` // oldest first
+}
+
+// The history is a JSON lines file so each scan only has to append to it
+func getHistoryFilePath() string {
+	return filepath.Join(dataDirPath, "history.jsonl")
+}
+
+func historyRetentionDays(settings Settings) int {
+	if settings.HistoryRetentionDays > 0 {
+		return settings.HistoryRetentionDays
+	}
+	return defaultHistoryRetentionDays
+}
+
+func historyEntryFromResult(result CertResult) HistoryEntry {
+	entry := HistoryEntry{
+		URL:             result.URL,
+		Time:            result.LastCheck,
+		DaysLeft:        result.DaysLeft,
+		ExpiryDate:      result.ExpiryDate,
+		Error:           result.Error,
+		VerifyErrorType: result.VerifyErrorType,
+		HandshakeMs:     result.HandshakeMs,
+	}
+	if result.Certificate != nil {
+		entry.FingerprintSHA256 = result.Certificate.FingerprintSHA256
+	}
+	return entry
+}
+
+// appendHistory adds one entry per result to the history file
+func appendHistory(results ScanResults) error {
+	file, err := os.OpenFile(getHistoryFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, result := range results.Results {
+		err = encoder.Encode(historyEntryFromResult(result))
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func loadHistory() ([]HistoryEntry, error) {
+	file, err := os.Open(getHistoryFilePath())
+	if err != nil {
+		// No scans recorded yet
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a line left half written by an interrupted scan
+			LogWarning("Skipping unreadable history entry: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// pruneHistory drops entries older than the cutoff. The file is only
+// rewritten when something has expired.
+func pruneHistory(cutoff time.Time) error {
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !entry.Time.Before(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(entries) {
+		return nil
+	}
+
+	LogDebug("Pruning %d history entries older than %s", len(entries)-len(kept), cutoff.Format("2006-01-02"))
+
+	tempPath := getHistoryFilePath() + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range kept {
+		if err = encoder.Encode(entry); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, getHistoryFilePath())
+}
+
+// recordScanHistory appends the scan to the history and applies retention
+func recordScanHistory(results ScanResults, retentionDays int) error {
+	err := appendHistory(results)
+	if err != nil {
+		return err
+	}
+	return pruneHistory(results.LastScan.AddDate(0, 0, -retentionDays))
+}
+
+func historyForSite(entries []HistoryEntry, url string) []HistoryEntry {
+	siteEntries := []HistoryEntry{}
+	for _, entry := range entries {
+		if entry.URL == url {
+			siteEntries = append(siteEntries, entry)
+		}
+	}
+	return siteEntries
+}
+
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		LogError("Error loading scan history: %v", err)
+		http.Error(w, "Error loading history", http.StatusInternalServerError)
+		return
+	}
+
+	siteHistory := SiteHistory{
+		URL:     url,
+		Entries: historyForSite(entries, url),
+	}
+
+	sitesList, err := loadSitesList()
+	if err == nil {
+		for _, site := range sitesList.Sites {
+			if site.URL == url {
+				siteHistory.Name = site.Name
+				break
+			}
+		}
+	}
+	if siteHistory.Name == "" && len(siteHistory.Entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err == nil {
+		siteHistory.RetentionDays = historyRetentionDays(settings)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(siteHistory)
+}
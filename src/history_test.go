@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecordScanHistory(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	now := time.Now()
+	first := ScanResults{
+		LastScan: now.AddDate(0, 0, -40),
+		Results: []CertResult{
+			{URL: "example.com", LastCheck: now.AddDate(0, 0, -40), DaysLeft: 50, Certificate: &CertDetails{FingerprintSHA256: "AA"}},
+			{URL: "other.com", LastCheck: now.AddDate(0, 0, -40), Error: "connection refused"},
+		},
+	}
+	second := ScanResults{
+		LastScan: now,
+		Results: []CertResult{
+			{URL: "example.com", LastCheck: now, DaysLeft: 89, HandshakeMs: 42, Certificate: &CertDetails{FingerprintSHA256: "BB"}},
+			{URL: "other.com", LastCheck: now, Error: "connection refused"},
+		},
+	}
+
+	if err := recordScanHistory(first, 90); err != nil {
+		t.Fatalf("recordScanHistory returned error: %v", err)
+	}
+	if err := recordScanHistory(second, 90); err != nil {
+		t.Fatalf("recordScanHistory returned error: %v", err)
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory returned error: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 history entries, got %d", len(entries))
+	}
+
+	siteEntries := historyForSite(entries, "example.com")
+	if len(siteEntries) != 2 {
+		t.Fatalf("Expected 2 entries for example.com, got %d", len(siteEntries))
+	}
+	if siteEntries[0].FingerprintSHA256 != "AA" || siteEntries[1].FingerprintSHA256 != "BB" {
+		t.Errorf("Expected entries oldest first, got %+v", siteEntries)
+	}
+	if siteEntries[1].HandshakeMs != 42 {
+		t.Errorf("Expected handshake time 42 ms, got %d", siteEntries[1].HandshakeMs)
+	}
+	if errorEntries := historyForSite(entries, "other.com"); errorEntries[0].Error != "connection refused" {
+		t.Errorf("Expected scan error to be recorded, got %+v", errorEntries[0])
+	}
+
+	// A shorter retention drops the first scan
+	if err := recordScanHistory(ScanResults{LastScan: now}, 30); err != nil {
+		t.Fatalf("recordScanHistory returned error: %v", err)
+	}
+	entries, err = loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 entries after pruning, got %d", len(entries))
+	}
+}
+
+func TestLoadHistorySkipsPartialLine(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	content := `{"url":"example.com","time":"2025-01-01T00:00:00Z","days_left":30,"expiry_date":"2025-01-31T00:00:00Z","handshake_ms":10}
+{"url":"example.com","ti`
+	if err := os.WriteFile(getHistoryFilePath(), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].DaysLeft != 30 {
+		t.Errorf("Expected the one complete entry, got %+v", entries)
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	setupMinimalSettingsFile(t)
+	if err := saveSites([]Site{{Name: "Example", URL: "example.com", Enabled: true}}); err != nil {
+		t.Fatalf("Failed to save sites: %v", err)
+	}
+	err := appendHistory(ScanResults{Results: []CertResult{
+		{URL: "example.com", LastCheck: time.Now(), DaysLeft: 30},
+		{URL: "other.com", LastCheck: time.Now(), DaysLeft: 10},
+	}})
+	if err != nil {
+		t.Fatalf("appendHistory returned error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/results/history?url=example.com", nil)
+	w := httptest.NewRecorder()
+	historyHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", contentType)
+	}
+
+	var siteHistory SiteHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &siteHistory); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if siteHistory.Name != "Example" || len(siteHistory.Entries) != 1 || siteHistory.Entries[0].DaysLeft != 30 {
+		t.Errorf("Unexpected history response: %+v", siteHistory)
+	}
+	if siteHistory.RetentionDays != defaultHistoryRetentionDays {
+		t.Errorf("Expected default retention of %d days, got %d", defaultHistoryRetentionDays, siteHistory.RetentionDays)
+	}
+
+	req = httptest.NewRequest("GET", "/results/history?url=unknown.com", nil)
+	w = httptest.NewRecorder()
+	historyHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown site, got %d", w.Code)
+	}
+}
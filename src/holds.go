@@ -0,0 +1,385 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// heldCheckInterval is how often held notifications are checked for release
+const heldCheckInterval = 5 * time.Minute
+
+// datetimeLocalFormat is the value of an HTML datetime-local input
+const datetimeLocalFormat = "2006-01-02T15:04"
+
+// QuietHours holds notifications back overnight. Critical alerts still go out
+// unless HoldCritical is set.
+type QuietHours struct {
+	Enabled      bool   `json:"enabled"`
+	Start        string `json:"start"`              // "22:00"
+	End          string `json:"end"`                // "07:00", on the next day when before Start
+	Timezone     string `json:"timezone,omitempty"` // IANA name such as "Europe/London", empty uses server time
+	HoldCritical bool   `json:"hold_critical,omitempty"`
+}
+
+// MaintenanceWindow holds back every notification for a site during planned work
+type MaintenanceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Note  string    `json:"note,omitempty"`
+}
+
+// HeldNotification is a notification queued until its hold ends
+type HeldNotification struct {
+	Notification Notification `json:"notification"`
+	HeldAt       time.Time    `json:"held_at"`
+	Reason       string       `json:"reason"`
+}
+
+// parseClock reads "HH:MM" as minutes after midnight
+func parseClock(value string) (int, bool) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	return clock.Hour()*60 + clock.Minute(), true
+}
+
+func quietHoursLocation(quiet QuietHours) *time.Location {
+	if quiet.Timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(quiet.Timezone)
+	if err != nil {
+		LogWarning("Unknown quiet hours time zone %q, using server time: %v", quiet.Timezone, err)
+		return time.Local
+	}
+	return location
+}
+
+// inQuietHours reports whether now falls within the quiet hours, which may
+// run past midnight
+func inQuietHours(quiet QuietHours, now time.Time) bool {
+	if !quiet.Enabled {
+		return false
+	}
+	start, startOK := parseClock(quiet.Start)
+	end, endOK := parseClock(quiet.End)
+	if !startOK || !endOK || start == end {
+		return false
+	}
+
+	local := now.In(quietHoursLocation(quiet))
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// activeMaintenance returns the window a site is in, if any
+func activeMaintenance(windows []MaintenanceWindow, now time.Time) (MaintenanceWindow, bool) {
+	for _, window := range windows {
+		if !now.Before(window.Start) && now.Before(window.End) {
+			return window, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// notificationHolds decides which notifications wait until later
+type notificationHolds struct {
+	quiet       QuietHours
+	now         time.Time
+	maintenance map[string][]MaintenanceWindow // by site URL
+	snoozed     map[string]time.Time           // by site URL
+}
+
+func newNotificationHolds(state NotificationState, settings Settings, now time.Time) notificationHolds {
+	holds := notificationHolds{
+		quiet:       settings.QuietHours,
+		now:         now,
+		maintenance: make(map[string][]MaintenanceWindow),
+		snoozed:     make(map[string]time.Time),
+	}
+	for url, history := range state.NotificationHistory {
+		if history.SnoozedUntil.After(now) {
+			holds.snoozed[url] = history.SnoozedUntil
+		}
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		LogWarning("Error loading sites for maintenance windows: %v", err)
+		return holds
+	}
+	for _, site := range sites {
+		if len(site.Maintenance) > 0 {
+			holds.maintenance[site.URL] = site.Maintenance
+		}
+	}
+	return holds
+}
+
+// reason says why a notification is held, or is empty if it can go out now
+func (h notificationHolds) reason(notification Notification) string {
+	url := notification.Result.URL
+	if until, snoozed := h.snoozed[url]; snoozed {
+		return "snoozed until " + until.Format("2006-01-02 15:04")
+	}
+	if window, found := activeMaintenance(h.maintenance[url], h.now); found {
+		return "maintenance until " + window.End.Format("2006-01-02 15:04")
+	}
+	if inQuietHours(h.quiet, h.now) && (notification.Severity != "critical" || h.quiet.HoldCritical) {
+		return "quiet hours"
+	}
+	return ""
+}
+
+// notifyOrHold sends a notification, or queues it in the state if it is held
+func notifyOrHold(state *NotificationState, holds notificationHolds, notification Notification, settings Settings) (int, map[string]string) {
+	if reason := holds.reason(notification); reason != "" {
+		LogInfo("Holding %s notification for %s (%s)", notification.Severity, notification.Result.URL, reason)
+		state.Held = append(state.Held, HeldNotification{Notification: notification, HeldAt: holds.now, Reason: reason})
+		return 0, nil
+	}
+	return notifyAllWithReferences(notification, settings)
+}
+
+// isStatusNotification reports whether a notification is about the site's
+// status, rather than a certificate change or failing nodes
+func isStatusNotification(notification Notification) bool {
+	return notification.Severity != "change" && !notification.NodeFailure
+}
+
+// staleHeldReason says why a held notification shouldn't be sent any more,
+// or is empty if it is still current. Only a site's last held alert or
+// resolution can be current: an alert while the site is still in its status,
+// and a resolution if the incident it ends was notified before the hold.
+// Certificate changes are always current, failing nodes while they still fail.
+func staleHeldReason(held []HeldNotification, i int, state NotificationState) string {
+	notification := held[i].Notification
+	url := notification.Result.URL
+	history, exists := state.NotificationHistory[url]
+	if notification.NodeFailure {
+		if history.NodeFailures == 0 {
+			return "its nodes have recovered"
+		}
+		return ""
+	}
+	if notification.Severity == "change" {
+		return ""
+	}
+	for _, later := range held[i+1:] {
+		if later.Notification.Result.URL == url && isStatusNotification(later.Notification) {
+			return "superseded by a later " + later.Notification.Severity + " notification"
+		}
+	}
+
+	status := "normal"
+	if exists {
+		status = history.LastStatus
+	}
+	if notification.Severity != "resolved" {
+		if status != notification.Severity {
+			return "site is now " + status
+		}
+		return ""
+	}
+	if isAlertStatus(status) {
+		return "site is now " + status
+	}
+
+	// Walk back to the incident's first alert. If it was held too, nobody
+	// was told about the incident and there's nothing to resolve.
+	for j := i - 1; j >= 0; j-- {
+		earlier := held[j].Notification
+		if earlier.Result.URL != url || !isStatusNotification(earlier) {
+			continue
+		}
+		if earlier.Severity == "resolved" {
+			break
+		}
+		if !isAlertStatus(earlier.PreviousStatus) {
+			return "the alert it resolves was never sent"
+		}
+	}
+	return ""
+}
+
+// processHeldNotifications delivers queued notifications whose hold has
+// ended, oldest first. Notifications the site's status has moved on from are
+// dropped rather than sent late.
+func processHeldNotifications(settings Settings, now time.Time) error {
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		return fmt.Errorf("error loading notification state: %w", err)
+	}
+	if len(state.Held) == 0 {
+		return nil
+	}
+
+	holds := newNotificationHolds(state, settings, now)
+	// Release to the team a site is routed to now, not when it was held
+	sites, err := loadSites()
+	if err != nil {
+		LogWarning("Error loading sites for held notification routes, using the routes they were held with: %v", err)
+	}
+	routes := siteRoutes(sites)
+	released := make(map[string]map[string]string) // references of alerts sent in this batch, by site
+	var stillHeld []HeldNotification
+	sent := 0
+
+	for i, held := range state.Held {
+		notification := held.Notification
+		if reason := holds.reason(notification); reason != "" {
+			held.Reason = reason
+			stillHeld = append(stillHeld, held)
+			continue
+		}
+
+		url := notification.Result.URL
+		if reason := staleHeldReason(state.Held, i, state); reason != "" {
+			LogInfo("Dropping held %s notification for %s, %s", notification.Severity, url, reason)
+			continue
+		}
+		if route, exists := routes[url]; exists {
+			notification.Result.Route = route
+		}
+
+		if notification.Severity == "resolved" && len(notification.AlertReferences) == 0 {
+			// The alert it resolves was held too, follow up on it now it's been sent
+			notification.AlertReferences = released[url]
+		}
+
+		LogInfo("Releasing %s notification for %s, held since %s", notification.Severity, url, held.HeldAt.Format("2006-01-02 15:04"))
+		count, references := notifyAllWithReferences(notification, settings)
+		sent += count
+		released[url] = mergeAlertReferences(released[url], references)
+
+		history, exists := state.NotificationHistory[url]
+		if exists && history.LastStatus == notification.Severity {
+			history.AlertReferences = mergeAlertReferences(history.AlertReferences, references)
+			history.LastNotified = now
+			state.NotificationHistory[url] = history
+		}
+	}
+
+	if len(stillHeld) == len(state.Held) {
+		return nil
+	}
+	state.Held = stillHeld
+
+	err = saveNotificationState(state)
+	if err != nil {
+		return fmt.Errorf("error saving notification state: %w", err)
+	}
+
+	LogInfo("Held notification processing complete. Sent %d notifications, %d still held", sent, len(stillHeld))
+	return nil
+}
+
+// runHeldNotifications releases held notifications until the process exits
+func runHeldNotifications(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settings, err := loadSettings()
+		if err != nil {
+			LogError("Error loading settings for held notifications: %v", err)
+			continue
+		}
+		err = processHeldNotifications(settings, time.Now())
+		if err != nil {
+			LogError("Error processing held notifications: %v", err)
+		}
+	}
+}
+
+// snoozeHandler snoozes a site's notifications until the posted time, or
+// ends the snooze when no time is given
+func snoozeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	url := r.FormValue("url")
+	if url == "" {
+		http.Error(w, "Missing site URL", http.StatusBadRequest)
+		return
+	}
+
+	var until time.Time
+	if value := r.FormValue("until"); value != "" {
+		var err error
+		until, err = time.ParseInLocation(datetimeLocalFormat, value, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid snooze time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		LogError("Error loading notification state for snooze: %v", err)
+		http.Error(w, "Error loading notification state", http.StatusInternalServerError)
+		return
+	}
+
+	history := state.NotificationHistory[url]
+	history.SnoozedUntil = until
+	state.NotificationHistory[url] = history
+
+	err = saveNotificationState(state)
+	if err != nil {
+		LogError("Error saving notification state for snooze: %v", err)
+		http.Error(w, "Error saving notification state", http.StatusInternalServerError)
+		return
+	}
+
+	if until.IsZero() {
+		LogInfo("Snooze ended for %s", url)
+	} else {
+		LogInfo("Notifications for %s snoozed until %s", url, until.Format("2006-01-02 15:04"))
+	}
+	http.Redirect(w, r, "/results", http.StatusSeeOther)
+}
+
+// parseQuietHoursForm copies the quiet hours fields from the settings form
+func parseQuietHoursForm(r *http.Request, settings *Settings) {
+	if _, ok := r.Form["quiet_hours_start"]; !ok {
+		return
+	}
+
+	quiet := &settings.QuietHours
+	quiet.Enabled = r.FormValue("quiet_hours_enabled") == "on"
+	quiet.HoldCritical = r.FormValue("quiet_hours_hold_critical") == "on"
+	if start := r.FormValue("quiet_hours_start"); start != "" {
+		if _, ok := parseClock(start); ok {
+			quiet.Start = strings.TrimSpace(start)
+		}
+	}
+	if end := r.FormValue("quiet_hours_end"); end != "" {
+		if _, ok := parseClock(end); ok {
+			quiet.End = strings.TrimSpace(end)
+		}
+	}
+
+	timezone := strings.TrimSpace(r.FormValue("quiet_hours_timezone"))
+	if _, err := time.LoadLocation(timezone); err != nil {
+		LogWarning("Ignoring unknown quiet hours time zone %q: %v", timezone, err)
+	} else {
+		quiet.Timezone = timezone
+	}
+
+	LogDebug("Updated quiet hours: enabled=%v, %s-%s %s", quiet.Enabled, quiet.Start, quiet.End, quiet.Timezone)
+}
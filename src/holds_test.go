@@ -0,0 +1,281 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	overnight := QuietHours{Enabled: true, Start: "22:00", End: "07:00", Timezone: "UTC"}
+	daytime := QuietHours{Enabled: true, Start: "12:00", End: "14:00", Timezone: "UTC"}
+	newYork := QuietHours{Enabled: true, Start: "22:00", End: "07:00", Timezone: "America/New_York"}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 6, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		settings QuietHours
+		now      time.Time
+		quiet    bool
+	}{
+		{"overnight before midnight", overnight, at(23, 30), true},
+		{"overnight after midnight", overnight, at(3, 0), true},
+		{"overnight end is exclusive", overnight, at(7, 0), false},
+		{"overnight daytime", overnight, at(12, 0), false},
+		{"same day window", daytime, at(13, 59), true},
+		{"same day outside", daytime, at(14, 0), false},
+		{"time zone", newYork, at(8, 0), true}, // 03:00 in New York
+		{"time zone daytime", newYork, at(15, 0), false},
+		{"disabled", QuietHours{Start: "00:00", End: "23:59"}, at(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inQuietHours(tt.settings, tt.now); got != tt.quiet {
+				t.Errorf("Expected %v, got %v", tt.quiet, got)
+			}
+		})
+	}
+}
+
+// quietNow returns quiet hours covering the current time
+func quietNow() QuietHours {
+	now := time.Now().UTC()
+	return QuietHours{
+		Enabled:  true,
+		Start:    now.Add(-time.Hour).Format("15:04"),
+		End:      now.Add(time.Hour).Format("15:04"),
+		Timezone: "UTC",
+	}
+}
+
+func TestProcessNotificationsHoldsDuringQuietHours(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	threaded := threadedFakeNotifier{&fakeNotifier{name: "threaded", enabled: map[string]bool{"warning": true, "critical": true}}}
+	useFakeNotifiers(t)
+	registerNotifier(threaded)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	settings.QuietHours = quietNow()
+
+	results := ScanResults{LastScan: time.Now(), Results: []CertResult{
+		{URL: "warning.example.com", DaysLeft: 20},
+		{URL: "critical.example.com", DaysLeft: 3},
+	}}
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+
+	if len(threaded.received) != 1 || threaded.received[0].Result.URL != "critical.example.com" {
+		t.Fatalf("Expected only the critical alert during quiet hours, got %+v", threaded.received)
+	}
+	state, _ := loadNotificationState()
+	if len(state.Held) != 1 || state.Held[0].Notification.Result.URL != "warning.example.com" || state.Held[0].Reason != "quiet hours" {
+		t.Fatalf("Expected the warning to be held, got %+v", state.Held)
+	}
+
+	// Nothing is released while quiet hours last
+	if err := processHeldNotifications(settings, time.Now()); err != nil {
+		t.Fatalf("processHeldNotifications returned error: %v", err)
+	}
+	if len(threaded.received) != 1 {
+		t.Fatalf("Expected the warning to stay held, got %d notifications", len(threaded.received))
+	}
+
+	settings.QuietHours.Enabled = false
+	if err := processHeldNotifications(settings, time.Now()); err != nil {
+		t.Fatalf("processHeldNotifications returned error: %v", err)
+	}
+	if len(threaded.received) != 2 || threaded.received[1].Severity != "warning" {
+		t.Fatalf("Expected the held warning to be sent, got %+v", threaded.received)
+	}
+
+	state, _ = loadNotificationState()
+	if len(state.Held) != 0 {
+		t.Errorf("Expected the queue to be empty, got %+v", state.Held)
+	}
+	if state.NotificationHistory["warning.example.com"].AlertReferences["threaded"] != "ref-2" {
+		t.Errorf("Expected the released alert to be referenced, got %+v", state.NotificationHistory["warning.example.com"])
+	}
+}
+
+func TestHeldWarningDroppedAfterCritical(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"warning": true, "critical": true, "resolved": true}}
+	useFakeNotifiers(t, fake)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	settings.QuietHours = quietNow()
+
+	scan := func(url string, daysLeft int) {
+		t.Helper()
+		results := ScanResults{LastScan: time.Now(), Results: []CertResult{{URL: url, DaysLeft: daysLeft}}}
+		if err := processNotifications(results, settings); err != nil {
+			t.Fatalf("processNotifications returned error: %v", err)
+		}
+	}
+
+	// The warning is held overnight, then the critical alert goes straight out
+	scan("example.com", 20)
+	scan("example.com", 3)
+	// A whole incident inside quiet hours
+	scan("blip.example.com", 20)
+	scan("blip.example.com", 60)
+	if len(fake.received) != 1 || fake.received[0].Severity != "critical" {
+		t.Fatalf("Expected only the critical alert during quiet hours, got %+v", fake.received)
+	}
+
+	settings.QuietHours.Enabled = false
+	if err := processHeldNotifications(settings, time.Now()); err != nil {
+		t.Fatalf("processHeldNotifications returned error: %v", err)
+	}
+	if len(fake.received) != 1 {
+		t.Errorf("Expected the stale notifications to be dropped, got %+v", fake.received[1:])
+	}
+	if state, _ := loadNotificationState(); len(state.Held) != 0 {
+		t.Errorf("Expected the queue to be empty, got %+v", state.Held)
+	}
+}
+
+func TestHeldNotificationsUseCurrentRoute(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	platform := &fakeNotifier{name: "platform", enabled: map[string]bool{"warning": true}}
+	marketing := &fakeNotifier{name: "marketing", enabled: map[string]bool{"warning": true}}
+	useFakeNotifiers(t, platform, marketing)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	settings.QuietHours = quietNow()
+	settings.Routes = []NotificationRoute{
+		{Name: "platform", Channels: []string{"platform"}},
+		{Name: "marketing", Channels: []string{"marketing"}},
+	}
+
+	results := ScanResults{LastScan: time.Now(), Results: []CertResult{{URL: "example.com", DaysLeft: 20, Route: "platform"}}}
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+
+	// The site moves to another team while its warning is held
+	if err := saveSites([]Site{{URL: "example.com", Enabled: true, Route: "marketing"}}); err != nil {
+		t.Fatalf("Error saving sites: %v", err)
+	}
+	settings.QuietHours.Enabled = false
+	if err := processHeldNotifications(settings, time.Now()); err != nil {
+		t.Fatalf("processHeldNotifications returned error: %v", err)
+	}
+	if len(platform.received) != 0 || len(marketing.received) != 1 {
+		t.Errorf("Expected the warning released to the new route, got %d platform and %d marketing", len(platform.received), len(marketing.received))
+	}
+}
+
+func TestStaleHeldReason(t *testing.T) {
+	held := func(url, severity, previous string) HeldNotification {
+		return HeldNotification{Notification: Notification{Severity: severity, PreviousStatus: previous, Result: CertResult{URL: url}}}
+	}
+	state := NotificationState{NotificationHistory: map[string]NotificationHistory{
+		"warned.example.com":   {LastStatus: "normal"},
+		"critical.example.com": {LastStatus: "critical"},
+	}}
+
+	queue := []HeldNotification{
+		held("critical.example.com", "warning", "normal"),
+		held("critical.example.com", "change", ""),
+		held("critical.example.com", "critical", "warning"),
+		// The warning was sent before the hold, only the critical alert was held
+		held("warned.example.com", "critical", "warning"),
+		held("warned.example.com", "resolved", "critical"),
+	}
+	want := []string{
+		"superseded by a later critical notification",
+		"",
+		"",
+		"superseded by a later resolved notification",
+		"",
+	}
+	for i := range queue {
+		if got := staleHeldReason(queue, i, state); got != want[i] {
+			t.Errorf("Notification %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestSnoozeAndMaintenanceHoldNotifications(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"critical": true, "change": true}}
+	useFakeNotifiers(t, fake)
+
+	now := time.Now()
+	err := saveSites([]Site{
+		{URL: "migrating.example.com", Enabled: true, Maintenance: []MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}},
+		{URL: "snoozed.example.com", Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("Error saving sites: %v", err)
+	}
+
+	form := url.Values{"url": {"snoozed.example.com"}, "until": {now.Add(2 * time.Hour).Format(datetimeLocalFormat)}}
+	r := httptest.NewRequest("POST", "/results/snooze", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	snoozeHandler(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected a redirect, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	results := ScanResults{LastScan: now, Results: []CertResult{
+		{URL: "migrating.example.com", DaysLeft: 3},
+		{URL: "snoozed.example.com", DaysLeft: 3},
+	}}
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+	processChangeNotifications([]CertChange{{URL: "migrating.example.com", Kind: changeIssuerChanged, Unexpected: true}}, results, settings)
+
+	if len(fake.received) != 0 {
+		t.Fatalf("Expected everything to be held, got %+v", fake.received)
+	}
+	state, _ := loadNotificationState()
+	var reasons []string
+	for _, held := range state.Held {
+		reasons = append(reasons, held.Notification.Severity+" "+strings.Fields(held.Reason)[0])
+	}
+	if strings.Join(reasons, ", ") != "critical maintenance, critical snoozed, change maintenance" {
+		t.Errorf("Unexpected held notifications %v", reasons)
+	}
+
+	// Once both holds have ended, the queue is delivered in order
+	if err := processHeldNotifications(settings, now.Add(3*time.Hour)); err != nil {
+		t.Fatalf("processHeldNotifications returned error: %v", err)
+	}
+	if len(fake.received) != 3 || fake.received[2].Change == nil {
+		t.Errorf("Expected all three notifications after the holds, got %+v", fake.received)
+	}
+}
@@ -15,6 +15,7 @@ func runScanWithNotifications(sites []Site) {
 
 func runScanWithNotificationsMode(sites []Site, notificationsOnly bool) {
 	var results ScanResults
+	var changes []CertChange
 
 	if notificationsOnly {
 		LogInfo("Processing notifications with existing certificate data")
@@ -28,12 +29,20 @@ func runScanWithNotificationsMode(sites []Site, notificationsOnly bool) {
 		// Use existing results but update the scan time to trigger notification processing
 		results = existingResults
 		results.LastScan = time.Now()
+		applySiteRoutes(&results, sites)
 
 		LogInfo("Processing notifications for %d existing certificate results", len(results.Results))
 	} else {
 		// Full scan with certificate checking
 		LogDebug("Starting full certificate scan")
-		results = scanAllSites(sites)
+		options := defaultScanOptions()
+		scanSettings, settingsErr := loadSettings()
+		if settingsErr != nil {
+			LogWarning("Error loading settings for scan options, using defaults: %v", settingsErr)
+		} else {
+			options = scanOptionsFromSettings(scanSettings)
+		}
+		results = scanAllSitesWithOptions(sites, options)
 
 		err := saveResults(results)
 		if err != nil {
@@ -41,6 +50,24 @@ func runScanWithNotificationsMode(sites []Site, notificationsOnly bool) {
 		} else {
 			LogInfo("Scan complete. Checked %d sites", len(results.Results))
 		}
+
+		retentionDays := defaultHistoryRetentionDays
+		if settingsErr == nil {
+			retentionDays = historyRetentionDays(scanSettings)
+		}
+		err = recordScanHistory(results, retentionDays)
+		if err != nil {
+			LogError("Error recording scan history: %v", err)
+		}
+		err = pruneDeliveries(results.LastScan.AddDate(0, 0, -retentionDays))
+		if err != nil {
+			LogError("Error pruning delivery log: %v", err)
+		}
+
+		changes, err = detectCertificateChanges(results, retentionDays)
+		if err != nil {
+			LogError("Error detecting certificate changes: %v", err)
+		}
 	}
 
 	// Process notifications after scan (or using existing data)
@@ -52,6 +79,7 @@ func runScanWithNotificationsMode(sites []Site, notificationsOnly bool) {
 		if err != nil {
 			LogError("Error processing notifications: %v", err)
 		}
+		processChangeNotifications(changes, results, settings)
 	}
 }
 
@@ -95,6 +123,10 @@ func main() {
 
 	// Start scheduled scanning with configurable interval
 	go runScheduledScans(sites, time.Duration(settings.ScanIntervalHours)*time.Hour)
+	go runReminderChecks(reminderCheckInterval)
+	go runDigestSchedule(digestCheckInterval)
+	go runHeldNotifications(heldCheckInterval)
+	go runOutbox(outboxCheckInterval)
 
 	// Routes
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -103,9 +135,19 @@ func main() {
 	http.HandleFunc("/settings", settingsHandler)
 	http.HandleFunc("/sites", sitesHandler)
 	http.HandleFunc("/status", statusHandler)
+	http.HandleFunc("/metrics", metricsHandler)
 	http.HandleFunc("/results", resultsHandler)
-	http.HandleFunc("/test-email", testEmailHandler)
-	http.HandleFunc("/test-ntfy", testNtfyHandler)
+	http.HandleFunc("/results/site", resultDetailHandler)
+	http.HandleFunc("/results/history", historyHandler)
+	http.HandleFunc("/results/snooze", snoozeHandler)
+	http.HandleFunc("/results/acknowledge", acknowledgeHandler)
+	http.HandleFunc("/test-notification", testNotificationHandler)
+	http.HandleFunc("/send-digest", sendDigestHandler)
+	http.HandleFunc("/outbox", outboxHandler)
+	http.HandleFunc("/notifications", deliveriesHandler)
+	http.HandleFunc("/notifications.json", deliveriesJSONHandler)
+	http.HandleFunc("/templates/default", defaultTemplateHandler)
+	http.HandleFunc("/templates/preview", previewTemplateHandler)
 
 	port := fmt.Sprintf(":%d", settings.Dashboard.Port)
 	LogInfo("Starting web server on %s", port)
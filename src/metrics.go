@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification send counters, reset when the process restarts as Prometheus expects
+type notificationCounterKey struct {
+	Channel string
+	Result  string // "success" or "failure"
+}
+
+var (
+	notificationCounters      = make(map[notificationCounterKey]int)
+	notificationCountersMutex sync.Mutex
+)
+
+func recordNotificationSend(channel string, err error) {
+	key := notificationCounterKey{Channel: channel, Result: "success"}
+	if err != nil {
+		key.Result = "failure"
+	}
+
+	notificationCountersMutex.Lock()
+	notificationCounters[key]++
+	notificationCountersMutex.Unlock()
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func siteLabels(result CertResult) string {
+	return fmt.Sprintf(`name="%s",url="%s"`, escapeLabelValue(result.Name), escapeLabelValue(result.URL))
+}
+
+func writeMetricHeader(w io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writeMetrics renders the scan results and notification counters in the
+// Prometheus text exposition format
+func writeMetrics(w io.Writer, results ScanResults, now time.Time) {
+	writeMetricHeader(w, "ssl_monitor_certificate_expiry_seconds", "gauge",
+		"Seconds until the earliest certificate in the served chain expires.")
+	for _, result := range results.Results {
+		if result.Error != "" {
+			continue
+		}
+		fmt.Fprintf(w, "ssl_monitor_certificate_expiry_seconds{%s} %.0f\n", siteLabels(result), result.ExpiryDate.Sub(now).Seconds())
+	}
+
+	writeMetricHeader(w, "ssl_monitor_scan_success", "gauge",
+		"Whether the last check of the site read a certificate (1) or failed (0).")
+	for _, result := range results.Results {
+		success := 1
+		if result.Error != "" {
+			success = 0
+		}
+		fmt.Fprintf(w, "ssl_monitor_scan_success{%s} %d\n", siteLabels(result), success)
+	}
+
+	writeMetricHeader(w, "ssl_monitor_handshake_duration_seconds", "gauge",
+		"Time taken to connect and complete the TLS handshake on the last check.")
+	for _, result := range results.Results {
+		if result.HandshakeMs == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "ssl_monitor_handshake_duration_seconds{%s} %.3f\n", siteLabels(result), float64(result.HandshakeMs)/1000)
+	}
+
+	if !results.LastScan.IsZero() {
+		writeMetricHeader(w, "ssl_monitor_last_scan_timestamp_seconds", "gauge",
+			"Unix time the last full scan started.")
+		fmt.Fprintf(w, "ssl_monitor_last_scan_timestamp_seconds %d\n", results.LastScan.Unix())
+
+		writeMetricHeader(w, "ssl_monitor_scan_duration_seconds", "gauge",
+			"How long the last full scan took.")
+		fmt.Fprintf(w, "ssl_monitor_scan_duration_seconds %.3f\n", float64(results.DurationMs)/1000)
+	}
+
+	notificationCountersMutex.Lock()
+	keys := make([]notificationCounterKey, 0, len(notificationCounters))
+	counts := make(map[notificationCounterKey]int, len(notificationCounters))
+	for key, count := range notificationCounters {
+		keys = append(keys, key)
+		counts[key] = count
+	}
+	notificationCountersMutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Channel != keys[j].Channel {
+			return keys[i].Channel < keys[j].Channel
+		}
+		return keys[i].Result < keys[j].Result
+	})
+
+	writeMetricHeader(w, "ssl_monitor_notifications_sent_total", "counter",
+		"Notifications sent since startup, by channel and result.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "ssl_monitor_notifications_sent_total{channel=\"%s\",result=\"%s\"} %d\n",
+			escapeLabelValue(key.Channel), key.Result, counts[key])
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	results, err := loadResults()
+	if os.IsNotExist(err) {
+		// No scan has finished yet, still serve the counters
+		err = nil
+	}
+	if err != nil {
+		LogError("Error loading results for metrics: %v", err)
+		http.Error(w, "Error loading results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, results, time.Now())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	notificationCountersMutex.Lock()
+	originalCounters := notificationCounters
+	notificationCounters = make(map[notificationCounterKey]int)
+	notificationCountersMutex.Unlock()
+	defer func() {
+		notificationCountersMutex.Lock()
+		notificationCounters = originalCounters
+		notificationCountersMutex.Unlock()
+	}()
+
+	recordNotificationSend("email", nil)
+	recordNotificationSend("email", nil)
+	recordNotificationSend("ntfy", errors.New("timeout"))
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	results := ScanResults{
+		LastScan:   now.Add(-time.Hour),
+		DurationMs: 2500,
+		Results: []CertResult{
+			{URL: "example.com", Name: "Example", ExpiryDate: now.Add(48 * time.Hour), HandshakeMs: 120},
+			{URL: "broken.example.com", Name: `Broken "test"`, Error: "connection refused"},
+		},
+	}
+
+	var output strings.Builder
+	writeMetrics(&output, results, now)
+	metrics := output.String()
+
+	expected := []string{
+		"# TYPE ssl_monitor_certificate_expiry_seconds gauge",
+		`ssl_monitor_certificate_expiry_seconds{name="Example",url="example.com"} 172800`,
+		`ssl_monitor_scan_success{name="Example",url="example.com"} 1`,
+		`ssl_monitor_scan_success{name="Broken \"test\"",url="broken.example.com"} 0`,
+		`ssl_monitor_handshake_duration_seconds{name="Example",url="example.com"} 0.120`,
+		"ssl_monitor_last_scan_timestamp_seconds 1748775600",
+		"ssl_monitor_scan_duration_seconds 2.500",
+		"# TYPE ssl_monitor_notifications_sent_total counter",
+		`ssl_monitor_notifications_sent_total{channel="email",result="success"} 2`,
+		`ssl_monitor_notifications_sent_total{channel="ntfy",result="failure"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("Expected metrics to contain %q\n%s", line, metrics)
+		}
+	}
+
+	if strings.Contains(metrics, `ssl_monitor_certificate_expiry_seconds{name="Broken`) {
+		t.Error("Failed sites should not report an expiry")
+	}
+}
+
+func TestMetricsHandler_NoResults(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	metricsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 before the first scan, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+	if strings.Contains(w.Body.String(), "ssl_monitor_last_scan_timestamp_seconds ") {
+		t.Error("Expected no last scan timestamp before the first scan")
+	}
+}
@@ -5,17 +5,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
+// defaultScanErrorThreshold is how many scans in a row must fail before an
+// error alert, unless the settings say otherwise
+const defaultScanErrorThreshold = 3
+
 type NotificationHistory struct {
-	LastStatus string    `json:"last_status"` // "normal", "warning", "critical"
-	LastScan   time.Time `json:"last_scan"`
+	LastStatus          string            `json:"last_status"` // "normal", "warning", "critical", "error"
+	LastScan            time.Time         `json:"last_scan"`
+	LastCheck           time.Time         `json:"last_check,omitempty"`           // check time of the last result processed
+	AlertReferences     map[string]string `json:"alert_references,omitempty"`     // first alert of the current incident, by channel
+	ConsecutiveFailures int               `json:"consecutive_failures,omitempty"` // failed scans in a row, reset by a successful one
+	NodeFailures        int               `json:"node_failures,omitempty"`        // scans in a row where some of the site's nodes failed
+	LastError           string            `json:"last_error,omitempty"`
+	LastNotified        time.Time         `json:"last_notified,omitempty"` // last alert or reminder for the current status
+	Reminders           int               `json:"reminders,omitempty"`     // reminders sent for the current status
+	Escalated           bool              `json:"escalated,omitempty"`
+	SnoozedUntil        time.Time         `json:"snoozed_until,omitempty"`   // notifications are held until then
+	Acknowledgement     *Acknowledgement  `json:"acknowledgement,omitempty"` // someone is handling the current alert
 }
 
+// notificationStateMutex serializes scans and reminder checks, which both
+// rewrite the notification state
+var notificationStateMutex sync.Mutex
+
 type NotificationState struct {
 	LastNotificationScan time.Time                      `json:"last_notification_scan"`
 	NotificationHistory  map[string]NotificationHistory `json:"notification_history"`
+	LastDigest           time.Time                      `json:"last_digest,omitempty"`
+	Held                 []HeldNotification             `json:"held,omitempty"` // notifications waiting for quiet hours, maintenance or a snooze to end
 }
 
 func getNotificationFilePath() string {
@@ -63,31 +84,47 @@ func determineCurrentStatus(daysLeft int, settings Settings) string {
 	}
 }
 
-func shouldSendEmailForStatus(status string, settings Settings) bool {
-	switch status {
-	case "warning":
-		return settings.Notifications.Email.EnabledWarning
-	case "critical":
-		return settings.Notifications.Email.EnabledCritical
-	default:
-		return false
+func scanErrorThreshold(settings Settings) int {
+	if settings.ScanErrorThreshold > 0 {
+		return settings.ScanErrorThreshold
 	}
+	return defaultScanErrorThreshold
+}
+
+// isAlertStatus reports whether a site status is one that was notified
+func isAlertStatus(status string) bool {
+	return status == "warning" || status == "critical" || status == "error"
 }
 
-func shouldSendNtfyForStatus(status string, settings Settings) bool {
-	switch status {
-	case "warning":
-		return settings.Notifications.Ntfy.EnabledWarning
-	case "critical":
-		return settings.Notifications.Ntfy.EnabledCritical
-	default:
-		return false
+// resolvedDescription says how a site got back to normal from an alert status,
+// completing "The certificate for example.com ..."
+func resolvedDescription(previousStatus string) string {
+	if previousStatus == "error" {
+		return "can be checked again after failed scans"
 	}
+	return fmt.Sprintf("has been renewed and is no longer %s", previousStatus)
+}
+
+// mergeAlertReferences keeps the incident's first alert as the one followups
+// refer to, only adding references for channels that didn't have one yet
+func mergeAlertReferences(references map[string]string, sent map[string]string) map[string]string {
+	for channel, reference := range sent {
+		if references == nil {
+			references = make(map[string]string)
+		}
+		if _, exists := references[channel]; !exists {
+			references[channel] = reference
+		}
+	}
+	return references
 }
 
 func processNotifications(results ScanResults, settings Settings) error {
 	LogInfo("Processing notifications for %d scan results", len(results.Results))
-	
+
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
 	state, err := loadNotificationState()
 	if err != nil {
 		return fmt.Errorf("error loading notification state: %w", err)
@@ -95,15 +132,10 @@ func processNotifications(results ScanResults, settings Settings) error {
 
 	notificationsSent := 0
 
-	for _, result := range results.Results {
-		if result.Error != "" {
-			LogWarning("Skipping %s due to scan error: %s", result.URL, result.Error)
-			continue
-		}
-
-		currentStatus := determineCurrentStatus(result.DaysLeft, settings)
-		LogDebug("Site %s (%d days left) current status: %s", result.URL, result.DaysLeft, currentStatus)
+	threshold := scanErrorThreshold(settings)
+	sender := newScanNotifier(&state, newNotificationHolds(state, settings, time.Now()), settings)
 
+	for _, result := range results.Results {
 		// Get previous status from history
 		history, exists := state.NotificationHistory[result.URL]
 		previousStatus := "normal" // default for new sites
@@ -111,46 +143,116 @@ func processNotifications(results ScanResults, settings Settings) error {
 			previousStatus = history.LastStatus
 		}
 
+		// Replaying the stored results after a settings change gets a new
+		// scan time but keeps each result's check time
+		freshCheck := history.LastCheck.IsZero() || result.LastCheck.After(history.LastCheck)
+		history.LastCheck = result.LastCheck
+
+		var currentStatus string
+		if result.Error != "" {
+			// A replayed failure isn't another failed scan
+			if freshCheck {
+				history.ConsecutiveFailures++
+				history.LastError = result.Error
+			}
+			if history.ConsecutiveFailures < threshold {
+				// A single failed scan is often a blip, keep the last known status
+				LogWarning("Scan of %s failed (%d of %d before alerting): %s", result.URL, history.ConsecutiveFailures, threshold, result.Error)
+				history.LastStatus = previousStatus
+				history.LastScan = results.LastScan
+				state.NotificationHistory[result.URL] = history
+				continue
+			}
+			currentStatus = "error"
+			LogDebug("Site %s has failed %d scans in a row, current status: error", result.URL, history.ConsecutiveFailures)
+		} else {
+			history.ConsecutiveFailures = 0
+			history.LastError = ""
+			currentStatus = determineCurrentStatus(result.DaysLeft, settings)
+			LogDebug("Site %s (%d days left) current status: %s", result.URL, result.DaysLeft, currentStatus)
+		}
+
 		LogDebug("Site %s status change: %s -> %s", result.URL, previousStatus, currentStatus)
 
+		references := history.AlertReferences
+
 		// Only send notifications if status changed and new status needs notifications
-		if currentStatus != previousStatus && (currentStatus == "warning" || currentStatus == "critical") {
+		if currentStatus != previousStatus && isAlertStatus(currentStatus) {
 			LogInfo("Status changed to %s for %s, checking enabled services", currentStatus, result.URL)
 
-			if shouldSendEmailForStatus(currentStatus, settings) {
-				LogInfo("Sending email notification for %s (status: %s)", result.URL, currentStatus)
-				err := sendEmailNotification(result, currentStatus, settings)
-				if err != nil {
-					LogError("Error sending email notification for %s: %v", result.URL, err)
-				} else {
-					notificationsSent++
-					LogInfo("Successfully sent email notification for %s", result.URL)
-				}
-			}
+			sent, sentReferences := sender.notify(Notification{
+				Severity:        currentStatus,
+				PreviousStatus:  previousStatus,
+				Result:          result,
+				Failures:        history.ConsecutiveFailures,
+				AlertReferences: references,
+			})
+			notificationsSent += sent
+			references = mergeAlertReferences(references, sentReferences)
 
-			if shouldSendNtfyForStatus(currentStatus, settings) {
-				LogInfo("Sending NTFY notification for %s (status: %s)", result.URL, currentStatus)
-				err := sendNtfyNotification(result, currentStatus, settings)
-				if err != nil {
-					LogError("Error sending NTFY notification for %s: %v", result.URL, err)
-				} else {
-					notificationsSent++
-					LogInfo("Successfully sent NTFY notification for %s", result.URL)
-				}
-			}
+			history.LastNotified = time.Now()
+			history.Reminders = 0
+			history.Escalated = false
+		} else if currentStatus == "normal" && isAlertStatus(previousStatus) {
+			LogInfo("Status recovered from %s for %s, checking enabled services", previousStatus, result.URL)
+
+			sent, _ := sender.notify(Notification{
+				Severity:        "resolved",
+				PreviousStatus:  previousStatus,
+				Result:          result,
+				AlertReferences: references,
+			})
+			notificationsSent += sent
+			references = nil
+
+			history.LastNotified = time.Time{}
+			history.Reminders = 0
+			history.Escalated = false
 		} else if currentStatus == previousStatus {
 			LogDebug("No status change for %s, skipping notifications", result.URL)
 		} else {
 			LogDebug("Status changed to %s for %s, but no notifications needed", result.URL, currentStatus)
 		}
 
-		// Update history with current status
-		state.NotificationHistory[result.URL] = NotificationHistory{
-			LastStatus: currentStatus,
-			LastScan:   results.LastScan,
+		// Failing nodes behind a site that still answers alert once they've
+		// failed as many scans as a whole site would, without changing its
+		// status, so its expiry alerts carry on
+		if result.FailedAddresses > 0 {
+			if freshCheck {
+				history.NodeFailures++
+				if history.NodeFailures == threshold {
+					failing := result
+					failing.Error = nodeFailureSummary(result)
+					LogInfo("Nodes behind %s have failed %d scans in a row, checking enabled services", result.URL, history.NodeFailures)
+					sent, _ := sender.notify(Notification{
+						Severity:       "error",
+						PreviousStatus: currentStatus,
+						Result:         failing,
+						Failures:       history.NodeFailures,
+						NodeFailure:    true,
+					})
+					notificationsSent += sent
+				}
+			}
+		} else if result.Error == "" {
+			history.NodeFailures = 0
 		}
+
+		// An acknowledgement only covers the status it was given for
+		if currentStatus != previousStatus && history.Acknowledgement != nil {
+			LogInfo("Clearing acknowledgement for %s after status change to %s", result.URL, currentStatus)
+			history.Acknowledgement = nil
+		}
+
+		// Update history with current status
+		history.LastStatus = currentStatus
+		history.LastScan = results.LastScan
+		history.AlertReferences = references
+		state.NotificationHistory[result.URL] = history
 	}
 
+	notificationsSent += sender.flush()
+
 	// Update last scan time
 	state.LastNotificationScan = results.LastScan
 
@@ -163,3 +265,50 @@ func processNotifications(results ScanResults, settings Settings) error {
 	LogInfo("Notification processing complete. Sent %d notifications", notificationsSent)
 	return nil
 }
+
+// processChangeNotifications sends alerts for unexpected certificate changes.
+// Routine renewals are recorded but don't notify.
+func processChangeNotifications(changes []CertChange, results ScanResults, settings Settings) {
+	routes := make(map[string]string, len(results.Results))
+	for _, result := range results.Results {
+		routes[result.URL] = result.Route
+	}
+
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		LogError("Error loading notification state for certificate changes: %v", err)
+		return
+	}
+	sender := newScanNotifier(&state, newNotificationHolds(state, settings, time.Now()), settings)
+	heldBefore := len(state.Held)
+
+	notificationsSent := 0
+
+	for _, change := range changes {
+		if !change.Unexpected {
+			LogDebug("Certificate for %s %s, no notification needed", change.URL, change.Kind)
+			continue
+		}
+
+		sent, _ := sender.notify(Notification{
+			Severity: "change",
+			Result:   CertResult{URL: change.URL, Name: change.Name, Route: routes[change.URL]},
+			Change:   &change,
+		})
+		notificationsSent += sent
+	}
+	notificationsSent += sender.flush()
+
+	if len(state.Held) > heldBefore {
+		if err := saveNotificationState(state); err != nil {
+			LogError("Error saving held certificate change notifications: %v", err)
+		}
+	}
+
+	if len(changes) > 0 {
+		LogInfo("Certificate change processing complete. Sent %d notifications", notificationsSent)
+	}
+}
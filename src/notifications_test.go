@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,3 +66,46 @@ func TestSaveAndLoadNotificationState(t *testing.T) {
 		t.Errorf("Saved and loaded state do not match.\nSaved: %s\nLoaded: %s", orig, reloaded)
 	}
 }
+
+func TestNtfyResolvedMessage(t *testing.T) {
+	result := CertResult{
+		URL:        "example.com",
+		Name:       "Example",
+		DaysLeft:   89,
+		ExpiryDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	resolved := Notification{
+		Severity:        "resolved",
+		PreviousStatus:  "critical",
+		Result:          result,
+		AlertReferences: map[string]string{"ntfy": "abc123"},
+	}
+	title, message, _, tags := ntfyMessage(resolved, Settings{})
+	if title != "SSL Resolved: Example" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	for _, expected := range []string{"89 days (2025-04-01)", `Resolves "🚨 SSL Critical: Example"`, "(message abc123)"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected message to contain %q, got %q", expected, message)
+		}
+	}
+	if !strings.Contains(tags, "white_check_mark") {
+		t.Errorf("Unexpected tags %q", tags)
+	}
+}
+
+func TestPostNtfyReturnsMessageID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"hwQ2YpKdmg","event":"message","topic":"test"}`))
+	}))
+	defer server.Close()
+
+	id, status, err := postNtfy(server.URL+"/test", "Title", "Message", "default", "ssl-monitor")
+	if err != nil {
+		t.Fatalf("postNtfy returned error: %v", err)
+	}
+	if id != "hwQ2YpKdmg" || status != http.StatusOK {
+		t.Errorf("Expected message ID hwQ2YpKdmg and status 200, got %q and %d", id, status)
+	}
+}
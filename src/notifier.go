@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errIncompleteSettings is returned when a channel is missing required settings
+var errIncompleteSettings = errors.New("settings incomplete")
+
+// statusCodeError is returned when a channel's server answers with an
+// unsuccessful HTTP status, which the delivery log records
+type statusCodeError struct {
+	service    string
+	StatusCode int
+}
+
+func (e *statusCodeError) Error() string {
+	return fmt.Sprintf("%s returned status code: %d", e.service, e.StatusCode)
+}
+
+// Notification is one alert about a site, handed to each enabled notifier
+type Notification struct {
+	Severity       string      `json:"severity"`                  // "warning", "critical", "error", "change" or "resolved"
+	PreviousStatus string      `json:"previous_status,omitempty"` // status before this scan, for everything but "change"
+	Result         CertResult  `json:"result"`                    // latest scan result for the site
+	Change         *CertChange `json:"change,omitempty"`          // the certificate change, for "change"
+	Failures       int         `json:"failures,omitempty"`        // consecutive failed scans, for "error"
+	NodeFailure    bool        `json:"node_failure,omitempty"`    // for "error", only some of the site's nodes failed
+	Reminder       int         `json:"reminder,omitempty"`        // reminder number while the status persists, 0 for the first alert
+	Escalated      bool        `json:"escalated,omitempty"`       // reminder sent after the escalation threshold
+	// AlertReferences identify the first alert of the current incident by
+	// channel name, so threaded channels can follow up on it
+	AlertReferences map[string]string `json:"alert_references,omitempty"`
+}
+
+// notificationSeverities are the severities channels can be enabled for
+var notificationSeverities = []string{"warning", "critical", "error", "change", "resolved"}
+
+// Notifier is a notification channel. Each channel keeps its own block under
+// Settings.Notifications, reads its own form fields and decides for itself
+// which severities it sends.
+type Notifier interface {
+	// Name is the key used in form fields, the test endpoint and metrics
+	Name() string
+	// Label is the name shown to users
+	Label() string
+	Enabled(settings Settings, severity string) bool
+	// Send returns the HTTP or SMTP status the channel's server answered
+	// with, 0 if it didn't answer
+	Send(notification Notification, settings Settings) (int, error)
+	// Test sends a test message, returning errIncompleteSettings if the channel isn't configured
+	Test(settings Settings) error
+	// ParseForm copies the channel's fields from the settings form
+	ParseForm(r *http.Request, settings *Settings)
+}
+
+// threadedNotifier is implemented by channels whose messages can refer back
+// to an earlier one, such as email replies
+type threadedNotifier interface {
+	// SendThreaded sends like Send and returns a reference to the message,
+	// which comes back in Notification.AlertReferences for follow-ups
+	SendThreaded(notification Notification, settings Settings) (string, int, error)
+}
+
+// notifiers holds every channel, in the order they are tried
+var notifiers []Notifier
+
+func registerNotifier(notifier Notifier) {
+	notifiers = append(notifiers, notifier)
+}
+
+func findNotifier(name string) (Notifier, bool) {
+	for _, notifier := range notifiers {
+		if notifier.Name() == name {
+			return notifier, true
+		}
+	}
+	return nil, false
+}
+
+// parseChannelList reads a comma separated list of channel names from a form,
+// dropping any that aren't registered
+func parseChannelList(text string) []string {
+	var channels []string
+	for _, channel := range strings.Split(text, ",") {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if _, found := findNotifier(channel); found {
+			channels = append(channels, channel)
+		} else if channel != "" {
+			LogWarning("Ignoring unknown notification channel %q", channel)
+		}
+	}
+	return channels
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// severityEnabled maps a severity onto a channel's per-severity toggles
+func severityEnabled(severity string, warning bool, critical bool, change bool, resolved bool, scanError bool) bool {
+	switch severity {
+	case "warning":
+		return warning
+	case "critical":
+		return critical
+	case "change":
+		return change
+	case "resolved":
+		return resolved
+	case "error":
+		return scanError
+	default:
+		return false
+	}
+}
+
+// deliverNotification sends through one notifier and records the outcome in
+// the metrics and the delivery log. Attempt is 1 for the first send and
+// counts up as the outbox retries. The message reference is only set for
+// threaded channels.
+func deliverNotification(notifier Notifier, notification Notification, settings Settings, attempt int) (string, error) {
+	LogInfo("Sending %s notification for %s (%s)", notifier.Label(), notification.Result.URL, notification.Severity)
+
+	var reference string
+	var status int
+	var err error
+	if threaded, ok := notifier.(threadedNotifier); ok {
+		reference, status, err = threaded.SendThreaded(notification, settings)
+	} else {
+		status, err = notifier.Send(notification, settings)
+	}
+	recordNotificationSend(notifier.Name(), err)
+	recordDelivery(deliveryRecordFor(notifier, notification, settings, attempt, status, err))
+	if err != nil {
+		LogError("Error sending %s notification for %s: %v", notifier.Label(), notification.Result.URL, err)
+		return "", err
+	}
+
+	LogInfo("Successfully sent %s notification for %s", notifier.Label(), notification.Result.URL)
+	return reference, nil
+}
+
+// channelSettingsFor reports whether a channel sends a notification, and the
+// settings it sends with. Sites with a route only use the route's channels
+// and recipients.
+func channelSettingsFor(notifier Notifier, notification Notification, route NotificationRoute, routed bool, settings Settings) (Settings, bool) {
+	channelSettings := settings
+	var wanted bool
+	if routed {
+		wanted = routeWants(route, notifier, settings, notification.Severity)
+		if wanted {
+			channelSettings = routedSettings(route, notifier, settings)
+		}
+	} else {
+		wanted = notifier.Enabled(settings, notification.Severity)
+	}
+	wanted = wanted || (notification.Escalated && isEscalationChannel(settings, notifier.Name()))
+	return channelSettings, wanted
+}
+
+// notifyAll sends a notification through every channel enabled for its
+// severity, or through the site's route if it has one, and returns how many
+// sends succeeded. Failed sends are queued in the outbox to be retried.
+func notifyAll(notification Notification, settings Settings) int {
+	sent, _ := notifyAllWithReferences(notification, settings)
+	return sent
+}
+
+// notifyAllWithReferences is notifyAll that also returns the message
+// references of the threaded channels that sent successfully
+func notifyAllWithReferences(notification Notification, settings Settings) (int, map[string]string) {
+	route, routed := findRoute(settings, notification.Result.Route)
+
+	sent := 0
+	references := make(map[string]string)
+	for _, notifier := range notifiers {
+		channelSettings, wanted := channelSettingsFor(notifier, notification, route, routed, settings)
+		if !wanted {
+			continue
+		}
+		reference, err := deliverNotification(notifier, notification, channelSettings, 1)
+		if err != nil {
+			queueDelivery(notifier, notification, err, time.Now())
+			continue
+		}
+		sent++
+		if reference != "" {
+			references[notifier.Name()] = reference
+		}
+	}
+	return sent, references
+}
+
+// testNotificationHandler sends a test message through one channel. The
+// settings form is posted along with the request so unsaved values can be
+// tried out before saving.
+func testNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.URL.Query().Get("channel")
+	notifier, found := findNotifier(channel)
+	if !found {
+		http.Error(w, fmt.Sprintf("Unknown notification channel %q", channel), http.StatusNotFound)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		LogError("Error loading settings for %s test: %v", notifier.Label(), err)
+		http.Error(w, "Error loading settings", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		LogDebug("Testing %s with form values", notifier.Label())
+		notifier.ParseForm(r, &settings)
+	} else {
+		LogDebug("Testing %s with saved settings", notifier.Label())
+	}
+
+	err = notifier.Test(settings)
+	if errors.Is(err, errIncompleteSettings) {
+		LogWarning("%s test failed: %v", notifier.Label(), err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "%s %v", notifier.Label(), err)
+		return
+	}
+	if err != nil {
+		LogError("Error sending %s test: %v", notifier.Label(), err)
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "Error sending %s test: %v", notifier.Label(), err)
+		return
+	}
+
+	LogInfo("%s test notification sent successfully", notifier.Label())
+	fmt.Fprintf(w, "%s test notification sent successfully!", notifier.Label())
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records what it was asked to send
+type fakeNotifier struct {
+	name     string
+	enabled  map[string]bool
+	sendErr  error
+	received []Notification
+}
+
+func (f *fakeNotifier) Name() string  { return f.name }
+func (f *fakeNotifier) Label() string { return f.name }
+
+func (f *fakeNotifier) Enabled(settings Settings, severity string) bool {
+	return f.enabled[severity]
+}
+
+func (f *fakeNotifier) Send(notification Notification, settings Settings) (int, error) {
+	f.received = append(f.received, notification)
+	return 0, f.sendErr
+}
+
+func (f *fakeNotifier) Test(settings Settings) error                  { return nil }
+func (f *fakeNotifier) ParseForm(r *http.Request, settings *Settings) {}
+
+func useFakeNotifiers(t *testing.T, fakes ...*fakeNotifier) {
+	t.Helper()
+	original := notifiers
+	notifiers = nil
+	for _, fake := range fakes {
+		registerNotifier(fake)
+	}
+	t.Cleanup(func() { notifiers = original })
+}
+
+func TestNotifiersRegistered(t *testing.T) {
+	for _, name := range []string{"email", "ntfy"} {
+		if _, found := findNotifier(name); !found {
+			t.Errorf("Expected %s notifier to be registered", name)
+		}
+	}
+}
+
+func TestNotifyAll(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	criticalOnly := &fakeNotifier{name: "critical-only", enabled: map[string]bool{"critical": true}}
+	everything := &fakeNotifier{name: "everything", enabled: map[string]bool{"warning": true, "critical": true}}
+	failing := &fakeNotifier{name: "failing", enabled: map[string]bool{"warning": true}, sendErr: errors.New("down")}
+	useFakeNotifiers(t, criticalOnly, everything, failing)
+
+	sent := notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "example.com"}}, Settings{})
+
+	if sent != 1 {
+		t.Errorf("Expected 1 successful send, got %d", sent)
+	}
+	if len(criticalOnly.received) != 0 {
+		t.Error("Notifier should not receive severities it hasn't enabled")
+	}
+	if len(everything.received) != 1 || len(failing.received) != 1 {
+		t.Errorf("Expected enabled notifiers to be tried, got %d and %d", len(everything.received), len(failing.received))
+	}
+}
+
+func TestProcessNotificationsUsesRegistry(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"warning": true, "critical": true}}
+	useFakeNotifiers(t, fake)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	results := ScanResults{
+		LastScan: time.Now(),
+		Results: []CertResult{
+			{URL: "warning.example.com", DaysLeft: 20},
+			{URL: "fine.example.com", DaysLeft: 60},
+		},
+	}
+
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+	if len(fake.received) != 1 || fake.received[0].Severity != "warning" || fake.received[0].PreviousStatus != "normal" {
+		t.Fatalf("Expected one warning notification, got %+v", fake.received)
+	}
+
+	// Same status on the next scan doesn't notify again
+	if err := processNotifications(results, settings); err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+	if len(fake.received) != 1 {
+		t.Errorf("Expected no repeat notification, got %d", len(fake.received))
+	}
+}
+
+func TestProcessChangeNotifications(t *testing.T) {
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"change": true}}
+	useFakeNotifiers(t, fake)
+
+	processChangeNotifications([]CertChange{
+		{URL: "renewed.example.com", Kind: changeRenewed},
+		{URL: "swapped.example.com", Kind: changeIssuerChanged, Unexpected: true},
+	}, ScanResults{}, Settings{})
+
+	if len(fake.received) != 1 || fake.received[0].Change == nil || fake.received[0].Change.URL != "swapped.example.com" {
+		t.Errorf("Expected only the unexpected change to notify, got %+v", fake.received)
+	}
+}
+
+// threadedFakeNotifier hands out a new message reference for every send
+type threadedFakeNotifier struct {
+	*fakeNotifier
+}
+
+func (f threadedFakeNotifier) SendThreaded(notification Notification, settings Settings) (string, int, error) {
+	status, err := f.Send(notification, settings)
+	return fmt.Sprintf("ref-%d", len(f.received)), status, err
+}
+
+func TestProcessNotificationsResolved(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	threaded := threadedFakeNotifier{&fakeNotifier{name: "threaded", enabled: map[string]bool{"warning": true, "critical": true, "resolved": true}}}
+	alertsOnly := &fakeNotifier{name: "alerts-only", enabled: map[string]bool{"warning": true, "critical": true}}
+	useFakeNotifiers(t, alertsOnly)
+	registerNotifier(threaded)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	scan := func(daysLeft int) {
+		t.Helper()
+		results := ScanResults{LastScan: time.Now(), Results: []CertResult{{URL: "example.com", DaysLeft: daysLeft}}}
+		if err := processNotifications(results, settings); err != nil {
+			t.Fatalf("processNotifications returned error: %v", err)
+		}
+	}
+
+	scan(20) // warning
+	scan(5)  // critical
+	scan(85) // renewed
+
+	received := threaded.received
+	if len(received) != 3 {
+		t.Fatalf("Expected warning, critical and resolved notifications, got %+v", received)
+	}
+	if received[1].AlertReferences["threaded"] != "ref-1" {
+		t.Errorf("Expected the escalation to reference the first alert, got %v", received[1].AlertReferences)
+	}
+	resolved := received[2]
+	if resolved.Severity != "resolved" || resolved.PreviousStatus != "critical" || resolved.Result.DaysLeft != 85 {
+		t.Errorf("Unexpected resolved notification %+v", resolved)
+	}
+	if resolved.AlertReferences["threaded"] != "ref-1" {
+		t.Errorf("Expected the resolution to reference the first alert, got %v", resolved.AlertReferences)
+	}
+	if len(alertsOnly.received) != 2 {
+		t.Errorf("Channels without resolved enabled should not be told, got %d notifications", len(alertsOnly.received))
+	}
+
+	state, err := loadNotificationState()
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if refs := state.NotificationHistory["example.com"].AlertReferences; len(refs) != 0 {
+		t.Errorf("Expected references to be cleared after recovery, got %v", refs)
+	}
+}
+
+func TestProcessNotificationsScanErrors(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"error": true, "resolved": true}}
+	useFakeNotifiers(t, fake)
+
+	var settings Settings
+	settings.ScanErrorThreshold = 2
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	scan := func(result CertResult) NotificationHistory {
+		t.Helper()
+		if err := processNotifications(ScanResults{LastScan: time.Now(), Results: []CertResult{result}}, settings); err != nil {
+			t.Fatalf("processNotifications returned error: %v", err)
+		}
+		state, err := loadNotificationState()
+		if err != nil {
+			t.Fatalf("Error loading state: %v", err)
+		}
+		return state.NotificationHistory[result.URL]
+	}
+	failed := CertResult{URL: "example.com", Error: "connection refused"}
+
+	history := scan(failed)
+	if len(fake.received) != 0 || history.LastStatus != "normal" || history.ConsecutiveFailures != 1 {
+		t.Fatalf("Expected the first failure to only be counted, got %+v and %d notifications", history, len(fake.received))
+	}
+
+	history = scan(failed)
+	if history.LastStatus != "error" || history.LastError != "connection refused" {
+		t.Errorf("Expected the site to be in error, got %+v", history)
+	}
+	if len(fake.received) != 1 || fake.received[0].Severity != "error" || fake.received[0].Failures != 2 {
+		t.Fatalf("Expected an error notification after 2 failures, got %+v", fake.received)
+	}
+
+	// Still failing, no repeat
+	scan(failed)
+	if len(fake.received) != 1 {
+		t.Errorf("Expected no repeat error notification, got %d", len(fake.received))
+	}
+
+	history = scan(CertResult{URL: "example.com", DaysLeft: 60})
+	if history.LastStatus != "normal" || history.ConsecutiveFailures != 0 || history.LastError != "" {
+		t.Errorf("Expected the failure count to clear on recovery, got %+v", history)
+	}
+	if len(fake.received) != 2 || fake.received[1].Severity != "resolved" || fake.received[1].PreviousStatus != "error" {
+		t.Errorf("Expected a resolved notification after recovery, got %+v", fake.received)
+	}
+}
+
+func TestProcessNotificationsNodeFailures(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"warning": true, "error": true}}
+	useFakeNotifiers(t, fake)
+
+	var settings Settings
+	settings.ScanErrorThreshold = 2
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	// One of two nodes fails on every scan, the other still serves a
+	// certificate expiring in 20 days
+	scan := func(result CertResult) NotificationHistory {
+		t.Helper()
+		result.LastCheck = time.Now()
+		if err := processNotifications(ScanResults{LastScan: time.Now(), Results: []CertResult{result}}, settings); err != nil {
+			t.Fatalf("processNotifications returned error: %v", err)
+		}
+		state, err := loadNotificationState()
+		if err != nil {
+			t.Fatalf("Error loading state: %v", err)
+		}
+		return state.NotificationHistory[result.URL]
+	}
+	partial := CertResult{URL: "example.com", DaysLeft: 20, FailedAddresses: 1, Addresses: []AddressResult{
+		{Address: "192.0.2.1", DaysLeft: 20},
+		{Address: "192.0.2.2", Error: "connection refused"},
+	}}
+
+	history := scan(partial)
+	if len(fake.received) != 1 || fake.received[0].Severity != "warning" {
+		t.Fatalf("Expected the expiry warning despite the failing node, got %+v", fake.received)
+	}
+	if history.LastStatus != "warning" || history.NodeFailures != 1 || history.ConsecutiveFailures != 0 {
+		t.Errorf("Expected one node failure counted apart from the status, got %+v", history)
+	}
+
+	history = scan(partial)
+	if len(fake.received) != 2 || !fake.received[1].NodeFailure || fake.received[1].Severity != "error" {
+		t.Fatalf("Expected a node failure alert after 2 scans, got %+v", fake.received)
+	}
+	if !strings.Contains(fake.received[1].Result.Error, "192.0.2.2: connection refused") {
+		t.Errorf("Expected the alert to name the failing node, got %q", fake.received[1].Result.Error)
+	}
+	if history.LastStatus != "warning" {
+		t.Errorf("Expected the site to stay in warning, got %q", history.LastStatus)
+	}
+
+	// Still failing, no repeat; recovery resets the count
+	scan(partial)
+	if len(fake.received) != 2 {
+		t.Errorf("Expected no repeat node failure alert, got %d notifications", len(fake.received))
+	}
+	partial.FailedAddresses = 0
+	if history = scan(partial); history.NodeFailures != 0 {
+		t.Errorf("Expected the node failure count to reset, got %d", history.NodeFailures)
+	}
+}
+
+func TestProcessNotificationsReplayDoesNotCountFailures(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"error": true}}
+	useFakeNotifiers(t, fake)
+
+	var settings Settings
+	settings.ScanErrorThreshold = 2
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+
+	// Saving a threshold reprocesses the last scan's results without
+	// scanning, with a new scan time
+	result := CertResult{URL: "example.com", Error: "connection refused", LastCheck: time.Now()}
+	for i := 0; i < 3; i++ {
+		results := ScanResults{LastScan: time.Now(), Results: []CertResult{result}}
+		if err := processNotifications(results, settings); err != nil {
+			t.Fatalf("processNotifications returned error: %v", err)
+		}
+	}
+
+	state, err := loadNotificationState()
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	if history := state.NotificationHistory["example.com"]; history.ConsecutiveFailures != 1 || history.LastStatus != "normal" {
+		t.Errorf("Expected one failed scan counted, got %+v", history)
+	}
+	if len(fake.received) != 0 {
+		t.Errorf("Expected no error notification from replays, got %+v", fake.received)
+	}
+}
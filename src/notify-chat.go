@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Colours used by the chat channels for each severity, matching the dashboard
+const (
+	colorWarning  = "#ffc107"
+	colorCritical = "#dc3545"
+	colorChange   = "#007cba"
+	colorError    = "#fd7e14"
+	colorResolved = "#28a745"
+	colorTest     = "#28a745"
+)
+
+// chatNotifier is an incoming-webhook chat channel. The channels only differ
+// in where their settings live and how a message is laid out.
+type chatNotifier struct {
+	name     string
+	label    string
+	settings func(settings *Settings) *ChatWebhookSettings
+	payload  func(message chatMessage) interface{}
+}
+
+func (c chatNotifier) Name() string  { return c.name }
+func (c chatNotifier) Label() string { return c.label }
+
+func (c chatNotifier) Enabled(settings Settings, severity string) bool {
+	chat := c.settings(&settings)
+	return severityEnabled(severity, chat.EnabledWarning, chat.EnabledCritical, chat.EnabledChange, chat.EnabledResolved, chat.EnabledError)
+}
+
+func (c chatNotifier) Send(notification Notification, settings Settings) (int, error) {
+	webhookURL := c.settings(&settings).WebhookURL
+	if webhookURL == "" {
+		return 0, fmt.Errorf("%w (webhook URL not configured)", errIncompleteSettings)
+	}
+
+	message := chatMessageFor(notification, settings, c.name)
+	message.Title = reminderPrefix(notification) + message.Title
+	return postChatWebhook(c.label, webhookURL, c.payload(message))
+}
+
+func (c chatNotifier) Test(settings Settings) error {
+	webhookURL := c.settings(&settings).WebhookURL
+	if webhookURL == "" {
+		return fmt.Errorf("%w (webhook URL not configured)", errIncompleteSettings)
+	}
+
+	LogInfo("Sending test %s notification", c.label)
+	_, err := postChatWebhook(c.label, webhookURL, c.payload(chatTestMessage(c.label, settings)))
+	return err
+}
+
+// SendDigest posts the digest coloured by its most urgent status
+func (c chatNotifier) SendDigest(digest Digest, settings Settings) (int, error) {
+	webhookURL := c.settings(&settings).WebhookURL
+	if webhookURL == "" {
+		return 0, fmt.Errorf("%w (webhook URL not configured)", errIncompleteSettings)
+	}
+
+	severity := digest.WorstStatus()
+	if severity == "normal" {
+		severity = "resolved"
+	}
+	message := chatMessage{
+		Severity: severity,
+		Title:    digestSubject(digest, settings),
+		Text:     digestText(digest),
+		Link:     dashboardURL(settings) + "/results",
+	}
+	return postChatWebhook(c.label, webhookURL, c.payload(message))
+}
+
+// SendBatch posts one message listing every site in the batch
+func (c chatNotifier) SendBatch(batch NotificationBatch, settings Settings) (int, error) {
+	webhookURL := c.settings(&settings).WebhookURL
+	if webhookURL == "" {
+		return 0, fmt.Errorf("%w (webhook URL not configured)", errIncompleteSettings)
+	}
+
+	message := chatMessage{
+		Severity: batch.Severity,
+		Title:    batchTitle(batch),
+		Text:     batchText(batch),
+		Link:     dashboardURL(settings) + "/results",
+	}
+	return postChatWebhook(c.label, webhookURL, c.payload(message))
+}
+
+// SetRecipient posts to a route's incoming webhook instead
+func (c chatNotifier) SetRecipient(settings *Settings, recipient string) {
+	c.settings(settings).WebhookURL = recipient
+}
+
+func (c chatNotifier) Recipient(settings Settings) string {
+	return urlHost(c.settings(&settings).WebhookURL)
+}
+
+func (c chatNotifier) ParseForm(r *http.Request, settings *Settings) {
+	chat := c.settings(settings)
+	chat.EnabledWarning = r.FormValue(c.name+"_enabled_warning") == "on"
+	chat.EnabledCritical = r.FormValue(c.name+"_enabled_critical") == "on"
+	chat.EnabledChange = r.FormValue(c.name+"_enabled_change") == "on"
+	chat.EnabledResolved = r.FormValue(c.name+"_enabled_resolved") == "on"
+	chat.EnabledError = r.FormValue(c.name+"_enabled_error") == "on"
+	chat.WebhookURL = strings.TrimSpace(r.FormValue(c.name + "_webhook_url"))
+
+	LogDebug("Updated %s notifications: warning=%v, critical=%v, change=%v, resolved=%v, error=%v",
+		c.label, chat.EnabledWarning, chat.EnabledCritical, chat.EnabledChange, chat.EnabledResolved, chat.EnabledError)
+}
+
+// chatFact is one name/value line shown under a chat message
+type chatFact struct {
+	Name  string
+	Value string
+}
+
+// chatMessage is the content shared by the Slack, Teams and Discord channels,
+// each of which renders it in its own card format
+type chatMessage struct {
+	Severity string // "warning", "critical", "error", "change", "resolved" or "test"
+	Title    string
+	Text     string
+	Facts    []chatFact
+	Link     string // dashboard page for the site
+}
+
+func severityColor(severity string) string {
+	switch severity {
+	case "critical":
+		return colorCritical
+	case "warning":
+		return colorWarning
+	case "error":
+		return colorError
+	case "change":
+		return colorChange
+	case "resolved":
+		return colorResolved
+	default:
+		return colorTest
+	}
+}
+
+// chatMessageFor renders the channel's template for a notification. The facts
+// under the message aren't part of the template.
+func chatMessageFor(notification Notification, settings Settings, channel string) chatMessage {
+	result := notification.Result
+	message := chatMessage{
+		Severity: notification.Severity,
+		Link:     siteDashboardURL(settings, result.URL),
+	}
+	message.Title, message.Text = renderMessage(settings, channel, newTemplateData(notification, settings, channel))
+
+	if change := notification.Change; change != nil {
+		message.Facts = []chatFact{
+			{"Issuer", fmt.Sprintf("%s → %s", change.OldIssuer, change.NewIssuer)},
+			{"Expiry", fmt.Sprintf("%s → %s", change.OldExpiry.Format("2006-01-02"), change.NewExpiry.Format("2006-01-02"))},
+			{"Fingerprint", change.NewFingerprint},
+		}
+		return message
+	}
+
+	if notification.Severity == "error" {
+		message.Facts = []chatFact{{"Error", result.Error}}
+		return message
+	}
+
+	message.Facts = []chatFact{
+		{"Days remaining", fmt.Sprintf("%d", result.DaysLeft)},
+		{"Expiry date", result.ExpiryDate.Format("2006-01-02")},
+	}
+	if result.ExpiringChainIndex > 0 && notification.Severity != "resolved" {
+		message.Facts = append(message.Facts, chatFact{"Expiring certificate", expiringElementDescription(result)})
+	}
+	return message
+}
+
+func chatTestMessage(label string, settings Settings) chatMessage {
+	return chatMessage{
+		Severity: "test",
+		Title:    "SSL Monitor Test",
+		Text:     fmt.Sprintf("If you see this, %s notifications are configured correctly!", label),
+		Link:     dashboardURL(settings) + "/results",
+	}
+}
+
+// postChatWebhook posts a JSON payload to a chat incoming webhook and returns
+// the status it answered with
+func postChatWebhook(label string, webhookURL string, payload interface{}) (int, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		LogError("%s HTTP error: %v", label, err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	LogDebug("%s response: status=%d", label, resp.StatusCode)
+
+	// Discord answers 204 and Teams workflows 202, so accept any success
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusCodeError{service: label, StatusCode: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
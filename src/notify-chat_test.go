@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startChatServer records the JSON payload posted to it
+func startChatServer(t *testing.T, status int) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	payload := map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("Payload is not JSON: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &payload
+}
+
+func chatTestSettings(webhookURL string) Settings {
+	var settings Settings
+	settings.Dashboard.URL = "https://ssl.example.com"
+	chat := ChatWebhookSettings{EnabledWarning: true, EnabledCritical: true, WebhookURL: webhookURL}
+	settings.Notifications.Slack = chat
+	settings.Notifications.Teams = chat
+	settings.Notifications.Discord = chat
+	return settings
+}
+
+var chatTestNotification = Notification{
+	Severity: "critical",
+	Result: CertResult{
+		URL:        "example.com:8443",
+		Name:       "Example",
+		DaysLeft:   3,
+		ExpiryDate: time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestChatNotifiersRegistered(t *testing.T) {
+	for _, name := range []string{"slack", "teams", "discord"} {
+		notifier, found := findNotifier(name)
+		if !found {
+			t.Fatalf("Expected %s notifier to be registered", name)
+		}
+		settings := chatTestSettings("https://chat.example.com")
+		if !notifier.Enabled(settings, "warning") || notifier.Enabled(settings, "change") {
+			t.Errorf("%s enablement should follow its toggles", name)
+		}
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	server, payload := startChatServer(t, http.StatusOK)
+	notifier, _ := findNotifier("slack")
+
+	if _, err := notifier.Send(chatTestNotification, chatTestSettings(server.URL)); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	encoded, _ := json.Marshal(*payload)
+	body := string(encoded)
+	for _, expected := range []string{`"color":"#dc3545"`, `"type":"header"`, `"type":"button"`,
+		`"url":"https://ssl.example.com/results/site?url=example.com%3A8443"`, "Days remaining"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected Slack payload to contain %s\n%s", expected, body)
+		}
+	}
+}
+
+func TestTeamsPayload(t *testing.T) {
+	server, payload := startChatServer(t, http.StatusAccepted)
+	notifier, _ := findNotifier("teams")
+
+	warning := chatTestNotification
+	warning.Severity = "warning"
+	if _, err := notifier.Send(warning, chatTestSettings(server.URL)); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	encoded, _ := json.Marshal(*payload)
+	body := string(encoded)
+	for _, expected := range []string{`"contentType":"application/vnd.microsoft.card.adaptive"`, `"type":"AdaptiveCard"`,
+		`"color":"Warning"`, `"type":"Action.OpenUrl"`, `"type":"FactSet"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected Teams payload to contain %s\n%s", expected, body)
+		}
+	}
+}
+
+func TestDiscordPayload(t *testing.T) {
+	server, payload := startChatServer(t, http.StatusNoContent)
+	notifier, _ := findNotifier("discord")
+
+	if _, err := notifier.Send(chatTestNotification, chatTestSettings(server.URL)); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	embeds, ok := (*payload)["embeds"].([]interface{})
+	if !ok || len(embeds) != 1 {
+		t.Fatalf("Expected one embed, got %v", *payload)
+	}
+	embed := embeds[0].(map[string]interface{})
+	if embed["color"] != float64(0xdc3545) {
+		t.Errorf("Expected critical colour, got %v", embed["color"])
+	}
+	if !strings.HasPrefix(embed["url"].(string), "https://ssl.example.com/results/site") {
+		t.Errorf("Expected embed to link to the dashboard, got %v", embed["url"])
+	}
+}
+
+func TestChatNotifierTestAndErrors(t *testing.T) {
+	notifier, _ := findNotifier("discord")
+
+	err := notifier.Test(chatTestSettings(""))
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("Expected not configured error, got %v", err)
+	}
+
+	server, payload := startChatServer(t, http.StatusNoContent)
+	if err := notifier.Test(chatTestSettings(server.URL)); err != nil {
+		t.Fatalf("Test returned error: %v", err)
+	}
+	if _, ok := (*payload)["embeds"]; !ok {
+		t.Error("Expected the test message to be sent as an embed")
+	}
+
+	failing, _ := startChatServer(t, http.StatusBadRequest)
+	_, err = notifier.Send(chatTestNotification, chatTestSettings(failing.URL))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected status code error, got %v", err)
+	}
+}
+
+func TestSiteDashboardURL(t *testing.T) {
+	var settings Settings
+	settings.Dashboard.Port = 8080
+	if link := siteDashboardURL(settings, "example.com"); link != "http://localhost:8080/results/site?url=example.com" {
+		t.Errorf("Unexpected default link %q", link)
+	}
+
+	settings.Dashboard.URL = "https://ssl.example.com"
+	if link := siteDashboardURL(settings, "[2001:db8::1]:443"); link != "https://ssl.example.com/results/site?url=%5B2001%3Adb8%3A%3A1%5D%3A443" {
+		t.Errorf("Unexpected link %q", link)
+	}
+}
+
+func TestChatResolvedMessage(t *testing.T) {
+	resolved := chatTestNotification
+	resolved.Severity = "resolved"
+	resolved.PreviousStatus = "critical"
+	resolved.Result.DaysLeft = 89
+
+	message := chatMessageFor(resolved, chatTestSettings(""), "slack")
+	if !strings.Contains(message.Title, "Resolved") || !strings.Contains(message.Text, "no longer critical") {
+		t.Errorf("Unexpected resolved message %+v", message)
+	}
+	if severityColor(message.Severity) != colorResolved || teamsColor(message.Severity) != "Good" {
+		t.Error("Expected resolved messages to be green")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerNotifier(chatNotifier{
+		name:     "discord",
+		label:    "Discord",
+		settings: func(settings *Settings) *ChatWebhookSettings { return &settings.Notifications.Discord },
+		payload:  discordPayload,
+	})
+}
+
+// discordColor converts a "#rrggbb" colour into the integer embeds use
+func discordColor(severity string) int {
+	color, _ := strconv.ParseInt(strings.TrimPrefix(severityColor(severity), "#"), 16, 32)
+	return int(color)
+}
+
+// discordPayload sends the message as a single embed titled with a link to the dashboard
+func discordPayload(message chatMessage) interface{} {
+	fields := make([]map[string]interface{}, 0, len(message.Facts))
+	for _, fact := range message.Facts {
+		fields = append(fields, map[string]interface{}{"name": fact.Name, "value": fact.Value, "inline": true})
+	}
+
+	return map[string]interface{}{
+		"username": "SSL Monitor",
+		"embeds": []map[string]interface{}{{
+			"title":       message.Title,
+			"url":         message.Link,
+			"description": message.Text,
+			"color":       discordColor(message.Severity),
+			"fields":      fields,
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		}},
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Email providers
+const (
+	emailProviderPostmark = "postmark"
+	emailProviderSMTP     = "smtp"
+)
+
+// postmarkAPIURL is a variable so tests can point it at a local server
+var postmarkAPIURL = "https://api.postmarkapp.com/email"
+
+type emailNotifier struct{}
+
+func init() {
+	registerNotifier(emailNotifier{})
+}
+
+func (emailNotifier) Name() string  { return "email" }
+func (emailNotifier) Label() string { return "Email" }
+
+func (emailNotifier) Enabled(settings Settings, severity string) bool {
+	email := settings.Notifications.Email
+	return severityEnabled(severity, email.EnabledWarning, email.EnabledCritical, email.EnabledChange, email.EnabledResolved, email.EnabledError)
+}
+
+func (e emailNotifier) Send(notification Notification, settings Settings) (int, error) {
+	_, status, err := e.SendThreaded(notification, settings)
+	return status, err
+}
+
+// SendThreaded replies to the incident's first alert, if there was one, so
+// escalations and the resolution land in the same thread
+func (emailNotifier) SendThreaded(notification Notification, settings Settings) (string, int, error) {
+	email := settings.Notifications.Email
+	if err := checkEmailSettings(email); err != nil {
+		return "", 0, err
+	}
+
+	subject, body := renderMessage(settings, "email", newTemplateData(notification, settings, "email"))
+	if notification.Escalated && settings.Reminders.EscalationEmailTo != "" {
+		email.To = strings.Join([]string{email.To, settings.Reminders.EscalationEmailTo}, ", ")
+	}
+	return sendEmail(email, reminderPrefix(notification)+subject, body, notification.AlertReferences["email"])
+}
+
+// checkEmailSettings returns errIncompleteSettings if the provider can't send yet
+func checkEmailSettings(email EmailSettings) error {
+	if email.Provider == emailProviderSMTP {
+		if email.SMTPHost == "" || email.From == "" || email.To == "" {
+			return fmt.Errorf("%w (missing SMTP host, from, or to address)", errIncompleteSettings)
+		}
+	} else if email.ServerToken == "" || email.From == "" || email.To == "" {
+		return fmt.Errorf("%w (missing server token, from, or to address)", errIncompleteSettings)
+	}
+	return nil
+}
+
+func (emailNotifier) Test(settings Settings) error {
+	email := settings.Notifications.Email
+	if err := checkEmailSettings(email); err != nil {
+		return err
+	}
+
+	LogInfo("Sending test email from %s to %s via %s", email.From, email.To, emailProviderName(email))
+	_, _, err := sendEmail(email, "SSL Monitor Test Email",
+		"<h2>SSL Monitor Test</h2><p>If you receive this email, your email notifications are configured correctly!</p>", "")
+	return err
+}
+
+// SendDigest emails the digest, to the digest recipients if they are set
+func (emailNotifier) SendDigest(digest Digest, settings Settings) (int, error) {
+	email := settings.Notifications.Email
+	if settings.Digest.EmailTo != "" {
+		email.To = settings.Digest.EmailTo
+	}
+	if err := checkEmailSettings(email); err != nil {
+		return 0, err
+	}
+
+	_, status, err := sendEmail(email, digestSubject(digest, settings), digestEmailBody(digest, settings), "")
+	return status, err
+}
+
+// SendBatch emails one table listing every site in the batch
+func (emailNotifier) SendBatch(batch NotificationBatch, settings Settings) (int, error) {
+	if err := checkEmailSettings(settings.Notifications.Email); err != nil {
+		return 0, err
+	}
+
+	_, status, err := sendEmail(settings.Notifications.Email, batchTitle(batch), batchEmailBody(batch, settings), "")
+	return status, err
+}
+
+// SetRecipient sends to a route's addresses instead
+func (emailNotifier) SetRecipient(settings *Settings, recipient string) {
+	settings.Notifications.Email.To = recipient
+}
+
+func (emailNotifier) Recipient(settings Settings) string {
+	return settings.Notifications.Email.To
+}
+
+func (emailNotifier) ParseForm(r *http.Request, settings *Settings) {
+	email := &settings.Notifications.Email
+	email.EnabledWarning = r.FormValue("email_enabled_warning") == "on"
+	email.EnabledCritical = r.FormValue("email_enabled_critical") == "on"
+	email.EnabledChange = r.FormValue("email_enabled_change") == "on"
+	email.EnabledResolved = r.FormValue("email_enabled_resolved") == "on"
+	email.EnabledError = r.FormValue("email_enabled_error") == "on"
+	email.Provider = emailProviderPostmark
+	if r.FormValue("email_provider") == emailProviderSMTP {
+		email.Provider = emailProviderSMTP
+	}
+	email.ServerToken = r.FormValue("email_server_token")
+	email.From = r.FormValue("email_from")
+	email.To = r.FormValue("email_to")
+	email.MessageStream = r.FormValue("email_message_stream")
+	email.SMTPHost = strings.TrimSpace(r.FormValue("email_smtp_host"))
+	email.SMTPPort = parseInt(r.FormValue("email_smtp_port"))
+	email.SMTPSecurity = smtpSecuritySTARTTLS
+	switch security := r.FormValue("email_smtp_security"); security {
+	case smtpSecurityTLS, smtpSecurityNone:
+		email.SMTPSecurity = security
+	}
+	email.SMTPUsername = r.FormValue("email_smtp_username")
+	email.SMTPPassword = r.FormValue("email_smtp_password")
+
+	LogDebug("Updated email notifications: provider=%s, warning=%v, critical=%v, change=%v, resolved=%v, error=%v",
+		email.Provider, email.EnabledWarning, email.EnabledCritical, email.EnabledChange, email.EnabledResolved, email.EnabledError)
+}
+
+// sendEmail delivers an HTML email through the selected provider and returns
+// its Message-ID and the provider's status. inReplyTo, if set, is the
+// Message-ID it follows up.
+func sendEmail(emailSettings EmailSettings, subject string, htmlBody string, inReplyTo string) (string, int, error) {
+	messageID := newMessageID(emailSettings.From)
+
+	var status int
+	var err error
+	if emailSettings.Provider == emailProviderSMTP {
+		status, err = sendSMTPEmail(emailSettings, subject, htmlBody, messageID, inReplyTo)
+	} else {
+		status, err = postPostmarkEmail(emailSettings, subject, htmlBody, messageID, inReplyTo)
+	}
+	if err != nil {
+		return "", status, err
+	}
+	return messageID, status, nil
+}
+
+func emailProviderName(emailSettings EmailSettings) string {
+	if emailSettings.Provider == emailProviderSMTP {
+		return "SMTP " + emailSettings.SMTPHost
+	}
+	return "Postmark"
+}
+
+// postPostmarkEmail sends one HTML email through the Postmark API and returns
+// the status it answered with
+func postPostmarkEmail(emailSettings EmailSettings, subject string, htmlBody string, messageID string, inReplyTo string) (int, error) {
+	headers := []map[string]string{{"Name": "Message-ID", "Value": messageID}}
+	if inReplyTo != "" {
+		headers = append(headers,
+			map[string]string{"Name": "In-Reply-To", "Value": inReplyTo},
+			map[string]string{"Name": "References", "Value": inReplyTo})
+	}
+
+	emailData := map[string]interface{}{
+		"From":          emailSettings.From,
+		"To":            emailSettings.To,
+		"Subject":       subject,
+		"HtmlBody":      htmlBody,
+		"TextBody":      plainTextFromHTML(htmlBody),
+		"MessageStream": emailSettings.MessageStream,
+		"Headers":       headers,
+	}
+
+	jsonData, err := json.Marshal(emailData)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", postmarkAPIURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", emailSettings.ServerToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		LogError("Postmark HTTP error: %v", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	LogDebug("Email response: status=%d", resp.StatusCode)
+
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, &statusCodeError{service: "postmark", StatusCode: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ntfyNotifier struct{}
+
+func init() {
+	registerNotifier(ntfyNotifier{})
+}
+
+func (ntfyNotifier) Name() string  { return "ntfy" }
+func (ntfyNotifier) Label() string { return "NTFY" }
+
+func (ntfyNotifier) Enabled(settings Settings, severity string) bool {
+	ntfy := settings.Notifications.Ntfy
+	return severityEnabled(severity, ntfy.EnabledWarning, ntfy.EnabledCritical, ntfy.EnabledChange, ntfy.EnabledResolved, ntfy.EnabledError)
+}
+
+func (n ntfyNotifier) Send(notification Notification, settings Settings) (int, error) {
+	_, status, err := n.SendThreaded(notification, settings)
+	return status, err
+}
+
+// SendThreaded returns the ntfy message ID, which the resolved message quotes
+// along with the alert's title
+func (ntfyNotifier) SendThreaded(notification Notification, settings Settings) (string, int, error) {
+	if settings.Notifications.Ntfy.URL == "" {
+		return "", 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	title, message, priority, tags := ntfyMessage(notification, settings)
+	title = reminderPrefix(notification) + title
+
+	LogInfo("Sending NTFY: URL=%s, Title=%s, Priority=%s", settings.Notifications.Ntfy.URL, title, priority)
+	return postNtfy(settings.Notifications.Ntfy.URL, title, message, priority, tags)
+}
+
+func (ntfyNotifier) Test(settings Settings) error {
+	url := settings.Notifications.Ntfy.URL
+	if url == "" {
+		return fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	LogInfo("Sending test NTFY notification to %s", url)
+	_, _, err := postNtfy(url, "SSL Monitor Test",
+		"SSL Monitor test notification - if you see this, NTFY is working correctly!", "default", "test,ssl-monitor")
+	return err
+}
+
+func (ntfyNotifier) SendDigest(digest Digest, settings Settings) (int, error) {
+	url := settings.Notifications.Ntfy.URL
+	if url == "" {
+		return 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	_, status, err := postNtfy(url, digestSubject(digest, settings), digestText(digest), "low", "clipboard,ssl-monitor")
+	return status, err
+}
+
+// SendBatch publishes one message listing every site in the batch
+func (ntfyNotifier) SendBatch(batch NotificationBatch, settings Settings) (int, error) {
+	url := settings.Notifications.Ntfy.URL
+	if url == "" {
+		return 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	priority, tags := ntfyPriority(batch.Severity)
+	_, status, err := postNtfy(url, batchTitle(batch), batchText(batch), priority, tags)
+	return status, err
+}
+
+// SetRecipient publishes to a route's topic URL instead
+func (ntfyNotifier) SetRecipient(settings *Settings, recipient string) {
+	settings.Notifications.Ntfy.URL = recipient
+}
+
+func (ntfyNotifier) Recipient(settings Settings) string {
+	return settings.Notifications.Ntfy.URL
+}
+
+func (ntfyNotifier) ParseForm(r *http.Request, settings *Settings) {
+	ntfy := &settings.Notifications.Ntfy
+	ntfy.EnabledWarning = r.FormValue("ntfy_enabled_warning") == "on"
+	ntfy.EnabledCritical = r.FormValue("ntfy_enabled_critical") == "on"
+	ntfy.EnabledChange = r.FormValue("ntfy_enabled_change") == "on"
+	ntfy.EnabledResolved = r.FormValue("ntfy_enabled_resolved") == "on"
+	ntfy.EnabledError = r.FormValue("ntfy_enabled_error") == "on"
+	ntfy.URL = r.FormValue("ntfy_url")
+
+	LogDebug("Updated NTFY notifications: warning=%v, critical=%v, change=%v, resolved=%v, error=%v",
+		ntfy.EnabledWarning, ntfy.EnabledCritical, ntfy.EnabledChange, ntfy.EnabledResolved, ntfy.EnabledError)
+}
+
+// ntfyMessage renders the ntfy template for a notification. Priority and tags
+// follow the severity and aren't part of the template.
+func ntfyMessage(notification Notification, settings Settings) (title, message, priority, tags string) {
+	title, message = renderMessage(settings, "ntfy", newTemplateData(notification, settings, "ntfy"))
+	priority, tags = ntfyPriority(notification.Severity)
+	return title, message, priority, tags
+}
+
+// ntfyPriority is the priority and tags for a severity
+func ntfyPriority(severity string) (priority, tags string) {
+	switch severity {
+	case "critical":
+		priority, tags = "urgent", "warning,ssl-monitor,urgent"
+	case "warning":
+		priority, tags = "high", "warning,ssl-monitor"
+	case "error":
+		priority, tags = "high", "x,ssl-monitor"
+	case "change":
+		priority, tags = "high", "lock,ssl-monitor"
+	case "resolved":
+		priority, tags = "default", "white_check_mark,ssl-monitor"
+	}
+	return priority, tags
+}
+
+// postNtfy publishes one message to an ntfy topic URL and returns the ID ntfy
+// gave it, if the server reported one, and the status it answered with
+func postNtfy(url string, title string, message string, priority string, tags string) (string, int, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(message))
+	if err != nil {
+		return "", 0, err
+	}
+
+	req.Header.Set("Title", title)
+	req.Header.Set("Priority", priority)
+	req.Header.Set("Tags", tags)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		LogError("NTFY HTTP error: %v", err)
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	LogDebug("NTFY response: status=%d", resp.StatusCode)
+
+	if resp.StatusCode != 200 {
+		return "", resp.StatusCode, &statusCodeError{service: "ntfy", StatusCode: resp.StatusCode}
+	}
+
+	// ntfy answers with the published message as JSON
+	var published struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&published)
+	return published.ID, resp.StatusCode, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+func init() {
+	registerNotifier(chatNotifier{
+		name:     "slack",
+		label:    "Slack",
+		settings: func(settings *Settings) *ChatWebhookSettings { return &settings.Notifications.Slack },
+		payload:  slackPayload,
+	})
+}
+
+// slackPayload lays the message out in Block Kit. Blocks have no colour of
+// their own, so they sit inside a coloured attachment.
+func slackPayload(message chatMessage) interface{} {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": message.Title, "emoji": true},
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": message.Text},
+		},
+	}
+
+	if len(message.Facts) > 0 {
+		fields := make([]map[string]interface{}, 0, len(message.Facts))
+		for _, fact := range message.Facts {
+			fields = append(fields, map[string]interface{}{"type": "mrkdwn", "text": "*" + fact.Name + "*\n" + fact.Value})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+
+	blocks = append(blocks, map[string]interface{}{
+		"type": "actions",
+		"elements": []map[string]interface{}{{
+			"type": "button",
+			"text": map[string]interface{}{"type": "plain_text", "text": "Open dashboard"},
+			"url":  message.Link,
+		}},
+	})
+
+	return map[string]interface{}{
+		"text": message.Title, // shown in notifications and by clients without Block Kit
+		"attachments": []map[string]interface{}{{
+			"color":  severityColor(message.Severity),
+			"blocks": blocks,
+		}},
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+func init() {
+	registerNotifier(chatNotifier{
+		name:     "teams",
+		label:    "Teams",
+		settings: func(settings *Settings) *ChatWebhookSettings { return &settings.Notifications.Teams },
+		payload:  teamsPayload,
+	})
+}
+
+// teamsColor maps a severity onto the named colours Adaptive Cards support
+func teamsColor(severity string) string {
+	switch severity {
+	case "critical", "error":
+		return "Attention"
+	case "warning":
+		return "Warning"
+	case "change":
+		return "Accent"
+	case "resolved":
+		return "Good"
+	default:
+		return "Good"
+	}
+}
+
+// teamsContainerStyle tints the card background for the expiry severities and
+// their recovery
+func teamsContainerStyle(severity string) string {
+	switch severity {
+	case "resolved":
+		return "good"
+	case "critical", "error":
+		return "attention"
+	case "warning":
+		return "warning"
+	default:
+		return "default"
+	}
+}
+
+// teamsPayload wraps an Adaptive Card in the message envelope Teams incoming
+// webhooks and workflows expect
+func teamsPayload(message chatMessage) interface{} {
+	body := []map[string]interface{}{
+		{
+			"type":   "TextBlock",
+			"text":   message.Title,
+			"weight": "Bolder",
+			"size":   "Medium",
+			"color":  teamsColor(message.Severity),
+			"wrap":   true,
+		},
+		{
+			"type": "TextBlock",
+			"text": message.Text,
+			"wrap": true,
+		},
+	}
+
+	if len(message.Facts) > 0 {
+		facts := make([]map[string]interface{}, 0, len(message.Facts))
+		for _, fact := range message.Facts {
+			facts = append(facts, map[string]interface{}{"title": fact.Name, "value": fact.Value})
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"msteams": map[string]interface{}{"width": "Full"},
+		"body": []map[string]interface{}{{
+			"type":  "Container",
+			"style": teamsContainerStyle(message.Severity),
+			"items": body,
+		}},
+		"actions": []map[string]interface{}{{
+			"type":  "Action.OpenUrl",
+			"title": "Open dashboard",
+			"url":   message.Link,
+		}},
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultWebhookHMACHeader = "X-Signature-256"
+
+// defaultWebhookTemplate is used when no body template is configured
+const defaultWebhookTemplate = `{
+  "site": {{json .Result.Name}},
+  "url": {{json .Result.URL}},
+  "old_status": {{json .OldStatus}},
+  "new_status": {{json .NewStatus}},{{if .Reminder}}
+  "reminder": {{.Reminder}},
+  "escalated": {{.Escalated}},{{end}}
+  "days_left": {{.Result.DaysLeft}},
+  "expiry_date": {{json .Result.ExpiryDate}}{{if .Result.Error}},
+  "error": {{json .Result.Error}},
+  "consecutive_failures": {{.Failures}}{{end}}{{if .NodeFailure}},
+  "node_failure": true{{end}}{{if .Change}},
+  "change": {{json .Change}}{{end}}
+}`
+
+type WebhookSettings struct {
+	EnabledWarning  bool              `json:"enabled_warning"`
+	EnabledCritical bool              `json:"enabled_critical"`
+	EnabledChange   bool              `json:"enabled_change"`
+	EnabledResolved bool              `json:"enabled_resolved"`
+	EnabledError    bool              `json:"enabled_error"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	BodyTemplate    string            `json:"body_template"` // text/template, empty uses the default JSON body
+	HMACSecret      string            `json:"hmac_secret,omitempty"`
+	HMACHeader      string            `json:"hmac_header,omitempty"` // header carrying "sha256=<hex>" of the body
+}
+
+// HeaderLines renders the headers one "Name: value" per line for the settings form
+func (w WebhookSettings) HeaderLines() string {
+	names := make([]string, 0, len(w.Headers))
+	for name := range w.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, name+": "+w.Headers[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WebhookTemplateData is what the body template is executed with
+type WebhookTemplateData struct {
+	Result      CertResult
+	OldStatus   string
+	NewStatus   string      // "warning", "critical", "error", "change", "resolved" or "test"
+	Change      *CertChange // set when NewStatus is "change"
+	Failures    int         // consecutive failed scans, when NewStatus is "error"
+	NodeFailure bool        // when NewStatus is "error", only some of the site's nodes failed
+	Reminder    int         // reminder number while the status persists, 0 for the first alert
+	Escalated   bool
+	Settings    Settings
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes any value, so strings can be dropped into a JSON body safely
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+type webhookNotifier struct{}
+
+func init() {
+	registerNotifier(webhookNotifier{})
+}
+
+func (webhookNotifier) Name() string  { return "webhook" }
+func (webhookNotifier) Label() string { return "Webhook" }
+
+func (webhookNotifier) Enabled(settings Settings, severity string) bool {
+	webhook := settings.Notifications.Webhook
+	return severityEnabled(severity, webhook.EnabledWarning, webhook.EnabledCritical, webhook.EnabledChange, webhook.EnabledResolved, webhook.EnabledError)
+}
+
+func (webhookNotifier) Send(notification Notification, settings Settings) (int, error) {
+	if settings.Notifications.Webhook.URL == "" {
+		return 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	return postWebhook(settings.Notifications.Webhook, WebhookTemplateData{
+		Result:      notification.Result,
+		OldStatus:   notification.PreviousStatus,
+		NewStatus:   notification.Severity,
+		Change:      notification.Change,
+		Failures:    notification.Failures,
+		NodeFailure: notification.NodeFailure,
+		Reminder:    notification.Reminder,
+		Escalated:   notification.Escalated,
+		Settings:    settings,
+	})
+}
+
+func (webhookNotifier) Test(settings Settings) error {
+	if settings.Notifications.Webhook.URL == "" {
+		return fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	LogInfo("Sending test webhook to %s", settings.Notifications.Webhook.URL)
+	_, err := postWebhook(settings.Notifications.Webhook, WebhookTemplateData{
+		Result: CertResult{
+			URL:        "example.com",
+			Name:       "SSL Monitor Test",
+			ExpiryDate: time.Now().AddDate(0, 0, 30),
+			DaysLeft:   30,
+			LastCheck:  time.Now(),
+		},
+		OldStatus: "normal",
+		NewStatus: "test",
+		Settings:  settings,
+	})
+	return err
+}
+
+// SendDigest posts the digest as JSON. The body template is for alerts about
+// one site, so it isn't used here.
+func (webhookNotifier) SendDigest(digest Digest, settings Settings) (int, error) {
+	if settings.Notifications.Webhook.URL == "" {
+		return 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	body, err := digestJSON(digest)
+	if err != nil {
+		return 0, err
+	}
+	return postWebhookBody(settings.Notifications.Webhook, body)
+}
+
+// SendBatch posts the batch as JSON. Like the digest it doesn't use the body
+// template, which is for alerts about one site.
+func (webhookNotifier) SendBatch(batch NotificationBatch, settings Settings) (int, error) {
+	if settings.Notifications.Webhook.URL == "" {
+		return 0, fmt.Errorf("%w (URL not configured)", errIncompleteSettings)
+	}
+
+	body, err := batchJSON(batch)
+	if err != nil {
+		return 0, err
+	}
+	return postWebhookBody(settings.Notifications.Webhook, body)
+}
+
+// SetRecipient sends to a route's URL, with the same method, headers and body
+func (webhookNotifier) SetRecipient(settings *Settings, recipient string) {
+	settings.Notifications.Webhook.URL = recipient
+}
+
+func (webhookNotifier) Recipient(settings Settings) string {
+	return urlHost(settings.Notifications.Webhook.URL)
+}
+
+func (webhookNotifier) ParseForm(r *http.Request, settings *Settings) {
+	webhook := &settings.Notifications.Webhook
+	webhook.EnabledWarning = r.FormValue("webhook_enabled_warning") == "on"
+	webhook.EnabledCritical = r.FormValue("webhook_enabled_critical") == "on"
+	webhook.EnabledChange = r.FormValue("webhook_enabled_change") == "on"
+	webhook.EnabledResolved = r.FormValue("webhook_enabled_resolved") == "on"
+	webhook.EnabledError = r.FormValue("webhook_enabled_error") == "on"
+	webhook.URL = strings.TrimSpace(r.FormValue("webhook_url"))
+	webhook.Method = strings.ToUpper(strings.TrimSpace(r.FormValue("webhook_method")))
+	webhook.Headers = parseHeaderLines(r.FormValue("webhook_headers"))
+	webhook.BodyTemplate = r.FormValue("webhook_body_template")
+	webhook.HMACSecret = r.FormValue("webhook_hmac_secret")
+	webhook.HMACHeader = strings.TrimSpace(r.FormValue("webhook_hmac_header"))
+
+	LogDebug("Updated webhook notifications: warning=%v, critical=%v, change=%v, resolved=%v, error=%v",
+		webhook.EnabledWarning, webhook.EnabledCritical, webhook.EnabledChange, webhook.EnabledResolved, webhook.EnabledError)
+}
+
+// parseHeaderLines reads "Name: value" lines, skipping blank or malformed ones
+func parseHeaderLines(text string) map[string]string {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			continue
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// signWebhookBody returns the HMAC-SHA256 signature header value for a body
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// renderWebhookBody executes the configured body template, or the default one
+func renderWebhookBody(bodyTemplate string, data WebhookTemplateData) ([]byte, error) {
+	if strings.TrimSpace(bodyTemplate) == "" {
+		bodyTemplate = defaultWebhookTemplate
+	}
+
+	parsed, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(bodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	var body bytes.Buffer
+	err = parsed.Execute(&body, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering body template: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+func postWebhook(webhook WebhookSettings, data WebhookTemplateData) (int, error) {
+	body, err := renderWebhookBody(webhook.BodyTemplate, data)
+	if err != nil {
+		return 0, err
+	}
+	return postWebhookBody(webhook, body)
+}
+
+// postWebhookBody sends an already rendered body with the configured method,
+// headers and signature, and returns the status the server answered with
+func postWebhookBody(webhook WebhookSettings, body []byte) (int, error) {
+	method := webhook.Method
+	if method == "" {
+		method = "POST"
+	}
+
+	req, err := http.NewRequest(method, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+	if webhook.HMACSecret != "" {
+		header := webhook.HMACHeader
+		if header == "" {
+			header = defaultWebhookHMACHeader
+		}
+		req.Header.Set(header, signWebhookBody(webhook.HMACSecret, body))
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		LogError("Webhook HTTP error: %v", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	LogDebug("Webhook response: status=%d", resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusCodeError{service: "webhook", StatusCode: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
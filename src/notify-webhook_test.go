@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type receivedWebhook struct {
+	method  string
+	headers http.Header
+	body    []byte
+}
+
+func startWebhookServer(t *testing.T, status int) (*httptest.Server, *receivedWebhook) {
+	t.Helper()
+	received := &receivedWebhook{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.method = r.Method
+		received.headers = r.Header.Clone()
+		received.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestWebhookSendWithTemplateAndSignature(t *testing.T) {
+	server, received := startWebhookServer(t, http.StatusAccepted)
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Critical = 7
+	settings.Notifications.Webhook = WebhookSettings{
+		EnabledCritical: true,
+		URL:             server.URL + "/hook",
+		Method:          "PUT",
+		Headers:         map[string]string{"Authorization": "Bearer secret-token"},
+		BodyTemplate:    `{{.Result.Name}} went {{.OldStatus}} -> {{.NewStatus}} with {{.Result.DaysLeft}} days (critical under {{.Settings.Dashboard.ColorThresholds.Critical}})`,
+		HMACSecret:      "shared-secret",
+		HMACHeader:      "X-Hub-Signature-256",
+	}
+
+	notifier, _ := findNotifier("webhook")
+	if !notifier.Enabled(settings, "critical") || notifier.Enabled(settings, "warning") {
+		t.Fatal("Webhook enablement should follow the per-severity toggles")
+	}
+
+	_, err := notifier.Send(Notification{
+		Severity:       "critical",
+		PreviousStatus: "warning",
+		Result:         CertResult{URL: "example.com", Name: "Example", DaysLeft: 3},
+	}, settings)
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	expectedBody := "Example went warning -> critical with 3 days (critical under 7)"
+	if string(received.body) != expectedBody {
+		t.Errorf("Expected body %q, got %q", expectedBody, received.body)
+	}
+	if received.method != "PUT" {
+		t.Errorf("Expected PUT, got %s", received.method)
+	}
+	if received.headers.Get("Authorization") != "Bearer secret-token" {
+		t.Errorf("Custom header not sent, got %q", received.headers.Get("Authorization"))
+	}
+
+	signature := received.headers.Get("X-Hub-Signature-256")
+	if !hmac.Equal([]byte(signature), []byte(signWebhookBody("shared-secret", received.body))) {
+		t.Errorf("Signature %q does not match the body", signature)
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("Expected sha256= prefix, got %q", signature)
+	}
+}
+
+func TestWebhookDefaultTemplate(t *testing.T) {
+	server, received := startWebhookServer(t, http.StatusOK)
+
+	var settings Settings
+	settings.Notifications.Webhook.URL = server.URL
+
+	expiry := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := webhookNotifier{}.Send(Notification{
+		Severity: "change",
+		Result:   CertResult{URL: "example.com", Name: `Quote "test"`, ExpiryDate: expiry},
+		Change:   &CertChange{URL: "example.com", Kind: changeIssuerChanged, Unexpected: true},
+	}, settings)
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	if received.method != "POST" {
+		t.Errorf("Expected POST by default, got %s", received.method)
+	}
+	if received.headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", received.headers.Get("Content-Type"))
+	}
+	if received.headers.Get(defaultWebhookHMACHeader) != "" {
+		t.Error("No signature should be sent without a secret")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(received.body, &payload); err != nil {
+		t.Fatalf("Default body is not valid JSON: %v\n%s", err, received.body)
+	}
+	if payload["site"] != `Quote "test"` || payload["new_status"] != "change" || payload["change"] == nil {
+		t.Errorf("Unexpected default payload: %v", payload)
+	}
+}
+
+func TestWebhookDefaultTemplateErrorReminder(t *testing.T) {
+	body, err := renderWebhookBody("", WebhookTemplateData{
+		Result:    CertResult{URL: "example.com", Error: "connection refused"},
+		OldStatus: "error",
+		NewStatus: "error",
+		Failures:  4,
+		Reminder:  2,
+		Escalated: true,
+	})
+	if err != nil {
+		t.Fatalf("renderWebhookBody returned error: %v", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Default body is not valid JSON: %v\n%s", err, body)
+	}
+	if payload["error"] != "connection refused" || payload["consecutive_failures"] != float64(4) ||
+		payload["reminder"] != float64(2) || payload["escalated"] != true {
+		t.Errorf("Unexpected default payload: %v", payload)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	server, _ := startWebhookServer(t, http.StatusInternalServerError)
+
+	var settings Settings
+	settings.Notifications.Webhook.URL = server.URL
+
+	_, err := webhookNotifier{}.Send(Notification{Severity: "warning"}, settings)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected status code error, got %v", err)
+	}
+
+	settings.Notifications.Webhook.BodyTemplate = "{{.Missing"
+	_, err = webhookNotifier{}.Send(Notification{Severity: "warning"}, settings)
+	if err == nil || !strings.Contains(err.Error(), "invalid body template") {
+		t.Errorf("Expected template error, got %v", err)
+	}
+
+	settings.Notifications.Webhook.URL = ""
+	if err := (webhookNotifier{}).Test(settings); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("Expected not configured error, got %v", err)
+	}
+}
+
+func TestParseHeaderLines(t *testing.T) {
+	headers := parseHeaderLines("authorization: Bearer abc\r\n\nX-Team:ops\nnot a header\n: empty name")
+
+	if len(headers) != 2 {
+		t.Fatalf("Expected 2 headers, got %v", headers)
+	}
+	if headers["Authorization"] != "Bearer abc" || headers["X-Team"] != "ops" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+
+	lines := WebhookSettings{Headers: headers}.HeaderLines()
+	if lines != "Authorization: Bearer abc\nX-Team: ops" {
+		t.Errorf("Unexpected header lines %q", lines)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+const outboxTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>SSL Monitor - Delivery Queue</title>
+    <style>
+        :root {
+            --bg-color: #f5f5f5;
+            --text-color: #333;
+            --text-secondary: #666;
+            --card-bg: white;
+            --border-color: #dee2e6;
+            --header-bg: #f8f9fa;
+            --nav-bg: #007cba;
+            --nav-hover-bg: #005a8b;
+            --shadow: rgba(0,0,0,0.1);
+        }
+
+        @media (prefers-color-scheme: dark) {
+            :root {
+                --bg-color: #1a1a1a;
+                --text-color: #e0e0e0;
+                --text-secondary: #b0b0b0;
+                --card-bg: #2d2d2d;
+                --border-color: #404040;
+                --header-bg: #3a3a3a;
+                --nav-bg: #0066a3;
+                --nav-hover-bg: #004d7a;
+                --shadow: rgba(0,0,0,0.3);
+            }
+        }
+
+        body {
+            font-family: Arial, sans-serif;
+            margin: 40px;
+            background-color: var(--bg-color);
+            color: var(--text-color);
+        }
+        .nav {
+            margin-bottom: 20px;
+        }
+        .nav a {
+            background: var(--nav-bg);
+            color: white;
+            padding: 8px 16px;
+            text-decoration: none;
+            border-radius: 4px;
+            margin-right: 10px;
+        }
+        .nav a:hover {
+            background: var(--nav-hover-bg);
+        }
+        .header, .card {
+            background: var(--card-bg);
+            padding: 20px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            box-shadow: 0 2px 4px var(--shadow);
+        }
+        h1 {
+            margin: 0 0 10px 0;
+        }
+        h2 {
+            margin-top: 0;
+        }
+        .subtitle {
+            color: var(--text-secondary);
+            font-size: 14px;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        th {
+            text-align: left;
+            padding: 10px;
+            background: var(--header-bg);
+            border-bottom: 1px solid var(--border-color);
+        }
+        td {
+            padding: 10px;
+            border-bottom: 1px solid var(--border-color);
+            vertical-align: top;
+        }
+        .error-message {
+            color: #dc3545;
+            font-style: italic;
+            word-break: break-all;
+        }
+        form {
+            display: inline;
+        }
+        button {
+            background: var(--nav-bg);
+            color: white;
+            border: none;
+            padding: 4px 10px;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 12px;
+        }
+        button.discard {
+            background: #6c757d;
+        }
+    </style>
+</head>
+<body>
+    <div class="nav">
+        <a href="/results">Results</a>
+        <a href="/sites">Sites</a>
+        <a href="/notifications">Notifications</a>
+        <a href="/settings">Settings</a>
+    </div>
+
+    <div class="header">
+        <h1>Delivery Queue</h1>
+        <div class="subtitle">Notifications a channel failed to deliver. They are retried with increasing delays, and marked failed after {{.Limit}} attempts.</div>
+    </div>
+
+    {{define "entries"}}
+        <table>
+            <thead>
+                <tr>
+                    <th>Site</th>
+                    <th>Severity</th>
+                    <th>Channel</th>
+                    <th>Attempts</th>
+                    <th>First Tried</th>
+                    <th>Last Attempt</th>
+                    <th>Next Attempt</th>
+                    <th>Last Error</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .}}
+                <tr>
+                    {{if .Batch}}
+                    <td>{{len .Batch.Notifications}} sites<div class="subtitle">{{range $i, $n := .Batch.Notifications}}{{if $i}}, {{end}}{{$n.Result.URL}}{{end}}</div></td>
+                    {{else}}
+                    <td>{{.Notification.Result.Name}}<div class="subtitle">{{.Notification.Result.URL}}</div></td>
+                    {{end}}
+                    <td>{{.Notification.Severity}}{{if .Notification.Reminder}} (reminder){{end}}</td>
+                    <td>{{.Channel}}</td>
+                    <td>{{.Attempts}}</td>
+                    <td>{{.Created.Format "2006-01-02 15:04"}}</td>
+                    <td>{{.LastAttempt.Format "2006-01-02 15:04"}}</td>
+                    <td>{{if .NextAttempt.IsZero}}&mdash;{{else}}{{.NextAttempt.Format "2006-01-02 15:04"}}{{end}}</td>
+                    <td class="error-message">{{.LastError}}</td>
+                    <td>
+                        <form method="POST" action="/outbox">
+                            <input type="hidden" name="id" value="{{.ID}}">
+                            <button type="submit" name="action" value="retry">Retry now</button>
+                            <button type="submit" name="action" value="discard" class="discard">Discard</button>
+                        </form>
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+    {{end}}
+
+    <div class="card">
+        <h2>Waiting to Retry</h2>
+        {{if .Pending}}
+            {{template "entries" .Pending}}
+        {{else}}
+            <p class="subtitle">Every notification has been delivered.</p>
+        {{end}}
+    </div>
+
+    <div class="card">
+        <h2>Failed</h2>
+        {{if .Dead}}
+            {{template "entries" .Dead}}
+        {{else}}
+            <p class="subtitle">No notifications have run out of retries.</p>
+        {{end}}
+    </div>
+</body>
+</html>`
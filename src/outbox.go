@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox entry statuses
+const (
+	outboxPending = "pending" // waiting for its next attempt
+	outboxDead    = "dead"    // gave up after the retry limit
+)
+
+// outboxCheckInterval is how often the outbox is checked for deliveries due
+// another attempt
+const outboxCheckInterval = time.Minute
+
+// Retries back off exponentially from outboxBaseDelay up to outboxMaxDelay
+const (
+	outboxBaseDelay = time.Minute
+	outboxMaxDelay  = 6 * time.Hour
+)
+
+// defaultDeliveryRetryLimit is how many times a delivery is tried, counting
+// the first send, before it is marked dead. With the backoff above the last
+// attempt is a little over four hours after the first.
+const defaultDeliveryRetryLimit = 9
+
+// OutboxEntry is a notification that one channel failed to deliver, kept in
+// the data directory until a retry succeeds
+type OutboxEntry struct {
+	ID           string             `json:"id"`
+	Channel      string             `json:"channel"`
+	Notification Notification       `json:"notification"`    // for a batch, only the severity and route are set
+	Batch        *NotificationBatch `json:"batch,omitempty"` // set when the failed send was a batch of sites
+	Status       string             `json:"status"`          // "pending" or "dead"
+	Attempts     int                `json:"attempts"`
+	Created      time.Time          `json:"created"`
+	LastAttempt  time.Time          `json:"last_attempt"`
+	NextAttempt  time.Time          `json:"next_attempt,omitempty"` // unset once dead
+	LastError    string             `json:"last_error"`
+}
+
+// Sites names what the entry is about, for logs
+func (e OutboxEntry) Sites() string {
+	if e.Batch != nil {
+		return fmt.Sprintf("%d sites", len(e.Batch.Notifications))
+	}
+	return e.Notification.Result.URL
+}
+
+// outboxMutex guards the outbox file. It is never held while the notification
+// state mutex is taken, as sends that fail during a scan queue into the outbox
+// with that mutex held.
+var outboxMutex sync.Mutex
+
+func getOutboxFilePath() string {
+	return filepath.Join(dataDirPath, "outbox.json")
+}
+
+func loadOutbox() ([]OutboxEntry, error) {
+	data, err := os.ReadFile(getOutboxFilePath())
+	if err != nil {
+		// Nothing has failed yet
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []OutboxEntry
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func saveOutbox(entries []OutboxEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getOutboxFilePath(), data, 0644)
+}
+
+func deliveryRetryLimit(settings Settings) int {
+	if settings.DeliveryRetryLimit > 0 {
+		return settings.DeliveryRetryLimit
+	}
+	return defaultDeliveryRetryLimit
+}
+
+// retryDelay is how long to wait after a delivery's nth failed attempt
+func retryDelay(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		return outboxMaxDelay
+	}
+	return delay
+}
+
+// queueDelivery adds a failed send to the outbox
+func queueDelivery(notifier Notifier, notification Notification, sendErr error, now time.Time) {
+	queueOutboxEntry(notifier, OutboxEntry{Notification: notification}, sendErr, now)
+}
+
+// queueBatchDelivery adds a failed batch to the outbox, to be retried as a batch
+func queueBatchDelivery(notifier Notifier, batch NotificationBatch, sendErr error, now time.Time) {
+	entry := OutboxEntry{
+		Notification: Notification{Severity: batch.Severity, Result: CertResult{Route: batch.Route}},
+		Batch:        &batch,
+	}
+	queueOutboxEntry(notifier, entry, sendErr, now)
+}
+
+// queueOutboxEntry records the first failed attempt of an entry. Channels that
+// aren't configured fail the same way every time, so they aren't retried.
+func queueOutboxEntry(notifier Notifier, entry OutboxEntry, sendErr error, now time.Time) {
+	if errors.Is(sendErr, errIncompleteSettings) {
+		return
+	}
+
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	entries, err := loadOutbox()
+	if err != nil {
+		LogError("Error loading outbox, %s notification for %s will not be retried: %v", notifier.Label(), entry.Sites(), err)
+		return
+	}
+
+	entry.ID = fmt.Sprintf("%s-%d-%d", notifier.Name(), now.UnixNano(), len(entries))
+	entry.Channel = notifier.Name()
+	entry.Status = outboxPending
+	entry.Attempts = 1
+	entry.Created = now
+	entry.LastAttempt = now
+	entry.NextAttempt = now.Add(retryDelay(1))
+	entry.LastError = sendErr.Error()
+	entries = append(entries, entry)
+
+	err = saveOutbox(entries)
+	if err != nil {
+		LogError("Error saving outbox, %s notification for %s will not be retried: %v", notifier.Label(), entry.Sites(), err)
+		return
+	}
+	LogInfo("Queued %s notification for %s to retry in %s", notifier.Label(), entry.Sites(), retryDelay(1))
+}
+
+// outboxAttempt is the outcome of retrying one entry
+type outboxAttempt struct {
+	id        string
+	reference string
+	err       error
+}
+
+// outboxFilter decides which queued notifications are still worth sending.
+// An alert is only current while the site is still in its status, and a
+// resolution isn't sent once the alert it follows has been dropped. Failing
+// nodes are current until they recover.
+type outboxFilter struct {
+	statuses     map[string]string // current status by site URL
+	nodeFailures map[string]int    // scans in a row with failing nodes, by site URL
+	dropped      map[string]bool   // channel and URL of dropped alerts
+}
+
+func newOutboxFilter(state NotificationState) outboxFilter {
+	filter := outboxFilter{statuses: make(map[string]string), nodeFailures: make(map[string]int), dropped: make(map[string]bool)}
+	for url, history := range state.NotificationHistory {
+		filter.statuses[url] = history.LastStatus
+		filter.nodeFailures[url] = history.NodeFailures
+	}
+	return filter
+}
+
+func (f outboxFilter) current(channel string, notification Notification) bool {
+	url := notification.Result.URL
+	key := channel + "\x00" + url
+	if notification.NodeFailure {
+		if failures, known := f.nodeFailures[url]; known && failures == 0 {
+			return false
+		}
+	} else if isAlertStatus(notification.Severity) {
+		if status, known := f.statuses[url]; known && status != notification.Severity {
+			f.dropped[key] = true
+			return false
+		}
+	} else if notification.Severity == "resolved" && f.dropped[key] {
+		return false
+	}
+	return true
+}
+
+// currentEntry trims an entry to the notifications that are still current,
+// returning false if none are
+func (f outboxFilter) currentEntry(entry OutboxEntry) (OutboxEntry, bool) {
+	if entry.Batch == nil {
+		return entry, f.current(entry.Channel, entry.Notification)
+	}
+	batch := *entry.Batch
+	batch.Notifications = nil
+	for _, notification := range entry.Batch.Notifications {
+		if f.current(entry.Channel, notification) {
+			batch.Notifications = append(batch.Notifications, notification)
+		}
+	}
+	entry.Batch = &batch
+	return entry, len(batch.Notifications) > 0
+}
+
+// processOutbox retries the deliveries that are due, oldest first. Once one
+// delivery to a channel fails the channel's later ones wait for the next
+// check, so a channel never receives a resolution before its alert. Alerts
+// for a status the site has since left are discarded rather than sent late.
+func processOutbox(settings Settings, now time.Time) error {
+	notificationStateMutex.Lock()
+	state, err := loadNotificationState()
+	notificationStateMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error loading notification state: %w", err)
+	}
+	filter := newOutboxFilter(state)
+
+	outboxMutex.Lock()
+	entries, err := loadOutbox()
+	outboxMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error loading outbox: %w", err)
+	}
+
+	// Send without holding the lock so a slow channel doesn't hold up scans
+	var attempts []outboxAttempt
+	superseded := make(map[string]bool)
+	failedChannels := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Status != outboxPending {
+			continue
+		}
+		entry, current := filter.currentEntry(entry)
+		if !current {
+			LogInfo("Discarding queued %s %s notification for %s, the site's status has changed", entry.Channel, entry.Notification.Severity, entry.Sites())
+			superseded[entry.ID] = true
+			continue
+		}
+		if entry.NextAttempt.After(now) || failedChannels[entry.Channel] {
+			continue
+		}
+
+		attempt := outboxAttempt{id: entry.ID}
+		notifier, found := findNotifier(entry.Channel)
+		if !found {
+			attempt.err = fmt.Errorf("unknown notification channel %q", entry.Channel)
+		} else {
+			channelSettings := settings
+			if route, routed := findRoute(settings, entry.Notification.Result.Route); routed {
+				channelSettings = routedSettings(route, notifier, settings)
+			}
+			LogInfo("Retrying %s notification for %s, attempt %d", notifier.Label(), entry.Sites(), entry.Attempts+1)
+			if entry.Batch != nil {
+				attempt.err = deliverBatch(notifier, *entry.Batch, channelSettings, entry.Attempts+1)
+			} else {
+				attempt.reference, attempt.err = deliverNotification(notifier, entry.Notification, channelSettings, entry.Attempts+1)
+			}
+		}
+		if attempt.err != nil {
+			failedChannels[entry.Channel] = true
+		}
+		attempts = append(attempts, attempt)
+	}
+	if len(attempts) == 0 && len(superseded) == 0 {
+		return nil
+	}
+
+	outboxMutex.Lock()
+	// Reload, entries may have been queued or discarded while sending
+	entries, err = loadOutbox()
+	if err != nil {
+		outboxMutex.Unlock()
+		return fmt.Errorf("error loading outbox: %w", err)
+	}
+	byID := make(map[string]outboxAttempt, len(attempts))
+	for _, attempt := range attempts {
+		byID[attempt.id] = attempt
+	}
+
+	limit := deliveryRetryLimit(settings)
+	var delivered []OutboxEntry
+	var remaining []OutboxEntry
+	for _, entry := range entries {
+		if superseded[entry.ID] {
+			continue
+		}
+		attempt, tried := byID[entry.ID]
+		if !tried {
+			remaining = append(remaining, entry)
+			continue
+		}
+		if attempt.err == nil {
+			if attempt.reference != "" {
+				entry.Notification.AlertReferences = map[string]string{entry.Channel: attempt.reference}
+			}
+			delivered = append(delivered, entry)
+			continue
+		}
+
+		entry.Attempts++
+		entry.LastAttempt = now
+		entry.LastError = attempt.err.Error()
+		if entry.Attempts >= limit {
+			entry.Status = outboxDead
+			entry.NextAttempt = time.Time{}
+			LogError("Giving up on %s notification for %s after %d attempts: %v", entry.Channel, entry.Sites(), entry.Attempts, attempt.err)
+		} else {
+			entry.NextAttempt = now.Add(retryDelay(entry.Attempts))
+		}
+		remaining = append(remaining, entry)
+	}
+
+	err = saveOutbox(remaining)
+	outboxMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error saving outbox: %w", err)
+	}
+
+	LogInfo("Outbox processing complete. Delivered %d of %d retries", len(delivered), len(attempts))
+	recordRetriedReferences(delivered)
+	return nil
+}
+
+// recordRetriedReferences keeps the message references of retried alerts that
+// are still current, so followups thread onto them
+func recordRetriedReferences(delivered []OutboxEntry) {
+	var threaded []OutboxEntry
+	for _, entry := range delivered {
+		if len(entry.Notification.AlertReferences) > 0 && isAlertStatus(entry.Notification.Severity) {
+			threaded = append(threaded, entry)
+		}
+	}
+	if len(threaded) == 0 {
+		return
+	}
+
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		LogError("Error loading notification state for retried notifications: %v", err)
+		return
+	}
+	for _, entry := range threaded {
+		url := entry.Notification.Result.URL
+		history, exists := state.NotificationHistory[url]
+		if !exists || history.LastStatus != entry.Notification.Severity {
+			continue
+		}
+		history.AlertReferences = mergeAlertReferences(history.AlertReferences, entry.Notification.AlertReferences)
+		state.NotificationHistory[url] = history
+	}
+	err = saveNotificationState(state)
+	if err != nil {
+		LogError("Error saving notification state for retried notifications: %v", err)
+	}
+}
+
+// runOutbox retries failed deliveries until the process exits
+func runOutbox(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settings, err := loadSettings()
+		if err != nil {
+			LogError("Error loading settings for notification retries: %v", err)
+			continue
+		}
+		err = processOutbox(settings, time.Now())
+		if err != nil {
+			LogError("Error processing notification retries: %v", err)
+		}
+	}
+}
+
+// OutboxPageData is the delivery queue page
+type OutboxPageData struct {
+	Pending []OutboxEntry
+	Dead    []OutboxEntry
+	Limit   int
+}
+
+// outboxHandler shows the deliveries waiting to be retried and those that gave
+// up. Posting action "retry" with an id tries it again at the next check and
+// "discard" removes it.
+func outboxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		updateOutboxEntry(w, r)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		http.Error(w, "Error loading settings", http.StatusInternalServerError)
+		return
+	}
+
+	outboxMutex.Lock()
+	entries, err := loadOutbox()
+	outboxMutex.Unlock()
+	if err != nil {
+		LogError("Error loading outbox: %v", err)
+		http.Error(w, "Error loading outbox", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := OutboxPageData{Limit: deliveryRetryLimit(settings)}
+	for _, entry := range entries {
+		if entry.Status == outboxDead {
+			pageData.Dead = append(pageData.Dead, entry)
+		} else {
+			pageData.Pending = append(pageData.Pending, entry)
+		}
+	}
+
+	parsedTemplate := template.Must(template.New("outbox").Parse(outboxTemplate))
+	parsedTemplate.Execute(w, pageData)
+}
+
+func updateOutboxEntry(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	action := r.FormValue("action")
+	if action != "retry" && action != "discard" {
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	entries, err := loadOutbox()
+	if err != nil {
+		LogError("Error loading outbox: %v", err)
+		http.Error(w, "Error loading outbox", http.StatusInternalServerError)
+		return
+	}
+
+	found := false
+	var remaining []OutboxEntry
+	for _, entry := range entries {
+		if entry.ID != id {
+			remaining = append(remaining, entry)
+			continue
+		}
+		found = true
+		if action == "discard" {
+			LogInfo("Discarded %s notification for %s from the outbox", entry.Channel, entry.Sites())
+			continue
+		}
+		if entry.Status == outboxDead {
+			// Start counting again so it gets a full set of retries
+			entry.Attempts = 0
+		}
+		entry.Status = outboxPending
+		entry.NextAttempt = time.Now()
+		LogInfo("Retrying %s notification for %s at the next check", entry.Channel, entry.Sites())
+		remaining = append(remaining, entry)
+	}
+	if !found {
+		http.Error(w, "Outbox entry not found", http.StatusNotFound)
+		return
+	}
+
+	err = saveOutbox(remaining)
+	if err != nil {
+		LogError("Error saving outbox: %v", err)
+		http.Error(w, "Error saving outbox", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/outbox", http.StatusSeeOther)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, outboxMaxDelay},
+		{100, outboxMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.delay {
+			t.Errorf("retryDelay(%d): expected %s, got %s", tt.attempts, tt.delay, got)
+		}
+	}
+}
+
+func TestUnconfiguredChannelsAreNotRetried(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	// Every real channel enabled, none of them configured
+	var settings Settings
+	settings.Notifications.Email.EnabledWarning = true
+	settings.Notifications.Ntfy.EnabledWarning = true
+	settings.Notifications.Webhook.EnabledWarning = true
+	settings.Notifications.Slack.EnabledWarning = true
+	settings.Notifications.Teams.EnabledWarning = true
+	settings.Notifications.Discord.EnabledWarning = true
+
+	notification := Notification{Severity: "warning", Result: CertResult{URL: "example.com", DaysLeft: 20}}
+	for _, notifier := range notifiers {
+		if _, err := notifier.Send(notification, settings); !errors.Is(err, errIncompleteSettings) {
+			t.Errorf("Expected %s Send to report incomplete settings, got %v", notifier.Name(), err)
+		}
+		if threaded, ok := notifier.(threadedNotifier); ok {
+			if _, _, err := threaded.SendThreaded(notification, settings); !errors.Is(err, errIncompleteSettings) {
+				t.Errorf("Expected %s SendThreaded to report incomplete settings, got %v", notifier.Name(), err)
+			}
+		}
+	}
+
+	if sent := notifyAll(notification, settings); sent != 0 {
+		t.Fatalf("Expected nothing sent, got %d", sent)
+	}
+	entries, err := loadOutbox()
+	if err != nil {
+		t.Fatalf("Error loading outbox: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no unconfigured channel to be queued, got %+v", entries)
+	}
+}
+
+func TestFailedDeliveryIsRetried(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"warning": true}, sendErr: errors.New("503 Service Unavailable")}
+	unconfigured := &fakeNotifier{name: "unconfigured", enabled: map[string]bool{"warning": true}, sendErr: fmt.Errorf("%w (URL not configured)", errIncompleteSettings)}
+	useFakeNotifiers(t, fake, unconfigured)
+
+	if sent := notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "example.com"}}, Settings{}); sent != 0 {
+		t.Fatalf("Expected the send to fail, got %d sent", sent)
+	}
+
+	entries, err := loadOutbox()
+	if err != nil {
+		t.Fatalf("Error loading outbox: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the configured channel to be queued, got %d entries", len(entries))
+	}
+	entry := entries[0]
+	if entry.Channel != "fake" || entry.Status != outboxPending || entry.Attempts != 1 || entry.LastError != "503 Service Unavailable" {
+		t.Errorf("Unexpected outbox entry: %+v", entry)
+	}
+
+	// Not due yet
+	if err := processOutbox(Settings{}, entry.Created.Add(30*time.Second)); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(fake.received) != 1 {
+		t.Fatalf("Expected no retry before the backoff, got %d sends", len(fake.received))
+	}
+
+	// Still failing, backs off further
+	if err := processOutbox(Settings{}, entry.NextAttempt); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	entries, _ = loadOutbox()
+	if len(entries) != 1 || entries[0].Attempts != 2 || !entries[0].NextAttempt.Equal(entry.NextAttempt.Add(2*time.Minute)) {
+		t.Fatalf("Expected a second attempt retried 2 minutes later, got %+v", entries)
+	}
+
+	fake.sendErr = nil
+	if err := processOutbox(Settings{}, entries[0].NextAttempt); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(fake.received) != 3 || fake.received[2].Result.URL != "example.com" {
+		t.Fatalf("Expected the notification to be delivered on the third attempt, got %d sends", len(fake.received))
+	}
+	entries, _ = loadOutbox()
+	if len(entries) != 0 {
+		t.Errorf("Expected the delivered notification to leave the outbox, got %+v", entries)
+	}
+}
+
+func TestOutboxGivesUp(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	fake := &fakeNotifier{name: "fake", enabled: map[string]bool{"warning": true, "resolved": true}, sendErr: errors.New("connection refused")}
+	useFakeNotifiers(t, fake)
+	settings := Settings{DeliveryRetryLimit: 2}
+
+	notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "example.com"}}, settings)
+	notifyAll(Notification{Severity: "resolved", Result: CertResult{URL: "example.com"}}, settings)
+	entries, _ := loadOutbox()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 queued notifications, got %d", len(entries))
+	}
+
+	// The resolution waits behind the alert when the alert fails again
+	later := entries[1].NextAttempt
+	if err := processOutbox(settings, later); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(fake.received) != 3 || fake.received[2].Severity != "warning" {
+		t.Fatalf("Expected only the alert to be retried, got %d sends", len(fake.received))
+	}
+
+	entries, _ = loadOutbox()
+	if entries[0].Status != outboxDead || !entries[0].NextAttempt.IsZero() {
+		t.Errorf("Expected the alert to be dead after 2 attempts, got %+v", entries[0])
+	}
+	if entries[1].Status != outboxPending || entries[1].Attempts != 1 {
+		t.Errorf("Expected the resolution to still be pending, got %+v", entries[1])
+	}
+
+	if err := processOutbox(settings, later.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(fake.received) != 4 || fake.received[3].Severity != "resolved" {
+		t.Errorf("Expected dead entries not to be retried, got %d sends", len(fake.received))
+	}
+}
+
+func TestOutboxDiscardsSupersededAlerts(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	flaky := &fakeNotifier{name: "flaky", enabled: map[string]bool{"warning": true, "resolved": true}, sendErr: errors.New("connection refused")}
+	batching := &batchFakeNotifier{fakeNotifier: &fakeNotifier{name: "batching", enabled: map[string]bool{"critical": true}, sendErr: errors.New("timeout")}}
+	useFakeNotifiers(t, flaky)
+	registerNotifier(batching)
+
+	// The warning fails, then the site is renewed and the resolution fails too
+	notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "renewed.example.com"}}, Settings{})
+	notifyAll(Notification{Severity: "resolved", PreviousStatus: "warning", Result: CertResult{URL: "renewed.example.com"}}, Settings{})
+	critical := func(url string) Notification {
+		return Notification{Severity: "critical", Result: CertResult{URL: url}}
+	}
+	notifyBatched([]Notification{critical("renewed.example.com"), critical("down.example.com")}, Settings{})
+
+	err := saveNotificationState(NotificationState{NotificationHistory: map[string]NotificationHistory{
+		"renewed.example.com": {LastStatus: "normal"},
+		"down.example.com":    {LastStatus: "critical"},
+	}})
+	if err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+
+	flaky.sendErr = nil
+	batching.sendErr = nil
+	if err := processOutbox(Settings{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Error processing outbox: %v", err)
+	}
+	if len(flaky.received) != 2 {
+		t.Errorf("Expected the stale warning and its resolution to be discarded, got %+v", flaky.received[2:])
+	}
+	if len(batching.batches) != 2 || batchSites(batching.batches[1]) != "down.example.com" {
+		t.Errorf("Expected the batch retried for the site still critical only, got %+v", batching.batches)
+	}
+	if entries, _ := loadOutbox(); len(entries) != 0 {
+		t.Errorf("Expected the outbox to be empty, got %+v", entries)
+	}
+}
+
+func postOutbox(t *testing.T, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/outbox", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	outboxHandler(w, r)
+	return w
+}
+
+func TestOutboxHandler(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
+
+	failed := time.Now().Add(-6 * time.Hour)
+	err := saveOutbox([]OutboxEntry{
+		{ID: "fake-1", Channel: "fake", Status: outboxDead, Attempts: 9, Created: failed, LastAttempt: failed, LastError: "401 Unauthorized",
+			Notification: Notification{Severity: "critical", Result: CertResult{URL: "dead.example.com", Name: "Dead"}}},
+		{ID: "fake-2", Channel: "fake", Status: outboxPending, Attempts: 1, Created: failed, LastAttempt: failed, NextAttempt: failed.Add(time.Minute),
+			Notification: Notification{Severity: "warning", Result: CertResult{URL: "pending.example.com", Name: "Pending"}}},
+	})
+	if err != nil {
+		t.Fatalf("Error saving outbox: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	outboxHandler(w, httptest.NewRequest("GET", "/outbox", nil))
+	body := w.Body.String()
+	for _, want := range []string{"dead.example.com", "401 Unauthorized", "pending.example.com"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the delivery queue to show %q", want)
+		}
+	}
+
+	if w := postOutbox(t, url.Values{"id": {"fake-1"}, "action": {"retry"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected redirect after retry, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postOutbox(t, url.Values{"id": {"fake-2"}, "action": {"discard"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected redirect after discard, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postOutbox(t, url.Values{"id": {"fake-2"}, "action": {"discard"}}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a discarded entry, got %d", w.Code)
+	}
+
+	entries, _ := loadOutbox()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry after discarding, got %d", len(entries))
+	}
+	if entries[0].Status != outboxPending || entries[0].Attempts != 0 || entries[0].NextAttempt.After(time.Now()) {
+		t.Errorf("Expected the dead entry to be due again with fresh attempts, got %+v", entries[0])
+	}
+}
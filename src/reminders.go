@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// reminderCheckInterval is how often due reminders are looked for, independent
+// of the scan interval
+const reminderCheckInterval = time.Hour
+
+// ReminderSettings repeats an alert while a site stays in the same status, and
+// escalates it when a critical site still hasn't been fixed
+type ReminderSettings struct {
+	WarningHours       int      `json:"warning_hours"` // 0 sends no reminders
+	CriticalHours      int      `json:"critical_hours"`
+	ErrorHours         int      `json:"error_hours"`
+	EscalateAfter      int      `json:"escalate_after"`                // reminders while critical before escalating, 0 never escalates
+	EscalationChannels []string `json:"escalation_channels,omitempty"` // channels that also get escalated reminders
+	EscalationEmailTo  string   `json:"escalation_email_to,omitempty"` // recipients added to escalated emails
+}
+
+// reminderInterval is how long to wait between reminders for a status, zero
+// when reminders are off
+func reminderInterval(settings Settings, status string) time.Duration {
+	var hours int
+	switch status {
+	case "warning":
+		hours = settings.Reminders.WarningHours
+	case "critical":
+		hours = settings.Reminders.CriticalHours
+	case "error":
+		hours = settings.Reminders.ErrorHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// isEscalationChannel reports whether a channel was added for escalations
+func isEscalationChannel(settings Settings, name string) bool {
+	return containsString(settings.Reminders.EscalationChannels, name)
+}
+
+// reminderPrefix marks repeated alerts in titles and subjects
+func reminderPrefix(notification Notification) string {
+	if notification.Escalated {
+		return "Escalated: "
+	}
+	if notification.Reminder > 0 {
+		return "Reminder: "
+	}
+	return ""
+}
+
+// processReminders repeats the alert for every site that has stayed in an
+// alerting status for longer than its reminder interval
+func processReminders(results ScanResults, settings Settings, now time.Time) error {
+	notificationStateMutex.Lock()
+	defer notificationStateMutex.Unlock()
+
+	state, err := loadNotificationState()
+	if err != nil {
+		return fmt.Errorf("error loading notification state: %w", err)
+	}
+
+	latest := make(map[string]CertResult, len(results.Results))
+	for _, result := range results.Results {
+		latest[result.URL] = result
+	}
+
+	remindersSent := 0
+	changed := false
+	holds := newNotificationHolds(state, settings, now)
+
+	for url, history := range state.NotificationHistory {
+		interval := reminderInterval(settings, history.LastStatus)
+		if interval == 0 || !isAlertStatus(history.LastStatus) {
+			continue
+		}
+		if history.Acknowledgement != nil {
+			// Someone is already on it
+			continue
+		}
+
+		result, found := latest[url]
+		if !found {
+			// Site was removed since the alert
+			continue
+		}
+
+		// State saved before reminders existed has no send time, count from the scan
+		lastNotified := history.LastNotified
+		if lastNotified.IsZero() {
+			lastNotified = history.LastScan
+		}
+		if now.Sub(lastNotified) < interval {
+			continue
+		}
+
+		// Reminders only repeat the alert, so they're skipped rather than queued
+		// while held. The next check after the hold ends sends one.
+		if reason := holds.reason(Notification{Severity: history.LastStatus, Result: result}); reason != "" {
+			LogDebug("Reminder for %s held (%s)", url, reason)
+			continue
+		}
+
+		history.Reminders++
+		escalateAfter := settings.Reminders.EscalateAfter
+		escalated := history.LastStatus == "critical" && escalateAfter > 0 && history.Reminders > escalateAfter
+		if escalated && !history.Escalated {
+			LogWarning("%s is still critical after %d reminders, escalating", url, escalateAfter)
+		}
+
+		LogInfo("Sending reminder %d for %s (%s)", history.Reminders, url, history.LastStatus)
+		sent, sentReferences := notifyAllWithReferences(Notification{
+			Severity:        history.LastStatus,
+			PreviousStatus:  history.LastStatus,
+			Result:          result,
+			Failures:        history.ConsecutiveFailures,
+			AlertReferences: history.AlertReferences,
+			Reminder:        history.Reminders,
+			Escalated:       escalated,
+		}, settings)
+		remindersSent += sent
+
+		history.AlertReferences = mergeAlertReferences(history.AlertReferences, sentReferences)
+		history.LastNotified = now
+		history.Escalated = escalated
+		state.NotificationHistory[url] = history
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	err = saveNotificationState(state)
+	if err != nil {
+		return fmt.Errorf("error saving notification state: %w", err)
+	}
+
+	LogInfo("Reminder processing complete. Sent %d notifications", remindersSent)
+	return nil
+}
+
+// runReminderChecks sends due reminders until the process exits
+func runReminderChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settings, err := loadSettings()
+		if err != nil {
+			LogError("Error loading settings for reminders: %v", err)
+			continue
+		}
+		results, err := loadResults()
+		if err != nil {
+			LogError("Error loading results for reminders: %v", err)
+			continue
+		}
+		sites, err := loadSites()
+		if err != nil {
+			LogError("Error loading sites for reminders: %v", err)
+			continue
+		}
+		applySiteRoutes(&results, sites)
+
+		err = processReminders(results, settings, time.Now())
+		if err != nil {
+			LogError("Error processing reminders: %v", err)
+		}
+	}
+}
+
+// parseReminderForm copies the reminder and escalation fields from the settings form
+func parseReminderForm(r *http.Request, settings *Settings) {
+	reminders := &settings.Reminders
+	fields := []struct {
+		name  string
+		value *int
+	}{
+		{"reminder_warning_hours", &reminders.WarningHours},
+		{"reminder_critical_hours", &reminders.CriticalHours},
+		{"reminder_error_hours", &reminders.ErrorHours},
+		{"reminder_escalate_after", &reminders.EscalateAfter},
+	}
+	for _, field := range fields {
+		if val := r.FormValue(field.name); val != "" {
+			if number := parseInt(val); number >= 0 {
+				*field.value = number
+			}
+		}
+	}
+
+	if _, ok := r.Form["reminder_escalation_channels"]; ok {
+		reminders.EscalationChannels = parseChannelList(r.FormValue("reminder_escalation_channels"))
+	}
+	if _, ok := r.Form["reminder_escalation_email_to"]; ok {
+		reminders.EscalationEmailTo = strings.TrimSpace(r.FormValue("reminder_escalation_email_to"))
+	}
+
+	LogDebug("Updated reminders: warning=%dh, critical=%dh, error=%dh, escalate after %d",
+		reminders.WarningHours, reminders.CriticalHours, reminders.ErrorHours, reminders.EscalateAfter)
+}
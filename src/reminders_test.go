@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessReminders(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	regular := &fakeNotifier{name: "regular", enabled: map[string]bool{"warning": true, "critical": true}}
+	escalation := &fakeNotifier{name: "escalation", enabled: map[string]bool{}}
+	useFakeNotifiers(t, regular, escalation)
+
+	var settings Settings
+	settings.Reminders = ReminderSettings{
+		CriticalHours:      24,
+		EscalateAfter:      1,
+		EscalationChannels: []string{"escalation"},
+	}
+
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	err := saveNotificationState(NotificationState{
+		NotificationHistory: map[string]NotificationHistory{
+			"critical.example.com": {LastStatus: "critical", LastScan: start, LastNotified: start},
+			"warning.example.com":  {LastStatus: "warning", LastScan: start, LastNotified: start},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+	results := ScanResults{Results: []CertResult{
+		{URL: "critical.example.com", DaysLeft: 3},
+		{URL: "warning.example.com", DaysLeft: 20},
+	}}
+
+	remind := func(now time.Time) {
+		t.Helper()
+		if err := processReminders(results, settings, now); err != nil {
+			t.Fatalf("processReminders returned error: %v", err)
+		}
+	}
+
+	remind(start.Add(12 * time.Hour))
+	if len(regular.received) != 0 {
+		t.Fatalf("Expected no reminder before the interval, got %+v", regular.received)
+	}
+
+	remind(start.Add(24 * time.Hour))
+	if len(regular.received) != 1 {
+		t.Fatalf("Expected one reminder, got %+v", regular.received)
+	}
+	first := regular.received[0]
+	if first.Result.URL != "critical.example.com" || first.Severity != "critical" || first.Reminder != 1 || first.Escalated {
+		t.Errorf("Unexpected first reminder %+v", first)
+	}
+	if len(escalation.received) != 0 {
+		t.Error("Escalation channel should not get reminders before escalating")
+	}
+
+	// Counting restarts from the last reminder, not the first alert
+	remind(start.Add(36 * time.Hour))
+	if len(regular.received) != 1 {
+		t.Errorf("Expected no reminder 12h after the last one, got %d", len(regular.received))
+	}
+
+	remind(start.Add(48 * time.Hour))
+	if len(regular.received) != 2 || !regular.received[1].Escalated || regular.received[1].Reminder != 2 {
+		t.Fatalf("Expected an escalated second reminder, got %+v", regular.received)
+	}
+	if len(escalation.received) != 1 {
+		t.Errorf("Expected the escalation channel to be added, got %d notifications", len(escalation.received))
+	}
+
+	state, err := loadNotificationState()
+	if err != nil {
+		t.Fatalf("Error loading state: %v", err)
+	}
+	history := state.NotificationHistory["critical.example.com"]
+	if history.Reminders != 2 || !history.Escalated || !history.LastNotified.Equal(start.Add(48*time.Hour)) {
+		t.Errorf("Expected reminders to be tracked in the state, got %+v", history)
+	}
+}
+
+func TestProcessNotificationsResetsReminders(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	useFakeNotifiers(t)
+
+	err := saveNotificationState(NotificationState{
+		NotificationHistory: map[string]NotificationHistory{
+			"example.com": {LastStatus: "critical", LastNotified: time.Now().Add(-72 * time.Hour), Reminders: 3, Escalated: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+
+	var settings Settings
+	settings.Dashboard.ColorThresholds.Warning = 28
+	settings.Dashboard.ColorThresholds.Critical = 7
+	err = processNotifications(ScanResults{LastScan: time.Now(), Results: []CertResult{{URL: "example.com", DaysLeft: 90}}}, settings)
+	if err != nil {
+		t.Fatalf("processNotifications returned error: %v", err)
+	}
+
+	state, _ := loadNotificationState()
+	history := state.NotificationHistory["example.com"]
+	if history.Reminders != 0 || history.Escalated || !history.LastNotified.IsZero() {
+		t.Errorf("Expected reminders to reset after recovery, got %+v", history)
+	}
+}
+
+func TestEscalatedEmailRecipients(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+	}))
+	defer server.Close()
+
+	originalURL := postmarkAPIURL
+	postmarkAPIURL = server.URL
+	defer func() { postmarkAPIURL = originalURL }()
+
+	var settings Settings
+	settings.Notifications.Email = EmailSettings{ServerToken: "token", From: "monitor@example.com", To: "ops@example.com"}
+	settings.Reminders.EscalationEmailTo = "manager@example.com"
+
+	notifier, _ := findNotifier("email")
+	_, err := notifier.Send(Notification{
+		Severity:  "critical",
+		Result:    CertResult{URL: "example.com", Name: "Example", DaysLeft: 2},
+		Reminder:  4,
+		Escalated: true,
+	}, settings)
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	if sent["To"] != "ops@example.com, manager@example.com" {
+		t.Errorf("Expected escalation recipients to be added, got %v", sent["To"])
+	}
+	if subject, _ := sent["Subject"].(string); !strings.HasPrefix(subject, "Escalated: SSL Certificate Critical") {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+}
+
+func TestParseReminderForm(t *testing.T) {
+	form := url.Values{
+		"reminder_critical_hours":      {"24"},
+		"reminder_escalate_after":      {"3"},
+		"reminder_escalation_channels": {"Slack, pager, email"},
+		"reminder_escalation_email_to": {" manager@example.com "},
+	}
+	r := httptest.NewRequest("POST", "/settings", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ParseForm()
+
+	var settings Settings
+	parseReminderForm(r, &settings)
+
+	reminders := settings.Reminders
+	if reminders.CriticalHours != 24 || reminders.EscalateAfter != 3 || reminders.WarningHours != 0 {
+		t.Errorf("Unexpected reminder intervals %+v", reminders)
+	}
+	if strings.Join(reminders.EscalationChannels, ",") != "slack,email" {
+		t.Errorf("Expected unknown channels to be dropped, got %v", reminders.EscalationChannels)
+	}
+	if reminders.EscalationEmailTo != "manager@example.com" {
+		t.Errorf("Unexpected escalation recipients %q", reminders.EscalationEmailTo)
+	}
+}
@@ -123,6 +123,13 @@ const resultsTemplate = `
             color: var(--text-secondary);
             font-size: 14px; 
         }
+        .site-link {
+            color: inherit;
+            text-decoration: none;
+        }
+        .site-link:hover {
+            text-decoration: underline;
+        }
         .days-left {
             font-weight: 600;
             font-size: 16px;
@@ -132,6 +139,20 @@ const resultsTemplate = `
             font-style: italic;
             font-size: 14px;
         }
+        .verify-badge {
+            display: inline-block;
+            margin-left: 6px;
+            padding: 2px 6px;
+            border-radius: 4px;
+            font-size: 12px;
+            background: var(--warning-bg);
+            color: var(--warning-text);
+            border: 1px solid var(--warning-border);
+        }
+        .verify-message {
+            color: var(--warning-text);
+            font-size: 14px;
+        }
         .expiry-date {
             color: var(--text-secondary);
         }
@@ -196,6 +217,32 @@ const resultsTemplate = `
             cursor: not-allowed;
             opacity: 0.7;
         }
+        .held-notice {
+            background: var(--warning-bg);
+            border: 1px solid var(--warning-border);
+            border-radius: 8px;
+            margin-bottom: 20px;
+            padding: 15px 20px;
+            color: var(--warning-text);
+        }
+        .snooze-form {
+            display: flex;
+            gap: 5px;
+            margin: 0;
+        }
+        .acknowledged {
+            font-size: 13px;
+            color: var(--text-secondary);
+            margin-bottom: 4px;
+        }
+        .btn-snooze {
+            border: 1px solid var(--warning-border);
+            background: transparent;
+            color: inherit;
+            padding: 4px 8px;
+            border-radius: 4px;
+            cursor: pointer;
+        }
         
         /* Scanning status styles */
         .scanning-status {
@@ -243,6 +290,18 @@ const resultsTemplate = `
         }
     </style>
     
+    <script>
+        // Remember who is acknowledging alerts on this browser
+        document.addEventListener('DOMContentLoaded', function() {
+            document.querySelectorAll('.ack-form').forEach(function(form) {
+                form.by.value = localStorage.getItem('ssl-monitor-ack-by') || '';
+                form.addEventListener('submit', function() {
+                    localStorage.setItem('ssl-monitor-ack-by', form.by.value);
+                });
+            });
+        });
+    </script>
+
     {{if .IsScanning}}
     <script>
         // Auto-refresh every 3 seconds while scanning
@@ -256,6 +315,7 @@ const resultsTemplate = `
     <div class="nav">
         <a href="/results" class="active">Results</a>
         <a href="/sites">Sites</a>
+        <a href="/notifications">Notifications</a>
         <a href="/settings">Settings</a>
     </div>
 
@@ -289,6 +349,22 @@ const resultsTemplate = `
     </div>
     {{end}}
 
+    {{if or .QuietHours .HeldCount}}
+    <div class="held-notice">
+        {{if .QuietHours}}<strong>🔕 Quiet hours</strong> until {{.Settings.QuietHours.End}}{{if .Settings.QuietHours.Timezone}} ({{.Settings.QuietHours.Timezone}}){{end}}{{if not .Settings.QuietHours.HoldCritical}}, critical alerts are still sent{{end}}.{{end}}
+        {{if .HeldCount}}{{.HeldCount}} notification(s) held, to be sent when their hold ends.{{end}}
+    </div>
+    {{end}}
+
+    {{if or .RetryCount .DeadCount}}
+    <div class="held-notice">
+        <strong>⚠️ Notification delivery problems:</strong>
+        {{if .RetryCount}}{{.RetryCount}} waiting to be retried.{{end}}
+        {{if .DeadCount}}{{.DeadCount}} failed after every retry.{{end}}
+        <a href="/outbox">View the delivery queue</a>
+    </div>
+    {{end}}
+
     <div class="results-container">
         {{if eq (len .Results) 0}}
             <div class="no-results">
@@ -304,14 +380,15 @@ const resultsTemplate = `
                         <th>Days Left</th>
                         <th>Expires</th>
                         <th>Last Check</th>
+                        <th>Notifications</th>
                     </tr>
                 </thead>
                 <tbody>
                     {{range .Results}}
                     <tr>
                         <td>
-                            <div class="site-name">{{.Name}}</div>
-                            <div class="url">{{.URL}}</div>
+                            <div class="site-name"><a class="site-link" href="/results/site?url={{.URL}}">{{.Name}}</a></div>
+                            <div class="url">{{.URL}} &middot; <a class="site-link" href="/results/history?url={{.URL}}">history</a></div>
                         </td>
                         <td>
                             <span class="status-indicator {{.ColorClass}}"></span>
@@ -324,12 +401,24 @@ const resultsTemplate = `
                             {{else}}
                                 Good
                             {{end}}
+                            {{if .HasVerifyError}}
+                                <span class="verify-badge">⚠️ {{.VerifyLabel}}</span>
+                            {{end}}
+                            {{if .Inconsistent}}
+                                <span class="verify-badge" title="Different nodes behind this site serve different certificates">⚠️ Nodes differ</span>
+                            {{end}}
+                            {{if .FailedAddresses}}
+                                <span class="verify-badge" title="Some of the nodes behind this site could not be checked">⚠️ {{.FailedAddresses}} node{{if gt .FailedAddresses 1}}s{{end}} failing</span>
+                            {{end}}
                         </td>
                         <td>
                             {{if .HasError}}
                                 <span class="error-message">Unknown</span>
                             {{else}}
                                 <span class="days-left">{{.DaysLeft}}</span>
+                                {{if .ExpiringElement}}
+                                    <div class="url">{{.ExpiringElement}}</div>
+                                {{end}}
                             {{end}}
                         </td>
                         <td>
@@ -340,13 +429,52 @@ const resultsTemplate = `
                             {{end}}
                         </td>
                         <td>{{.LastCheck.Format "2006-01-02 15:04"}}</td>
+                        <td>
+                            {{if not .MaintenanceUntil.IsZero}}
+                                <div class="url">🔧 Maintenance until {{.MaintenanceUntil.Format "2006-01-02 15:04"}}</div>
+                            {{end}}
+                            {{if .SnoozedUntil.IsZero}}
+                            <form method="post" action="/results/snooze" class="snooze-form">
+                                <input type="hidden" name="url" value="{{.URL}}">
+                                <input type="datetime-local" name="until" required>
+                                <button type="submit" class="btn-snooze">Snooze</button>
+                            </form>
+                            {{else}}
+                            <div class="url">🔕 Snoozed until {{.SnoozedUntil.Format "2006-01-02 15:04"}}</div>
+                            <form method="post" action="/results/snooze" class="snooze-form">
+                                <input type="hidden" name="url" value="{{.URL}}">
+                                <button type="submit" class="btn-snooze">Unsnooze</button>
+                            </form>
+                            {{end}}
+                            {{if .Acknowledgement}}
+                            <div class="acknowledged">✔ Acknowledged by {{.Acknowledgement.By}} at {{.Acknowledgement.At.Format "2006-01-02 15:04"}}{{if .Acknowledgement.Note}}: {{.Acknowledgement.Note}}{{end}}</div>
+                            <form method="post" action="/results/acknowledge" class="snooze-form">
+                                <input type="hidden" name="url" value="{{.URL}}">
+                                <input type="hidden" name="action" value="unacknowledge">
+                                <button type="submit" class="btn-snooze">Remove acknowledgement</button>
+                            </form>
+                            {{else if .Alerting}}
+                            <form method="post" action="/results/acknowledge" class="snooze-form ack-form">
+                                <input type="hidden" name="url" value="{{.URL}}">
+                                <input type="text" name="by" placeholder="Your name" required>
+                                <input type="text" name="note" placeholder="Note (optional)">
+                                <button type="submit" class="btn-snooze">Acknowledge</button>
+                            </form>
+                            {{end}}
+                        </td>
                     </tr>
                     {{if .HasError}}
                     <tr>
-                        <td colspan="5">
+                        <td colspan="6">
                             <div class="error-message">Error: {{.Error}}</div>
                         </td>
                     </tr>
+                    {{else if .HasVerifyError}}
+                    <tr>
+                        <td colspan="6">
+                            <div class="verify-message">Verification failed: {{.VerifyError}}</div>
+                        </td>
+                    </tr>
                     {{end}}
                     {{end}}
                 </tbody>
@@ -354,4 +482,279 @@ const resultsTemplate = `
         {{end}}
     </div>
 </body>
-</html>`
\ No newline at end of file
+</html>`
+
+const resultDetailTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>SSL Monitor - {{.Result.Name}}</title>
+    <style>
+        :root {
+            --bg-color: #f5f5f5;
+            --text-color: #333;
+            --text-secondary: #666;
+            --card-bg: white;
+            --border-color: #dee2e6;
+            --header-bg: #f8f9fa;
+            --nav-bg: #007cba;
+            --nav-hover-bg: #005a8b;
+            --warning-bg: #fff3cd;
+            --warning-border: #ffeaa7;
+            --warning-text: #856404;
+            --shadow: rgba(0,0,0,0.1);
+        }
+
+        @media (prefers-color-scheme: dark) {
+            :root {
+                --bg-color: #1a1a1a;
+                --text-color: #e0e0e0;
+                --text-secondary: #b0b0b0;
+                --card-bg: #2d2d2d;
+                --border-color: #404040;
+                --header-bg: #3a3a3a;
+                --nav-bg: #0066a3;
+                --nav-hover-bg: #004d7a;
+                --warning-bg: #3d3516;
+                --warning-border: #5a4b1a;
+                --warning-text: #d4c069;
+                --shadow: rgba(0,0,0,0.3);
+            }
+        }
+
+        body {
+            font-family: Arial, sans-serif;
+            margin: 40px;
+            background-color: var(--bg-color);
+            color: var(--text-color);
+        }
+        .nav {
+            margin-bottom: 20px;
+        }
+        .nav a {
+            background: var(--nav-bg);
+            color: white;
+            padding: 8px 16px;
+            text-decoration: none;
+            border-radius: 4px;
+            margin-right: 10px;
+        }
+        .nav a:hover {
+            background: var(--nav-hover-bg);
+        }
+        .header, .card {
+            background: var(--card-bg);
+            padding: 20px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            box-shadow: 0 2px 4px var(--shadow);
+        }
+        h1 {
+            margin: 0 0 10px 0;
+        }
+        h2 {
+            margin-top: 0;
+        }
+        .subtitle {
+            color: var(--text-secondary);
+            font-size: 14px;
+        }
+        .status-indicator {
+            width: 12px;
+            height: 12px;
+            border-radius: 50%;
+            display: inline-block;
+            margin-right: 8px;
+        }
+        .green { background-color: #28a745; }
+        .yellow { background-color: #ffc107; }
+        .red { background-color: #dc3545; }
+        .grey { background-color: #6c757d; }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        th {
+            width: 220px;
+            text-align: left;
+            vertical-align: top;
+            padding: 10px;
+            background: var(--header-bg);
+            border-bottom: 1px solid var(--border-color);
+        }
+        td {
+            padding: 10px;
+            border-bottom: 1px solid var(--border-color);
+            word-break: break-all;
+        }
+        .mono {
+            font-family: monospace;
+            font-size: 13px;
+        }
+        .chain th {
+            width: auto;
+        }
+        .chain tr.expiring td {
+            background: var(--warning-bg);
+        }
+        .change-badge {
+            display: inline-block;
+            padding: 2px 6px;
+            border-radius: 3px;
+            font-size: 12px;
+            background: #28a745;
+            color: white;
+        }
+        .change-badge.unexpected {
+            background: #dc3545;
+        }
+        .error-message {
+            color: #dc3545;
+            font-style: italic;
+        }
+        .verify-message {
+            background: var(--warning-bg);
+            border: 1px solid var(--warning-border);
+            color: var(--warning-text);
+            padding: 10px;
+            border-radius: 4px;
+            margin-top: 10px;
+        }
+    </style>
+</head>
+<body>
+    <div class="nav">
+        <a href="/results">Results</a>
+        <a href="/sites">Sites</a>
+        <a href="/notifications">Notifications</a>
+        <a href="/settings">Settings</a>
+    </div>
+
+    <div class="header">
+        <h1>{{.Result.Name}}</h1>
+        <div class="subtitle">{{.Result.URL}} &middot; checked {{.Result.LastCheck.Format "2006-01-02 15:04:05"}}{{if .Result.HandshakeMs}} &middot; handshake {{.Result.HandshakeMs}} ms{{end}} &middot; <a href="/results/history?url={{.Result.URL}}">scan history</a></div>
+        <p><span class="status-indicator {{.ColorClass}}"></span>{{.StatusLabel}}{{if not .Result.Error}} &middot; {{.Result.DaysLeft}} days left{{end}}</p>
+        {{if .ExpiringElement}}
+            <div class="subtitle">Expiry is set by the {{.ExpiringElement}}</div>
+        {{end}}
+        {{if .Result.Error}}
+            <div class="error-message">Error: {{.Result.Error}}</div>
+        {{end}}
+        {{if .VerifyLabel}}
+            <div class="verify-message"><strong>⚠️ {{.VerifyLabel}}:</strong> {{.Result.VerifyError}}</div>
+        {{end}}
+    </div>
+
+    <div class="card">
+        <h2>Certificate</h2>
+        {{with .Result.Certificate}}
+        <table>
+            <tr><th>Subject</th><td>{{.Subject}}</td></tr>
+            <tr><th>Issuer</th><td>{{.Issuer}}</td></tr>
+            <tr><th>Subject Alternative Names</th><td>{{range $i, $san := .SANs}}{{if $i}}, {{end}}{{$san}}{{else}}None{{end}}</td></tr>
+            <tr><th>Valid From</th><td>{{.NotBefore.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+            <tr><th>Valid Until</th><td>{{.NotAfter.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+            <tr><th>Serial Number</th><td class="mono">{{.SerialNumber}}</td></tr>
+            <tr><th>Public Key</th><td>{{.KeyAlgorithm}}{{if .KeySize}} {{.KeySize}} bits{{end}}</td></tr>
+            <tr><th>Signature Algorithm</th><td>{{.SignatureAlgorithm}}</td></tr>
+            <tr><th>SHA-256 Fingerprint</th><td class="mono">{{.FingerprintSHA256}}</td></tr>
+        </table>
+        {{else}}
+        <p class="subtitle">No certificate details were recorded for this site. Run a new scan to collect them.</p>
+        {{end}}
+    </div>
+
+    {{if .Result.Addresses}}
+    <div class="card">
+        <h2>Addresses</h2>
+        {{if .Result.Inconsistent}}
+            <div class="verify-message">⚠️ The nodes behind this site are serving different certificates.</div>
+        {{end}}
+        {{if .Result.FailedAddresses}}
+            <div class="verify-message">⚠️ {{.Result.FailedAddresses}} of the nodes behind this site could not be checked.</div>
+        {{end}}
+        <table class="chain">
+            <thead>
+                <tr>
+                    <th>Address</th>
+                    <th>Days Left</th>
+                    <th>Expires</th>
+                    <th>SHA-256 Fingerprint</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Result.Addresses}}
+                <tr>
+                    <td>{{.Address}}</td>
+                    {{if .Error}}
+                    <td colspan="3" class="error-message">Error: {{.Error}}</td>
+                    {{else}}
+                    <td>{{.DaysLeft}}</td>
+                    <td>{{.ExpiryDate.Format "2006-01-02"}}</td>
+                    <td class="mono">{{.FingerprintSHA256}}</td>
+                    {{end}}
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+    </div>
+    {{end}}
+
+    {{if .Chain}}
+    <div class="card">
+        <h2>Certificate Chain</h2>
+        <table class="chain">
+            <thead>
+                <tr>
+                    <th>#</th>
+                    <th>Certificate</th>
+                    <th>Expires</th>
+                    <th>SHA-256 Fingerprint</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Chain}}
+                <tr{{if .IsExpiring}} class="expiring"{{end}}>
+                    <td>{{.Position}}</td>
+                    <td>
+                        <div>{{.Subject}}</div>
+                        <div class="subtitle">{{.Description}} &middot; issued by {{.Issuer}}</div>
+                    </td>
+                    <td>{{.NotAfter.Format "2006-01-02"}}{{if .IsExpiring}} <strong>(earliest)</strong>{{end}}</td>
+                    <td class="mono">{{.FingerprintSHA256}}</td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+    </div>
+    {{end}}
+
+    {{if .Changes}}
+    <div class="card">
+        <h2>Certificate Changes</h2>
+        <table class="chain">
+            <thead>
+                <tr>
+                    <th>Detected</th>
+                    <th>Change</th>
+                    <th>Issuer</th>
+                    <th>Expiry</th>
+                    <th>New Fingerprint</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Changes}}
+                <tr>
+                    <td>{{.DetectedAt.Format "2006-01-02 15:04"}}</td>
+                    <td><span class="change-badge{{if .Unexpected}} unexpected{{end}}">{{.KindLabel}}</span></td>
+                    <td>{{if eq .OldIssuer .NewIssuer}}{{.NewIssuer}}{{else}}{{.OldIssuer}} &rarr; {{.NewIssuer}}{{end}}</td>
+                    <td>{{.OldExpiry.Format "2006-01-02"}} &rarr; {{.NewExpiry.Format "2006-01-02"}}</td>
+                    <td class="mono">{{.NewFingerprint}}</td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+    </div>
+    {{end}}
+</body>
+</html>`
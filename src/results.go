@@ -39,6 +39,19 @@ type ResultDisplay struct {
 	Error      string
 	ColorClass string
 	HasError   bool
+
+	VerifyError    string
+	VerifyLabel    string
+	HasVerifyError bool
+
+	ExpiringElement string // set when a chain certificate expires before the leaf
+	Inconsistent    bool
+	FailedAddresses int // nodes that failed while others answered
+
+	SnoozedUntil     time.Time // zero unless notifications are snoozed
+	MaintenanceUntil time.Time // zero unless the site is in a maintenance window
+	Alerting         bool      // the last notification was an alert, so it can be acknowledged
+	Acknowledgement  *Acknowledgement
 }
 
 type ResultsPageData struct {
@@ -48,6 +61,48 @@ type ResultsPageData struct {
 	LastModified time.Time
 	Settings     Settings
 	IsScanning   bool // Add scanning state to page data
+	QuietHours   bool // notifications are being held for quiet hours
+	HeldCount    int  // notifications waiting to be sent
+	RetryCount   int  // failed deliveries waiting to be retried
+	DeadCount    int  // failed deliveries that ran out of retries
+}
+
+type ChainElementDisplay struct {
+	CertDetails
+	Position    int
+	Description string
+	IsExpiring  bool
+}
+
+type ResultDetailPageData struct {
+	Result          CertResult
+	StatusLabel     string
+	ColorClass      string
+	VerifyLabel     string
+	LastScan        time.Time
+	Chain           []ChainElementDisplay
+	ExpiringElement string
+	Changes         []ChangeDisplay
+}
+
+type ChangeDisplay struct {
+	CertChange
+	KindLabel string
+}
+
+func changeKindLabel(kind string) string {
+	switch kind {
+	case changeRenewed:
+		return "Renewed"
+	case changeRekeyed:
+		return "Re-keyed"
+	case changeIssuerChanged:
+		return "Issuer changed"
+	case changeReplaced:
+		return "Replaced"
+	default:
+		return kind
+	}
 }
 
 func loadSitesList() (SitesList, error) {
@@ -76,6 +131,26 @@ func loadResults() (ScanResults, error) {
 	return results, err
 }
 
+// verifyErrorLabel turns a CertResult.VerifyErrorType into a short label for the dashboard
+func verifyErrorLabel(errorType string) string {
+	switch errorType {
+	case verifyErrorExpired:
+		return "Expired"
+	case verifyErrorNotYetValid:
+		return "Not yet valid"
+	case verifyErrorHostnameMismatch:
+		return "Hostname mismatch"
+	case verifyErrorSelfSigned:
+		return "Self-signed"
+	case verifyErrorUnknownAuthority:
+		return "Untrusted issuer"
+	case "":
+		return ""
+	default:
+		return "Invalid certificate"
+	}
+}
+
 func getColorClass(daysLeft int, settings Settings) string {
 	if daysLeft < settings.Dashboard.ColorThresholds.Critical {
 		return "red"
@@ -86,6 +161,30 @@ func getColorClass(daysLeft int, settings Settings) string {
 	}
 }
 
+// statusLabel is the dashboard wording for a result's status
+func statusLabel(result CertResult, settings Settings) string {
+	if result.Error != "" {
+		return "Error"
+	}
+	switch getColorClass(result.DaysLeft, settings) {
+	case "red":
+		return "Critical"
+	case "yellow":
+		return "Warning"
+	default:
+		return "Good"
+	}
+}
+
+func findResult(results ScanResults, url string) (CertResult, bool) {
+	for _, result := range results.Results {
+		if result.URL == url {
+			return result, true
+		}
+	}
+	return CertResult{}, false
+}
+
 // Scan function that manages state
 func runScanWithState(sites []Site) {
 	setScanningState(true)
@@ -138,6 +237,17 @@ func resultsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Snoozes and held notifications, which only add to the page
+	state, err := loadNotificationState()
+	if err != nil {
+		LogWarning("Error loading notification state for results: %v", err)
+	}
+	maintenance := make(map[string][]MaintenanceWindow)
+	for _, site := range sitesList.Sites {
+		maintenance[site.URL] = site.Maintenance
+	}
+	now := time.Now()
+
 	// Convert to display format with color classes and status text
 	displayResults := make([]ResultDisplay, len(scanResults.Results))
 	for i, result := range scanResults.Results {
@@ -149,6 +259,26 @@ func resultsHandler(w http.ResponseWriter, r *http.Request) {
 			LastCheck:  result.LastCheck,
 			Error:      result.Error,
 			HasError:   result.Error != "",
+
+			VerifyError:    result.VerifyError,
+			VerifyLabel:    verifyErrorLabel(result.VerifyErrorType),
+			HasVerifyError: result.VerifyErrorType != "",
+		}
+
+		if result.ExpiringChainIndex > 0 {
+			display.ExpiringElement = expiringElementDescription(result)
+		}
+		display.Inconsistent = result.Inconsistent
+		display.FailedAddresses = result.FailedAddresses
+
+		history := state.NotificationHistory[result.URL]
+		if history.SnoozedUntil.After(now) {
+			display.SnoozedUntil = history.SnoozedUntil
+		}
+		display.Alerting = isAlertStatus(history.LastStatus)
+		display.Acknowledgement = history.Acknowledgement
+		if window, found := activeMaintenance(maintenance[result.URL], now); found {
+			display.MaintenanceUntil = window.End
 		}
 
 		if display.HasError {
@@ -188,8 +318,83 @@ func resultsHandler(w http.ResponseWriter, r *http.Request) {
 		LastModified: sitesList.LastModified,
 		Settings:     settings,
 		IsScanning:   getScanningState(),
+		QuietHours:   inQuietHours(settings.QuietHours, now),
+		HeldCount:    len(state.Held),
+	}
+
+	outboxMutex.Lock()
+	outbox, err := loadOutbox()
+	outboxMutex.Unlock()
+	if err != nil {
+		LogWarning("Error loading outbox for results page: %v", err)
+	}
+	for _, entry := range outbox {
+		if entry.Status == outboxDead {
+			pageData.DeadCount++
+		} else {
+			pageData.RetryCount++
+		}
 	}
 
 	parsedTemplate := template.Must(template.New("results").Parse(resultsTemplate))
 	parsedTemplate.Execute(w, pageData)
-}
\ No newline at end of file
+}
+
+func resultDetailHandler(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+
+	scanResults, err := loadResults()
+	if err != nil {
+		http.Error(w, "Error loading results", http.StatusInternalServerError)
+		return
+	}
+
+	result, found := findResult(scanResults, url)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		http.Error(w, "Error loading settings", http.StatusInternalServerError)
+		return
+	}
+
+	pageData := ResultDetailPageData{
+		Result:      result,
+		StatusLabel: statusLabel(result, settings),
+		VerifyLabel: verifyErrorLabel(result.VerifyErrorType),
+		LastScan:    scanResults.LastScan,
+	}
+	for i, element := range result.Chain {
+		pageData.Chain = append(pageData.Chain, ChainElementDisplay{
+			CertDetails: element,
+			Position:    i + 1,
+			Description: chainElementDescription(result.Chain, i),
+			IsExpiring:  i == result.ExpiringChainIndex,
+		})
+	}
+	if len(result.Chain) > 0 {
+		pageData.ExpiringElement = expiringElementDescription(result)
+	}
+
+	certificateState, err := loadCertificateState()
+	if err != nil {
+		LogWarning("Error loading certificate history for %s: %v", url, err)
+	}
+	for _, change := range changesForSite(certificateState, url) {
+		pageData.Changes = append(pageData.Changes, ChangeDisplay{
+			CertChange: change,
+			KindLabel:  changeKindLabel(change.Kind),
+		})
+	}
+	if result.Error != "" {
+		pageData.ColorClass = "grey"
+	} else {
+		pageData.ColorClass = getColorClass(result.DaysLeft, settings)
+	}
+
+	parsedTemplate := template.Must(template.New("result-detail").Parse(resultDetailTemplate))
+	parsedTemplate.Execute(w, pageData)
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetColorClass(t *testing.T) {
@@ -118,4 +126,119 @@ func TestGetColorClassDifferentThresholds(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestVerifyErrorLabel(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		verifyErrorExpired:          "Expired",
+		verifyErrorNotYetValid:      "Not yet valid",
+		verifyErrorHostnameMismatch: "Hostname mismatch",
+		verifyErrorSelfSigned:       "Self-signed",
+		verifyErrorUnknownAuthority: "Untrusted issuer",
+		verifyErrorInvalid:          "Invalid certificate",
+	}
+
+	for errorType, expected := range tests {
+		if label := verifyErrorLabel(errorType); label != expected {
+			t.Errorf("verifyErrorLabel(%q) = %q, want %q", errorType, label, expected)
+		}
+	}
+}
+
+func writeTestResults(t *testing.T, results ScanResults) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal results: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dataDirPath, "results.json"), data, 0644)
+	if err != nil {
+		t.Fatalf("Failed to write results: %v", err)
+	}
+}
+
+func TestResultDetailHandler(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	setupMinimalSettingsFile(t)
+	writeTestResults(t, ScanResults{
+		LastScan: time.Now(),
+		Results: []CertResult{
+			{
+				URL:        "example.com:8443",
+				Name:       "Example",
+				ExpiryDate: time.Now().Add(40 * 24 * time.Hour),
+				DaysLeft:   40,
+				LastCheck:  time.Now(),
+				Certificate: &CertDetails{
+					Subject:            "CN=example.com",
+					Issuer:             "CN=Example CA",
+					SANs:               []string{"example.com", "www.example.com"},
+					SerialNumber:       "01:02:03",
+					KeyAlgorithm:       "RSA",
+					KeySize:            2048,
+					SignatureAlgorithm: "SHA256-RSA",
+					FingerprintSHA256:  "AA:BB:CC",
+				},
+			},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/results/site?url="+url.QueryEscape("example.com:8443"), nil)
+	w := httptest.NewRecorder()
+	resultDetailHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{"CN=Example CA", "www.example.com", "RSA 2048 bits", "SHA256-RSA", "AA:BB:CC", "01:02:03"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected detail page to contain %q", expected)
+		}
+	}
+}
+
+func TestResultDetailHandler_NotFound(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	setupMinimalSettingsFile(t)
+	writeTestResults(t, ScanResults{LastScan: time.Now(), Results: []CertResult{}})
+
+	req := httptest.NewRequest("GET", "/results/site?url=missing.example.com", nil)
+	w := httptest.NewRecorder()
+	resultDetailHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown site, got %d", w.Code)
+	}
+}
+
+func TestResultsHandlerLinksToDetail(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+
+	setupMinimalSettingsFile(t)
+	if err := saveSites([]Site{}); err != nil {
+		t.Fatalf("Failed to save sites: %v", err)
+	}
+	writeTestResults(t, ScanResults{
+		LastScan: time.Now(),
+		Results:  []CertResult{{URL: "example.com", Name: "Example", DaysLeft: 60, LastCheck: time.Now()}},
+	})
+
+	req := httptest.NewRequest("GET", "/results", nil)
+	w := httptest.NewRecorder()
+	resultsHandler(w, req)
+
+	if !strings.Contains(w.Body.String(), `href="/results/site?url=example.com"`) {
+		t.Error("Expected results page to link to the site detail page")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NotificationRoute sends the alerts for sites with its routing key to its own
+// list of channels instead of the global ones
+type NotificationRoute struct {
+	Name       string   `json:"name"`                 // routing key set on sites
+	Channels   []string `json:"channels"`             // channels that get the route's alerts
+	Severities []string `json:"severities,omitempty"` // severities to send, empty uses each channel's toggles
+	// Recipients override where a channel sends, by channel name: addresses
+	// for email, the topic URL for ntfy and the webhook URL for the others
+	Recipients map[string]string `json:"recipients,omitempty"`
+}
+
+// recipientNotifier is implemented by channels whose destination a route can
+// override
+type recipientNotifier interface {
+	// SetRecipient points the channel's settings at another destination
+	SetRecipient(settings *Settings, recipient string)
+	// Recipient says where the channel sends, for the delivery log. Secret
+	// webhook URLs are reduced to their host.
+	Recipient(settings Settings) string
+}
+
+// findRoute looks up a site's route. Sites without a routing key, or with one
+// that doesn't match a route, use the global channels.
+func findRoute(settings Settings, key string) (NotificationRoute, bool) {
+	if key == "" {
+		return NotificationRoute{}, false
+	}
+	for _, route := range settings.Routes {
+		if strings.EqualFold(route.Name, key) {
+			return route, true
+		}
+	}
+	LogWarning("No notification route named %q, using the global channels", key)
+	return NotificationRoute{}, false
+}
+
+// routeWants reports whether a route sends a severity through a channel
+func routeWants(route NotificationRoute, notifier Notifier, settings Settings, severity string) bool {
+	if !containsString(route.Channels, notifier.Name()) {
+		return false
+	}
+	if len(route.Severities) == 0 {
+		return notifier.Enabled(settings, severity)
+	}
+	return containsString(route.Severities, severity)
+}
+
+// routedSettings applies a route's recipient override for one channel
+func routedSettings(route NotificationRoute, notifier Notifier, settings Settings) Settings {
+	recipient := route.Recipients[notifier.Name()]
+	if recipient == "" {
+		return settings
+	}
+	overrider, ok := notifier.(recipientNotifier)
+	if !ok {
+		LogWarning("%s recipients can't be overridden, ignoring route %s", notifier.Label(), route.Name)
+		return settings
+	}
+	overrider.SetRecipient(&settings, recipient)
+	return settings
+}
+
+// siteRoutes maps each site URL to its routing key
+func siteRoutes(sites []Site) map[string]string {
+	routes := make(map[string]string, len(sites))
+	for _, site := range sites {
+		routes[site.URL] = site.Route
+	}
+	return routes
+}
+
+// applySiteRoutes copies each site's current routing key onto its result, so
+// route changes apply without rescanning
+func applySiteRoutes(results *ScanResults, sites []Site) {
+	routes := siteRoutes(sites)
+	for i := range results.Results {
+		results.Results[i].Route = routes[results.Results[i].URL]
+	}
+}
+
+// RouteNames lists the configured routes, for the sites page
+func (s Settings) RouteNames() []string {
+	var names []string
+	for _, route := range s.Routes {
+		names = append(names, route.Name)
+	}
+	return names
+}
+
+// RouteRows is the configured routes plus an empty one for adding a route on
+// the settings page
+func (s Settings) RouteRows() []NotificationRoute {
+	return append(append([]NotificationRoute{}, s.Routes...), NotificationRoute{})
+}
+
+// parseRouteKey normalises a routing key entered on the sites page
+func parseRouteKey(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// parseRouteForm reads the route rows from the settings form. Rows are
+// numbered from 0, and a row with no name removes the route.
+func parseRouteForm(r *http.Request, settings *Settings) {
+	if _, ok := r.Form["route_0_name"]; !ok {
+		return
+	}
+
+	var routes []NotificationRoute
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("route_%d_", i)
+		if _, ok := r.Form[prefix+"name"]; !ok {
+			break
+		}
+		name := parseRouteKey(r.FormValue(prefix + "name"))
+		if name == "" {
+			continue
+		}
+
+		route := NotificationRoute{
+			Name:     name,
+			Channels: parseChannelList(r.FormValue(prefix + "channels")),
+		}
+		for _, severity := range strings.Split(r.FormValue(prefix+"severities"), ",") {
+			severity = strings.ToLower(strings.TrimSpace(severity))
+			if containsString(notificationSeverities, severity) {
+				route.Severities = append(route.Severities, severity)
+			} else if severity != "" {
+				LogWarning("Ignoring unknown severity %q in route %s", severity, name)
+			}
+		}
+		for _, notifier := range notifiers {
+			if recipient := strings.TrimSpace(r.FormValue(prefix + "recipient_" + notifier.Name())); recipient != "" {
+				if route.Recipients == nil {
+					route.Recipients = make(map[string]string)
+				}
+				route.Recipients[notifier.Name()] = recipient
+			}
+		}
+		routes = append(routes, route)
+	}
+	settings.Routes = routes
+
+	LogDebug("Updated notification routes: %d routes", len(routes))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNotifyAllRoutes(t *testing.T) {
+	global := &fakeNotifier{name: "global", enabled: map[string]bool{"warning": true, "critical": true}}
+	team := &fakeNotifier{name: "team", enabled: map[string]bool{"critical": true}}
+	useFakeNotifiers(t, global, team)
+
+	var settings Settings
+	settings.Routes = []NotificationRoute{
+		{Name: "marketing", Channels: []string{"team"}},
+		{Name: "platform", Channels: []string{"global", "team"}, Severities: []string{"warning"}},
+	}
+
+	notify := func(route string, severity string) {
+		t.Helper()
+		notifyAll(Notification{Severity: severity, Result: CertResult{URL: "example.com", Route: route}}, settings)
+	}
+
+	// The route's channels use their own toggles when it lists no severities
+	notify("marketing", "warning")
+	notify("marketing", "critical")
+	if len(global.received) != 0 || len(team.received) != 1 {
+		t.Errorf("Expected only the critical alert on the team channel, got %d global and %d team", len(global.received), len(team.received))
+	}
+
+	// Listed severities replace the channel toggles
+	notify("platform", "warning")
+	notify("platform", "critical")
+	if len(global.received) != 1 || len(team.received) != 2 {
+		t.Errorf("Expected the warning on both channels, got %d global and %d team", len(global.received), len(team.received))
+	}
+
+	// Unknown and empty routes fall back to the global channels
+	notify("nobody", "warning")
+	notify("", "critical")
+	if len(global.received) != 3 || len(team.received) != 3 {
+		t.Errorf("Expected global delivery without a route, got %d global and %d team", len(global.received), len(team.received))
+	}
+}
+
+func TestNotifyAllRouteRecipients(t *testing.T) {
+	var recipients []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var email map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&email)
+		recipients = append(recipients, email["To"].(string))
+	}))
+	defer server.Close()
+
+	originalURL := postmarkAPIURL
+	postmarkAPIURL = server.URL
+	defer func() { postmarkAPIURL = originalURL }()
+
+	var settings Settings
+	settings.Notifications.Email = EmailSettings{ServerToken: "token", From: "monitor@example.com", To: "platform@example.com", EnabledWarning: true}
+	settings.Routes = []NotificationRoute{
+		{Name: "marketing", Channels: []string{"email"}, Recipients: map[string]string{"email": "marketing@example.com"}},
+	}
+
+	notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "campaign.example.com", Route: "marketing"}}, settings)
+	notifyAll(Notification{Severity: "warning", Result: CertResult{URL: "api.example.com"}}, settings)
+
+	if strings.Join(recipients, " ") != "marketing@example.com platform@example.com" {
+		t.Errorf("Expected the route recipients to replace the global ones, got %v", recipients)
+	}
+}
+
+func TestApplySiteRoutes(t *testing.T) {
+	results := ScanResults{Results: []CertResult{
+		{URL: "campaign.example.com", Route: "platform"},
+		{URL: "api.example.com", Route: "platform"},
+	}}
+	applySiteRoutes(&results, []Site{
+		{URL: "campaign.example.com", Route: "marketing"},
+		{URL: "api.example.com"},
+	})
+
+	if results.Results[0].Route != "marketing" || results.Results[1].Route != "" {
+		t.Errorf("Expected routes from the sites, got %+v", results.Results)
+	}
+}
+
+func TestParseRouteForm(t *testing.T) {
+	form := url.Values{
+		"route_0_name":              {"Marketing"},
+		"route_0_channels":          {"email, slack, pager"},
+		"route_0_severities":        {"warning, critical, bogus"},
+		"route_0_recipient_email":   {" marketing@example.com "},
+		"route_0_recipient_slack":   {""},
+		"route_1_name":              {""},
+		"route_1_channels":          {"ntfy"},
+		"route_2_name":              {"platform"},
+		"route_2_channels":          {"ntfy"},
+		"route_2_recipient_ntfy":    {"https://ntfy.sh/platform"},
+		"route_2_recipient_unknown": {"ignored"},
+	}
+	r := httptest.NewRequest("POST", "/settings", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ParseForm()
+
+	var settings Settings
+	parseRouteForm(r, &settings)
+
+	got, _ := json.Marshal(settings.Routes)
+	want, _ := json.Marshal([]NotificationRoute{
+		{Name: "marketing", Channels: []string{"email", "slack"}, Severities: []string{"warning", "critical"}, Recipients: map[string]string{"email": "marketing@example.com"}},
+		{Name: "platform", Channels: []string{"ntfy"}, Recipients: map[string]string{"ntfy": "https://ntfy.sh/platform"}},
+	})
+	if string(got) != string(want) {
+		t.Errorf("Expected routes %s, got %s", want, got)
+	}
+}
@@ -1,19 +1,47 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	defaultScanConcurrency = 10
+	defaultTLSPort         = 443
+	scanTimeout            = 10 * time.Second
+)
+
+// scanRootCAs replaces the system roots when verifying certificates, nil uses the system pool
+var scanRootCAs *x509.CertPool
+
 type Site struct {
-	Name    string    `json:"name"`
-	URL     string    `json:"url"`
-	Enabled bool      `json:"enabled"`
-	Added   time.Time `json:"added"`
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	Host     string    `json:"host,omitempty"` // parsed from URL, filled in for older sites.json files on load
+	Port     int       `json:"port,omitempty"`
+	SNI      string    `json:"sni,omitempty"`      // optional server name to send instead of Host
+	Protocol string    `json:"protocol,omitempty"` // STARTTLS protocol, empty for direct TLS
+	Route    string    `json:"route,omitempty"`    // notification route, empty uses the global channels
+	Enabled  bool      `json:"enabled"`
+	Added    time.Time `json:"added"`
+	// Maintenance windows hold back the site's notifications during planned work
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
 }
 
 type SitesList struct {
@@ -28,11 +56,265 @@ type CertResult struct {
 	DaysLeft   int       `json:"days_left"`
 	LastCheck  time.Time `json:"last_check"`
 	Error      string    `json:"error,omitempty"`
+	Route      string    `json:"route,omitempty"` // the site's notification route
+	// Time taken to connect, negotiate STARTTLS and complete the TLS handshake
+	HandshakeMs int64 `json:"handshake_ms,omitempty"`
+	// Verification problems don't stop the certificate being read, so they're kept apart from Error
+	VerifyError     string `json:"verify_error,omitempty"`
+	VerifyErrorType string `json:"verify_error_type,omitempty"`
+
+	Certificate *CertDetails `json:"certificate,omitempty"` // leaf certificate, nil when the handshake failed
+
+	// Every certificate the server sent, leaf first. ExpiryDate is the earliest
+	// NotAfter in the chain and ExpiringChainIndex says which element that is.
+	Chain              []CertDetails `json:"chain,omitempty"`
+	ExpiringChainIndex int           `json:"expiring_chain_index"`
+
+	// Per-node results when the host resolves to more than one address. The
+	// fields above describe the node whose certificate expires first.
+	Addresses    []AddressResult `json:"addresses,omitempty"`
+	Inconsistent bool            `json:"inconsistent,omitempty"` // nodes served different leaf certificates
+	// FailedAddresses counts the nodes that couldn't be checked when others
+	// could. Error is only set when every node fails.
+	FailedAddresses int `json:"failed_addresses,omitempty"`
+}
+
+// AddressResult is the outcome of checking one IP address behind a site
+type AddressResult struct {
+	Address           string    `json:"address"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	DaysLeft          int       `json:"days_left"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	VerifyErrorType   string    `json:"verify_error_type,omitempty"`
+
+	Certificate *CertDetails `json:"certificate,omitempty"` // the node's leaf certificate
+}
+
+// CertDetails describes a single certificate as presented by the server
+type CertDetails struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SANs               []string  `json:"sans,omitempty"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyAlgorithm       string    `json:"key_algorithm"`
+	KeySize            int       `json:"key_size,omitempty"`
+	PublicKeySHA256    string    `json:"public_key_sha256,omitempty"` // changes when the certificate is re-keyed
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	FingerprintSHA256  string    `json:"fingerprint_sha256"`
 }
 
+// Categories for CertResult.VerifyErrorType
+const (
+	verifyErrorExpired          = "expired"
+	verifyErrorNotYetValid      = "not_yet_valid"
+	verifyErrorHostnameMismatch = "hostname_mismatch"
+	verifyErrorSelfSigned       = "self_signed"
+	verifyErrorUnknownAuthority = "unknown_authority"
+	verifyErrorInvalid          = "invalid"
+)
+
 type ScanResults struct {
-	LastScan time.Time    `json:"last_scan"`
-	Results  []CertResult `json:"results"`
+	LastScan   time.Time    `json:"last_scan"`
+	DurationMs int64        `json:"duration_ms,omitempty"` // wall time of the whole scan
+	Results    []CertResult `json:"results"`
+}
+
+// siteTarget returns the host and port to dial, parsing the URL for sites that predate the Host field
+func siteTarget(site Site) (string, int, error) {
+	if site.Host != "" {
+		port := site.Port
+		if port == 0 {
+			port = defaultPortForProtocol(site.Protocol)
+		}
+		return site.Host, port, nil
+	}
+
+	_, host, port, err := parseSiteURL(site.URL, defaultPortForProtocol(site.Protocol))
+	return host, port, err
+}
+
+func siteServerName(site Site, host string) string {
+	if site.SNI != "" {
+		return site.SNI
+	}
+	return host
+}
+
+// dialTLS connects to address, performs the STARTTLS upgrade for protocol if
+// one is set, and completes the TLS handshake. The chain is not verified here
+// so that expired or untrusted certificates can still be inspected, callers
+// check it with verifyCertificateChain.
+func dialTLS(address string, protocol string, serverName string) (*tls.Conn, error) {
+	dialer := &net.Dialer{Timeout: scanTimeout}
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(scanTimeout))
+
+	if protocol != "" {
+		LogDebug("Negotiating %s STARTTLS with %s", protocol, address)
+		err = startTLS(conn, protocol, serverName)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s STARTTLS failed: %w", protocol, err)
+		}
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// colonHex formats bytes as upper case hex pairs separated by colons, as browsers show serials and fingerprints
+func colonHex(data []byte) string {
+	pairs := make([]string, len(data))
+	for i, b := range data {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":")
+}
+
+func publicKeySize(cert *x509.Certificate) int {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+func certificateDetails(cert *x509.Certificate) CertDetails {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	fingerprint := sha256.Sum256(cert.Raw)
+	publicKeyFingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return CertDetails{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SANs:               sans,
+		SerialNumber:       colonHex(cert.SerialNumber.Bytes()),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		KeySize:            publicKeySize(cert),
+		PublicKeySHA256:    colonHex(publicKeyFingerprint[:]),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		FingerprintSHA256:  colonHex(fingerprint[:]),
+	}
+}
+
+// chainElementDescription names a chain element for people, e.g. "intermediate certificate CN=R3,O=Let's Encrypt"
+func chainElementDescription(chain []CertDetails, index int) string {
+	if index < 0 || index >= len(chain) {
+		return "certificate"
+	}
+	if index == 0 {
+		return "leaf certificate"
+	}
+
+	element := chain[index]
+	if index == len(chain)-1 && element.Subject == element.Issuer {
+		return "root certificate " + element.Subject
+	}
+	return "intermediate certificate " + element.Subject
+}
+
+// expiringElementDescription describes the chain element that sets the result's expiry date
+func expiringElementDescription(result CertResult) string {
+	return chainElementDescription(result.Chain, result.ExpiringChainIndex)
+}
+
+// verifyCertificateChain checks the presented chain the same way a client would
+func verifyCertificateChain(certs []*x509.Certificate, serverName string, now time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         scanRootCAs,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	return err
+}
+
+func classifyVerifyError(err error, leaf *x509.Certificate, now time.Time) string {
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+
+	switch {
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		// The Expired reason covers both ends of the validity period
+		if now.Before(invalidErr.Cert.NotBefore) {
+			return verifyErrorNotYetValid
+		}
+		return verifyErrorExpired
+	case errors.As(err, &hostnameErr):
+		return verifyErrorHostnameMismatch
+	case errors.As(err, &authorityErr):
+		// CheckSignatureFrom would insist the leaf is a CA, so check the signature directly
+		selfSigned := leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil
+		if bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && selfSigned {
+			return verifyErrorSelfSigned
+		}
+		return verifyErrorUnknownAuthority
+	default:
+		return verifyErrorInvalid
+	}
+}
+
+// lookupAddresses resolves host to every IP address it has, tests swap it to avoid DNS
+var lookupAddresses = func(host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+	defer cancel()
+
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(ipAddrs))
+	seen := make(map[string]bool)
+	for _, ipAddr := range ipAddrs {
+		address := ipAddr.IP.String()
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
 }
 
 func checkCertificate(site Site) CertResult {
@@ -42,72 +324,290 @@ func checkCertificate(site Site) CertResult {
 		LastCheck: time.Now(),
 	}
 
-	LogDebug("Connecting to %s:443", site.URL)
-
-	// Set up connection with timeout
-	dialer := &net.Dialer{Timeout: 10 * time.Second}
-	conn, err := tls.DialWithDialer(dialer, "tcp", site.URL+":443", &tls.Config{
-		ServerName: site.URL,
-	})
+	host, port, err := siteTarget(site)
+	if err != nil {
+		result.Error = err.Error()
+		LogWarning("Invalid address for %s: %s", site.URL, err.Error())
+		return result
+	}
+	serverName := siteServerName(site, host)
 
+	addresses, err := lookupAddresses(host)
+	if err == nil && len(addresses) == 0 {
+		err = fmt.Errorf("no addresses found for %s", host)
+	}
 	if err != nil {
 		result.Error = err.Error()
 		LogWarning("Certificate check failed for %s: %s", site.URL, err.Error())
 		return result
 	}
+
+	// Check every node and report the one whose certificate expires first,
+	// so a single stale node can't hide behind healthy ones
+	var selected *CertResult
+	failed := 0
+	fingerprints := make(map[string]bool)
+	addressResults := make([]AddressResult, 0, len(addresses))
+
+	for _, ip := range addresses {
+		addressResult := checkAddress(site, net.JoinHostPort(ip, strconv.Itoa(port)), serverName)
+		addressResults = append(addressResults, AddressResult{
+			Address:           ip,
+			ExpiryDate:        addressResult.ExpiryDate,
+			DaysLeft:          addressResult.DaysLeft,
+			FingerprintSHA256: leafFingerprint(addressResult),
+			Certificate:       addressResult.Certificate,
+			Error:             addressResult.Error,
+			VerifyErrorType:   addressResult.VerifyErrorType,
+		})
+
+		if addressResult.Error != "" {
+			failed++
+			if selected == nil {
+				selected = &addressResult
+			}
+			continue
+		}
+
+		fingerprints[leafFingerprint(addressResult)] = true
+		if selected == nil || selected.Error != "" || addressResult.ExpiryDate.Before(selected.ExpiryDate) {
+			selected = &addressResult
+		}
+	}
+
+	result = *selected
+	result.LastCheck = time.Now()
+	if len(addresses) > 1 {
+		result.Addresses = addressResults
+	}
+	result.Inconsistent = len(fingerprints) > 1
+	if result.Inconsistent {
+		LogWarning("Nodes behind %s are serving %d different certificates", site.URL, len(fingerprints))
+	}
+	// Nodes that failed while others answered don't fail the site, whose
+	// expiry is still known, but are flagged so they can alert
+	if result.Error == "" && failed > 0 {
+		result.FailedAddresses = failed
+		LogWarning("%s", nodeFailureSummary(result))
+	}
+
+	return result
+}
+
+// nodeFailureSummary describes a site's failing nodes, e.g. "1 of 2 addresses
+// behind example.com failed, 2001:db8::1: connection refused"
+func nodeFailureSummary(result CertResult) string {
+	summary := fmt.Sprintf("%d of %d addresses behind %s failed", result.FailedAddresses, len(result.Addresses), result.URL)
+	for _, address := range result.Addresses {
+		if address.Error != "" {
+			return fmt.Sprintf("%s, %s: %s", summary, address.Address, address.Error)
+		}
+	}
+	return summary
+}
+
+func leafFingerprint(result CertResult) string {
+	if result.Certificate == nil {
+		return ""
+	}
+	return result.Certificate.FingerprintSHA256
+}
+
+// checkAddress handshakes with one node of a site and reads its certificate chain
+func checkAddress(site Site, address string, serverName string) CertResult {
+	result := CertResult{
+		URL:       site.URL,
+		Name:      site.Name,
+		LastCheck: time.Now(),
+	}
+
+	LogDebug("Connecting to %s", address)
+
+	start := time.Now()
+	conn, err := dialTLS(address, site.Protocol, serverName)
+	result.HandshakeMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		LogWarning("Certificate check failed for %s at %s: %s", site.URL, address, err.Error())
+		return result
+	}
 	defer conn.Close()
 
 	// Get the certificate
 	certs := conn.ConnectionState().PeerCertificates
 	if len(certs) == 0 {
 		result.Error = "No certificates found"
-		LogWarning("No certificates found for %s", site.URL)
+		LogWarning("No certificates found for %s at %s", site.URL, address)
 		return result
 	}
 
-	// Use the first certificate (leaf certificate)
+	// The site expires when the first certificate in the served chain does
 	cert := certs[0]
-	result.ExpiryDate = cert.NotAfter
-	result.DaysLeft = int(time.Until(cert.NotAfter).Hours() / 24)
+	result.Chain = make([]CertDetails, len(certs))
+	for i, chainCert := range certs {
+		result.Chain[i] = certificateDetails(chainCert)
+		if chainCert.NotAfter.Before(certs[result.ExpiringChainIndex].NotAfter) {
+			result.ExpiringChainIndex = i
+		}
+	}
+	leafDetails := result.Chain[0]
+	result.Certificate = &leafDetails
+
+	expiring := certs[result.ExpiringChainIndex]
+	result.ExpiryDate = expiring.NotAfter
+	result.DaysLeft = int(time.Until(expiring.NotAfter).Hours() / 24)
+	if result.ExpiringChainIndex > 0 {
+		LogInfo("Chain for %s expires before its leaf: %s", site.URL, expiringElementDescription(result))
+	}
 
-	LogDebug("Certificate for %s expires %s (%d days)", site.URL, result.ExpiryDate.Format("2006-01-02"), result.DaysLeft)
+	now := time.Now()
+	err = verifyCertificateChain(certs, serverName, now)
+	if err != nil {
+		result.VerifyError = err.Error()
+		result.VerifyErrorType = classifyVerifyError(err, cert, now)
+		LogWarning("Certificate verification failed for %s (%s): %s", site.URL, result.VerifyErrorType, result.VerifyError)
+	}
+
+	LogDebug("Certificate for %s at %s expires %s (%d days)", site.URL, address, result.ExpiryDate.Format("2006-01-02"), result.DaysLeft)
 
 	return result
 }
 
+// ScanOptions controls how certificate checks are spread across workers
+type ScanOptions struct {
+	Concurrency  int           // maximum number of sites checked at once
+	HostInterval time.Duration // minimum gap between connections to the same host, 0 disables
+}
+
+func defaultScanOptions() ScanOptions {
+	return ScanOptions{
+		Concurrency:  defaultScanConcurrency,
+		HostInterval: 0,
+	}
+}
+
+func scanOptionsFromSettings(settings Settings) ScanOptions {
+	options := defaultScanOptions()
+	if settings.ScanConcurrency > 0 {
+		options.Concurrency = settings.ScanConcurrency
+	}
+	if settings.ScanHostIntervalMs > 0 {
+		options.HostInterval = time.Duration(settings.ScanHostIntervalMs) * time.Millisecond
+	}
+	return options
+}
+
+// certificateChecker is the function used by the scan workers, tests swap it to avoid network calls
+var certificateChecker = checkCertificate
+
+// hostRateLimiter spaces out connections to the same host across all workers
+type hostRateLimiter struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	next     map[string]time.Time
+}
+
+func newHostRateLimiter(interval time.Duration) *hostRateLimiter {
+	return &hostRateLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// wait blocks until the host is allowed another connection and reserves the following slot
+func (l *hostRateLimiter) wait(host string) {
+	if l.interval <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mutex.Unlock()
+
+	if delay := time.Until(slot); delay > 0 {
+		LogDebug("Rate limiting %s for %v", host, delay)
+		time.Sleep(delay)
+	}
+}
+
+func siteRateLimitKey(site Site) string {
+	host, _, err := siteTarget(site)
+	if err != nil {
+		return strings.ToLower(site.URL)
+	}
+	return host
+}
+
 func scanAllSites(sites []Site) ScanResults {
+	return scanAllSitesWithOptions(sites, defaultScanOptions())
+}
+
+func scanAllSitesWithOptions(sites []Site, options ScanOptions) ScanResults {
 	results := ScanResults{
 		LastScan: time.Now(),
 		Results:  make([]CertResult, 0),
 	}
 
-	enabledCount := 0
-	for _, site := range sites {
-		if site.Enabled {
-			enabledCount++
-		}
-	}
-
-	LogInfo("Scanning %d enabled sites", enabledCount)
-
+	enabledSites := make([]Site, 0, len(sites))
 	for _, site := range sites {
 		if !site.Enabled {
 			LogDebug("Skipping disabled site %s", site.Name)
 			continue
 		}
+		enabledSites = append(enabledSites, site)
+	}
 
-		LogDebug("Checking %s (%s)", site.Name, site.URL)
-		result := checkCertificate(site)
-		results.Results = append(results.Results, result)
+	workers := options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(enabledSites) {
+		workers = len(enabledSites)
+	}
 
-		if result.Error != "" {
-			LogWarning("Error checking %s: %s", site.Name, result.Error)
-		} else {
-			LogInfo("Certificate for %s expires %s (%d days)", site.Name, result.ExpiryDate.Format("2006-01-02"), result.DaysLeft)
-		}
+	LogInfo("Scanning %d enabled sites with %d workers", len(enabledSites), workers)
+
+	// Each worker writes to its own slot so results keep the original site order
+	results.Results = make([]CertResult, len(enabledSites))
+	limiter := newHostRateLimiter(options.HostInterval)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				site := enabledSites[index]
+				limiter.wait(siteRateLimitKey(site))
+
+				LogDebug("Checking %s (%s)", site.Name, site.URL)
+				result := certificateChecker(site)
+				result.Route = site.Route
+				results.Results[index] = result
+
+				if result.Error != "" {
+					LogWarning("Error checking %s: %s", site.Name, result.Error)
+				} else {
+					LogInfo("Certificate for %s expires %s (%d days)", site.Name, result.ExpiryDate.Format("2006-01-02"), result.DaysLeft)
+				}
+			}
+		}()
+	}
+
+	for index := range enabledSites {
+		jobs <- index
 	}
+	close(jobs)
+	wg.Wait()
 
-	LogInfo("Scan completed for %d sites", len(results.Results))
+	results.DurationMs = time.Since(results.LastScan).Milliseconds()
+	LogInfo("Scan completed for %d sites in %d ms", len(results.Results), results.DurationMs)
 	return results
 }
 
@@ -1,19 +1,167 @@
 package main
 
 import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// testCert is a generated certificate and its key, used to build chains for fake TLS servers
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+type testCertOptions struct {
+	commonName string
+	notBefore  time.Time // zero means an hour ago
+	notAfter   time.Time
+	isCA       bool
+	parent     *testCert // signer, nil for self-signed
+}
+
+// newTestCert creates a certificate, leaf certificates are valid for 127.0.0.1 and localhost
+func newTestCert(t *testing.T, options testCertOptions) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	notBefore := options.notBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-time.Hour)
+	}
+	commonName := options.commonName
+	if commonName == "" {
+		commonName = "localhost"
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              options.notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  options.isCA,
+	}
+	if !options.isCA || options.parent == nil {
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+
+	parentCert, parentKey := template, key
+	if options.parent != nil {
+		parentCert, parentKey = options.parent.cert, options.parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+// tlsCertificate returns a server certificate presenting c followed by chain
+func (c *testCert) tlsCertificate(chain ...*testCert) tls.Certificate {
+	certificate := tls.Certificate{
+		Certificate: [][]byte{c.cert.Raw},
+		PrivateKey:  c.key,
+		Leaf:        c.cert,
+	}
+	for _, intermediate := range chain {
+		certificate.Certificate = append(certificate.Certificate, intermediate.cert.Raw)
+	}
+	return certificate
+}
+
+// newTestCertificate creates a self-signed certificate for 127.0.0.1 and localhost
+func newTestCertificate(t *testing.T, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	generated := newTestCert(t, testCertOptions{notAfter: notAfter, isCA: true})
+	return generated.tlsCertificate(), generated.cert
+}
+
+// trustTestCertificate makes checkCertificate accept cert for the rest of the test
+func trustTestCertificate(t *testing.T, cert *x509.Certificate) {
+	t.Helper()
+
+	originalRoots := scanRootCAs
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	scanRootCAs = pool
+	t.Cleanup(func() { scanRootCAs = originalRoots })
+}
+
+// startFakeServer accepts a single connection, runs the plaintext part of the
+// protocol and then completes a TLS handshake with certificate
+func startFakeServer(t *testing.T, certificate tls.Certificate, plaintext func(net.Conn, *bufio.Reader) bool) int {
+	t.Helper()
+	return startFakeServerOn(t, "127.0.0.1:0", certificate, plaintext)
+}
+
+// startFakeServerOn is startFakeServer listening on a specific address
+func startFakeServerOn(t *testing.T, address string, certificate tls.Certificate, plaintext func(net.Conn, *bufio.Reader) bool) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		if plaintext != nil && !plaintext(conn, bufio.NewReader(conn)) {
+			return
+		}
+
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}})
+		tlsConn.Handshake()
+		// Wait for the client to hang up
+		io.Copy(io.Discard, tlsConn)
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
 func TestScanAllSites_EmptyList(t *testing.T) {
 	sites := []Site{}
-	
+
 	results := scanAllSites(sites)
-	
+
 	if len(results.Results) != 0 {
 		t.Errorf("Expected 0 results for empty sites list, got %d", len(results.Results))
 	}
-	
+
 	if results.LastScan.IsZero() {
 		t.Error("Expected LastScan to be set")
 	}
@@ -28,15 +176,15 @@ func TestScanAllSites_DisabledSitesSkipped(t *testing.T) {
 			Added:   time.Now(),
 		},
 		{
-			Name:    "Another Disabled Site", 
+			Name:    "Another Disabled Site",
 			URL:     "disabled2.example.com",
 			Enabled: false,
 			Added:   time.Now(),
 		},
 	}
-	
+
 	results := scanAllSites(sites)
-	
+
 	if len(results.Results) != 0 {
 		t.Errorf("Expected 0 results when all sites disabled, got %d", len(results.Results))
 	}
@@ -46,7 +194,7 @@ func TestScanAllSites_MixedEnabledDisabled(t *testing.T) {
 	sites := []Site{
 		{
 			Name:    "Enabled Site",
-			URL:     "enabled.example.com", 
+			URL:     "enabled.example.com",
 			Enabled: true,
 			Added:   time.Now(),
 		},
@@ -63,20 +211,20 @@ func TestScanAllSites_MixedEnabledDisabled(t *testing.T) {
 			Added:   time.Now(),
 		},
 	}
-	
+
 	results := scanAllSites(sites)
-	
+
 	// Should have 2 results (only enabled sites)
 	if len(results.Results) != 2 {
 		t.Errorf("Expected 2 results for 2 enabled sites, got %d", len(results.Results))
 	}
-	
+
 	// Check that the right sites were processed
 	expectedURLs := map[string]bool{
 		"enabled.example.com":  false,
 		"enabled2.example.com": false,
 	}
-	
+
 	for _, result := range results.Results {
 		if _, exists := expectedURLs[result.URL]; !exists {
 			t.Errorf("Unexpected URL in results: %s", result.URL)
@@ -84,7 +232,7 @@ func TestScanAllSites_MixedEnabledDisabled(t *testing.T) {
 			expectedURLs[result.URL] = true
 		}
 	}
-	
+
 	// Verify all expected URLs were found
 	for url, found := range expectedURLs {
 		if !found {
@@ -102,33 +250,33 @@ func TestScanAllSites_ResultStructure(t *testing.T) {
 			Added:   time.Now(),
 		},
 	}
-	
+
 	results := scanAllSites(sites)
-	
+
 	// Basic structure checks
 	if results.LastScan.IsZero() {
 		t.Error("Expected LastScan to be set")
 	}
-	
+
 	if len(results.Results) != 1 {
 		t.Fatalf("Expected 1 result, got %d", len(results.Results))
 	}
-	
+
 	result := results.Results[0]
-	
+
 	// Check that basic fields are populated
 	if result.URL != "test.example.com" {
 		t.Errorf("Expected URL 'test.example.com', got '%s'", result.URL)
 	}
-	
+
 	if result.Name != "Test Site" {
 		t.Errorf("Expected Name 'Test Site', got '%s'", result.Name)
 	}
-	
+
 	if result.LastCheck.IsZero() {
 		t.Error("Expected LastCheck to be set")
 	}
-	
+
 	// Note: We can't test ExpiryDate and DaysLeft easily without actual network calls
 	// but we can verify the structure is there
 }
@@ -142,16 +290,16 @@ func TestScanAllSites_TimingConsistency(t *testing.T) {
 			Added:   time.Now(),
 		},
 	}
-	
+
 	beforeScan := time.Now()
 	results := scanAllSites(sites)
 	afterScan := time.Now()
-	
+
 	// LastScan should be within our time window
 	if results.LastScan.Before(beforeScan) || results.LastScan.After(afterScan) {
 		t.Errorf("LastScan time %v should be between %v and %v", results.LastScan, beforeScan, afterScan)
 	}
-	
+
 	// Each result's LastCheck should also be within our window
 	for i, result := range results.Results {
 		if result.LastCheck.Before(beforeScan) || result.LastCheck.After(afterScan) {
@@ -164,10 +312,10 @@ func TestScanAllSites_TimingConsistency(t *testing.T) {
 func TestDaysCalculationLogic(t *testing.T) {
 	// We can't easily test checkCertificate without network calls,
 	// but we can test the days calculation logic by understanding how it works
-	
+
 	// The logic in checkCertificate is: int(time.Until(cert.NotAfter).Hours() / 24)
 	// Let's test this calculation directly
-	
+
 	tests := []struct {
 		name        string
 		expiryDate  time.Time
@@ -199,16 +347,448 @@ func TestDaysCalculationLogic(t *testing.T) {
 			expectedMax: -1,
 		},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Replicate the exact calculation from checkCertificate
 			daysLeft := int(time.Until(tt.expiryDate).Hours() / 24)
-			
+
 			if daysLeft < tt.expectedMin || daysLeft > tt.expectedMax {
-				t.Errorf("Days calculation for %s: got %d, expected between %d and %d", 
+				t.Errorf("Days calculation for %s: got %d, expected between %d and %d",
 					tt.name, daysLeft, tt.expectedMin, tt.expectedMax)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestScanAllSitesWithOptions_PreservesOrder(t *testing.T) {
+	originalChecker := certificateChecker
+	defer func() { certificateChecker = originalChecker }()
+
+	// Later sites finish first so the workers complete out of order
+	certificateChecker = func(site Site) CertResult {
+		delay := time.Duration(len(site.Name)) * time.Millisecond
+		time.Sleep(20*time.Millisecond - delay)
+		return CertResult{URL: site.URL, Name: site.Name, LastCheck: time.Now()}
+	}
+
+	sites := []Site{
+		{Name: "a", URL: "a.example.com", Enabled: true},
+		{Name: "bb", URL: "b.example.com", Enabled: true},
+		{Name: "ccc", URL: "c.example.com", Enabled: false},
+		{Name: "dddd", URL: "d.example.com", Enabled: true},
+		{Name: "eeeee", URL: "e.example.com", Enabled: true},
+	}
+
+	results := scanAllSitesWithOptions(sites, ScanOptions{Concurrency: 4})
+
+	expected := []string{"a.example.com", "b.example.com", "d.example.com", "e.example.com"}
+	if len(results.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results.Results))
+	}
+	for i, url := range expected {
+		if results.Results[i].URL != url {
+			t.Errorf("Result[%d]: expected URL %q, got %q", i, url, results.Results[i].URL)
+		}
+	}
+}
+
+func TestScanAllSitesWithOptions_BoundedConcurrency(t *testing.T) {
+	originalChecker := certificateChecker
+	defer func() { certificateChecker = originalChecker }()
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	certificateChecker = func(site Site) CertResult {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		return CertResult{URL: site.URL, Name: site.Name}
+	}
+
+	sites := make([]Site, 12)
+	for i := range sites {
+		sites[i] = Site{Name: fmt.Sprintf("Site %d", i), URL: fmt.Sprintf("site%d.example.com", i), Enabled: true}
+	}
+
+	results := scanAllSitesWithOptions(sites, ScanOptions{Concurrency: 3})
+
+	if len(results.Results) != len(sites) {
+		t.Errorf("Expected %d results, got %d", len(sites), len(results.Results))
+	}
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 concurrent checks, got %d", maxInFlight)
+	}
+	if maxInFlight < 2 {
+		t.Errorf("Expected checks to run concurrently, max in flight was %d", maxInFlight)
+	}
+}
+
+func TestHostRateLimiter(t *testing.T) {
+	limiter := newHostRateLimiter(30 * time.Millisecond)
+
+	start := time.Now()
+	limiter.wait("example.com")
+	limiter.wait("other.example.com") // Different host is not delayed
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("First connections should not be delayed, took %v", elapsed)
+	}
+
+	limiter.wait("example.com")
+	limiter.wait("example.com")
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected third connection to example.com after at least 60ms, got %v", elapsed)
+	}
+}
+
+func TestHostRateLimiter_Disabled(t *testing.T) {
+	limiter := newHostRateLimiter(0)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limiter.wait("example.com")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("Disabled limiter should not delay, took %v", elapsed)
+	}
+}
+
+func TestScanOptionsFromSettings(t *testing.T) {
+	options := scanOptionsFromSettings(Settings{})
+	if options.Concurrency != defaultScanConcurrency {
+		t.Errorf("Expected default concurrency %d, got %d", defaultScanConcurrency, options.Concurrency)
+	}
+	if options.HostInterval != 0 {
+		t.Errorf("Expected rate limit disabled by default, got %v", options.HostInterval)
+	}
+
+	options = scanOptionsFromSettings(Settings{ScanConcurrency: 25, ScanHostIntervalMs: 500})
+	if options.Concurrency != 25 {
+		t.Errorf("Expected concurrency 25, got %d", options.Concurrency)
+	}
+	if options.HostInterval != 500*time.Millisecond {
+		t.Errorf("Expected host interval 500ms, got %v", options.HostInterval)
+	}
+}
+
+func TestSiteTarget(t *testing.T) {
+	tests := []struct {
+		name         string
+		site         Site
+		expectedHost string
+		expectedPort int
+		expectedSNI  string
+	}{
+		{"parsed fields", Site{URL: "ignored", Host: "example.com", Port: 8443}, "example.com", 8443, "example.com"},
+		{"missing port", Site{Host: "example.com"}, "example.com", 443, "example.com"},
+		{"legacy URL only", Site{URL: "example.org:993"}, "example.org", 993, "example.org"},
+		{"SNI override", Site{Host: "192.0.2.1", Port: 443, SNI: "www.example.com"}, "192.0.2.1", 443, "www.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, err := siteTarget(tt.site)
+			if err != nil {
+				t.Fatalf("siteTarget() returned error: %v", err)
+			}
+			if host != tt.expectedHost || port != tt.expectedPort {
+				t.Errorf("Expected %s:%d, got %s:%d", tt.expectedHost, tt.expectedPort, host, port)
+			}
+			if sni := siteServerName(tt.site, host); sni != tt.expectedSNI {
+				t.Errorf("Expected server name %q, got %q", tt.expectedSNI, sni)
+			}
+		})
+	}
+}
+
+func TestCheckCertificate_InvalidURL(t *testing.T) {
+	result := checkCertificate(Site{Name: "Broken", URL: "example.com:notaport"})
+
+	if result.Error == "" {
+		t.Error("Expected an error for an invalid site URL")
+	}
+	if result.Name != "Broken" {
+		t.Errorf("Expected name to be preserved, got %q", result.Name)
+	}
+}
+
+func TestCheckCertificate_VerificationFailures(t *testing.T) {
+	trustedRoot := newTestCert(t, testCertOptions{commonName: "Trusted Root", notAfter: time.Now().Add(365 * 24 * time.Hour), isCA: true})
+	untrustedRoot := newTestCert(t, testCertOptions{commonName: "Untrusted Root", notAfter: time.Now().Add(365 * 24 * time.Hour), isCA: true})
+	trustTestCertificate(t, trustedRoot.cert)
+
+	expiredLeaf := newTestCert(t, testCertOptions{
+		notBefore: time.Now().Add(-90 * 24 * time.Hour),
+		notAfter:  time.Now().Add(-3 * 24 * time.Hour),
+		parent:    trustedRoot,
+	})
+	futureLeaf := newTestCert(t, testCertOptions{
+		notBefore: time.Now().Add(24 * time.Hour),
+		notAfter:  time.Now().Add(90 * 24 * time.Hour),
+		parent:    trustedRoot,
+	})
+	validLeaf := newTestCert(t, testCertOptions{notAfter: time.Now().Add(60 * 24 * time.Hour), parent: trustedRoot})
+	selfSigned := newTestCert(t, testCertOptions{notAfter: time.Now().Add(60 * 24 * time.Hour)})
+	untrustedLeaf := newTestCert(t, testCertOptions{notAfter: time.Now().Add(60 * 24 * time.Hour), parent: untrustedRoot})
+
+	tests := []struct {
+		name         string
+		certificate  tls.Certificate
+		sni          string
+		expectedType string
+		expectedDays int
+	}{
+		{"valid", validLeaf.tlsCertificate(), "", "", 59},
+		{"expired", expiredLeaf.tlsCertificate(), "", verifyErrorExpired, -3},
+		{"not yet valid", futureLeaf.tlsCertificate(), "", verifyErrorNotYetValid, 89},
+		{"hostname mismatch", validLeaf.tlsCertificate(), "www.example.com", verifyErrorHostnameMismatch, 59},
+		{"self-signed", selfSigned.tlsCertificate(), "", verifyErrorSelfSigned, 59},
+		{"unknown authority", untrustedLeaf.tlsCertificate(untrustedRoot), "", verifyErrorUnknownAuthority, 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startFakeServer(t, tt.certificate, nil)
+
+			result := checkCertificate(Site{Name: tt.name, URL: "127.0.0.1", Host: "127.0.0.1", Port: port, SNI: tt.sni})
+
+			if result.Error != "" {
+				t.Fatalf("Verification problems should not set Error, got %q", result.Error)
+			}
+			if result.VerifyErrorType != tt.expectedType {
+				t.Errorf("Expected verify error type %q, got %q (%s)", tt.expectedType, result.VerifyErrorType, result.VerifyError)
+			}
+			if tt.expectedType != "" && result.VerifyError == "" {
+				t.Error("Expected VerifyError to describe the failure")
+			}
+			if result.ExpiryDate.IsZero() {
+				t.Error("Expected expiry date to be recorded")
+			}
+			if result.Certificate == nil || result.Certificate.FingerprintSHA256 == "" {
+				t.Error("Expected certificate details to be recorded")
+			}
+			if result.DaysLeft < tt.expectedDays-1 || result.DaysLeft > tt.expectedDays {
+				t.Errorf("Expected about %d days left, got %d", tt.expectedDays, result.DaysLeft)
+			}
+		})
+	}
+}
+
+func TestCertificateDetails(t *testing.T) {
+	notAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	generated := newTestCert(t, testCertOptions{commonName: "details.example.com", notAfter: notAfter})
+
+	details := certificateDetails(generated.cert)
+
+	if details.Subject != "CN=details.example.com" {
+		t.Errorf("Expected subject 'CN=details.example.com', got %q", details.Subject)
+	}
+	if details.Issuer != "CN=details.example.com" {
+		t.Errorf("Expected self-signed issuer, got %q", details.Issuer)
+	}
+	if len(details.SANs) != 2 || details.SANs[0] != "localhost" || details.SANs[1] != "127.0.0.1" {
+		t.Errorf("Expected SANs [localhost 127.0.0.1], got %v", details.SANs)
+	}
+	if !details.NotAfter.Equal(notAfter) {
+		t.Errorf("Expected NotAfter %v, got %v", notAfter, details.NotAfter)
+	}
+	if details.KeyAlgorithm != "ECDSA" || details.KeySize != 256 {
+		t.Errorf("Expected ECDSA 256, got %s %d", details.KeyAlgorithm, details.KeySize)
+	}
+	if details.SignatureAlgorithm != "ECDSA-SHA256" {
+		t.Errorf("Expected ECDSA-SHA256 signature, got %q", details.SignatureAlgorithm)
+	}
+
+	sum := sha256.Sum256(generated.cert.Raw)
+	if details.FingerprintSHA256 != colonHex(sum[:]) {
+		t.Errorf("Fingerprint mismatch: got %q", details.FingerprintSHA256)
+	}
+	if len(details.FingerprintSHA256) != 32*3-1 {
+		t.Errorf("Expected 32 colon separated bytes, got %q", details.FingerprintSHA256)
+	}
+	keySum := sha256.Sum256(generated.cert.RawSubjectPublicKeyInfo)
+	if details.PublicKeySHA256 != colonHex(keySum[:]) {
+		t.Errorf("Public key fingerprint mismatch: got %q", details.PublicKeySHA256)
+	}
+	if details.SerialNumber != colonHex(generated.cert.SerialNumber.Bytes()) {
+		t.Errorf("Serial number mismatch: got %q", details.SerialNumber)
+	}
+}
+
+func TestColonHex(t *testing.T) {
+	if got := colonHex([]byte{0x01, 0xab, 0xff}); got != "01:AB:FF" {
+		t.Errorf("colonHex() = %q, want 01:AB:FF", got)
+	}
+	if got := colonHex(nil); got != "" {
+		t.Errorf("colonHex(nil) = %q, want empty", got)
+	}
+}
+
+func TestCheckCertificate_IntermediateExpiresFirst(t *testing.T) {
+	root := newTestCert(t, testCertOptions{commonName: "Test Root", notAfter: time.Now().Add(365 * 24 * time.Hour), isCA: true})
+	intermediate := newTestCert(t, testCertOptions{
+		commonName: "Test Intermediate",
+		notAfter:   time.Now().Add(10*24*time.Hour + time.Hour),
+		isCA:       true,
+		parent:     root,
+	})
+	leaf := newTestCert(t, testCertOptions{notAfter: time.Now().Add(60 * 24 * time.Hour), parent: intermediate})
+	trustTestCertificate(t, root.cert)
+
+	port := startFakeServer(t, leaf.tlsCertificate(intermediate), nil)
+	result := checkCertificate(Site{Name: "Chain", URL: "127.0.0.1", Host: "127.0.0.1", Port: port})
+
+	if result.Error != "" || result.VerifyError != "" {
+		t.Fatalf("Expected a clean check, got error %q verify error %q", result.Error, result.VerifyError)
+	}
+	if len(result.Chain) != 2 {
+		t.Fatalf("Expected 2 certificates in chain, got %d", len(result.Chain))
+	}
+	if result.ExpiringChainIndex != 1 {
+		t.Errorf("Expected intermediate (index 1) to expire first, got index %d", result.ExpiringChainIndex)
+	}
+	if !result.ExpiryDate.Equal(intermediate.cert.NotAfter) {
+		t.Errorf("Expected expiry %v from intermediate, got %v", intermediate.cert.NotAfter, result.ExpiryDate)
+	}
+	if result.DaysLeft != 10 {
+		t.Errorf("Expected 10 days left, got %d", result.DaysLeft)
+	}
+	if !result.Certificate.NotAfter.Equal(leaf.cert.NotAfter) {
+		t.Errorf("Expected leaf details to keep the leaf expiry, got %v", result.Certificate.NotAfter)
+	}
+	if description := expiringElementDescription(result); description != "intermediate certificate CN=Test Intermediate" {
+		t.Errorf("Unexpected expiring element description %q", description)
+	}
+}
+
+func TestChainElementDescription(t *testing.T) {
+	chain := []CertDetails{
+		{Subject: "CN=example.com", Issuer: "CN=R3"},
+		{Subject: "CN=R3", Issuer: "CN=ISRG Root X1"},
+		{Subject: "CN=ISRG Root X1", Issuer: "CN=ISRG Root X1"},
+	}
+
+	tests := []struct {
+		index    int
+		expected string
+	}{
+		{0, "leaf certificate"},
+		{1, "intermediate certificate CN=R3"},
+		{2, "root certificate CN=ISRG Root X1"},
+		{5, "certificate"},
+	}
+
+	for _, tt := range tests {
+		if got := chainElementDescription(chain, tt.index); got != tt.expected {
+			t.Errorf("chainElementDescription(%d) = %q, want %q", tt.index, got, tt.expected)
+		}
+	}
+
+	// Results saved before chains were recorded
+	if got := expiringElementDescription(CertResult{}); got != "certificate" {
+		t.Errorf("Expected generic description without a chain, got %q", got)
+	}
+}
+
+// fakeLookup makes lookupAddresses return addresses for the rest of the test
+func fakeLookup(t *testing.T, addresses ...string) {
+	t.Helper()
+
+	originalLookup := lookupAddresses
+	lookupAddresses = func(host string) ([]string, error) {
+		return addresses, nil
+	}
+	t.Cleanup(func() { lookupAddresses = originalLookup })
+}
+
+func TestCheckCertificate_MultipleAddresses(t *testing.T) {
+	root := newTestCert(t, testCertOptions{commonName: "Test Root", notAfter: time.Now().Add(365 * 24 * time.Hour), isCA: true})
+	fresh := newTestCert(t, testCertOptions{notAfter: time.Now().Add(80 * 24 * time.Hour), parent: root})
+	stale := newTestCert(t, testCertOptions{notAfter: time.Now().Add(12*24*time.Hour + time.Hour), parent: root})
+	trustTestCertificate(t, root.cert)
+
+	port := startFakeServer(t, fresh.tlsCertificate(), nil)
+	startFakeServerOn(t, fmt.Sprintf("127.0.0.2:%d", port), stale.tlsCertificate(), nil)
+	fakeLookup(t, "127.0.0.1", "127.0.0.2")
+
+	result := checkCertificate(Site{Name: "Balanced", URL: "localhost", Host: "localhost", Port: port})
+
+	if result.Error != "" {
+		t.Fatalf("Expected no error, got %q", result.Error)
+	}
+	if len(result.Addresses) != 2 {
+		t.Fatalf("Expected 2 address results, got %d", len(result.Addresses))
+	}
+	if result.Addresses[0].Address != "127.0.0.1" || result.Addresses[1].Address != "127.0.0.2" {
+		t.Errorf("Unexpected addresses %+v", result.Addresses)
+	}
+	if !result.Inconsistent {
+		t.Error("Expected site to be flagged inconsistent when nodes serve different certificates")
+	}
+	if result.DaysLeft != 12 {
+		t.Errorf("Expected the stale node's 12 days to be reported, got %d", result.DaysLeft)
+	}
+	if result.Certificate.FingerprintSHA256 != result.Addresses[1].FingerprintSHA256 {
+		t.Error("Expected site certificate details to come from the stale node")
+	}
+}
+
+func TestCheckCertificate_MultipleAddressesConsistent(t *testing.T) {
+	certificate, cert := newTestCertificate(t, time.Now().Add(30*24*time.Hour))
+	trustTestCertificate(t, cert)
+
+	port := startFakeServer(t, certificate, nil)
+	startFakeServerOn(t, fmt.Sprintf("127.0.0.2:%d", port), certificate, nil)
+	// The third node is down
+	fakeLookup(t, "127.0.0.1", "127.0.0.2", "127.0.0.3")
+
+	result := checkCertificate(Site{Name: "Balanced", URL: "localhost", Host: "localhost", Port: port})
+
+	if result.Error != "" {
+		t.Fatalf("One failing node should not fail the site, got %q", result.Error)
+	}
+	if result.FailedAddresses != 1 {
+		t.Errorf("Expected the failing node to be counted, got %d", result.FailedAddresses)
+	}
+	if !strings.HasPrefix(nodeFailureSummary(result), "1 of 3 addresses behind localhost failed, 127.0.0.3: ") {
+		t.Errorf("Unexpected node failure summary %q", nodeFailureSummary(result))
+	}
+	if result.Inconsistent {
+		t.Error("Nodes serving the same certificate should not be flagged inconsistent")
+	}
+	if len(result.Addresses) != 3 {
+		t.Fatalf("Expected 3 address results, got %d", len(result.Addresses))
+	}
+	if result.Addresses[2].Error == "" {
+		t.Error("Expected the unreachable node to record an error")
+	}
+}
+
+func TestCheckCertificate_AllAddressesFail(t *testing.T) {
+	fakeLookup(t, "127.0.0.1")
+
+	// Grab a free port and close it so the connection is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	result := checkCertificate(Site{Name: "Down", URL: "localhost", Host: "localhost", Port: port})
+
+	if result.Error == "" {
+		t.Error("Expected an error when every address fails")
+	}
+	if len(result.Addresses) != 0 {
+		t.Errorf("Single address sites should not list addresses, got %d", len(result.Addresses))
+	}
+}
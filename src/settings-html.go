@@ -119,7 +119,7 @@ const settingsTemplate = `
             margin-left: 5px; 
             color: var(--text-color);
         }
-        input, select { 
+        input, select, textarea { 
             width: 300px; 
             padding: 8px; 
             border: 1px solid var(--input-border);
@@ -130,6 +130,12 @@ const settingsTemplate = `
         input[type="checkbox"] { 
             width: auto; 
         }
+        textarea {
+            width: 600px;
+            max-width: 100%;
+            font-family: monospace;
+            font-size: 13px;
+        }
         button { 
             padding: 10px 15px; 
             margin-right: 10px; 
@@ -172,6 +178,7 @@ const settingsTemplate = `
     <div class="nav">
         <a href="/results">Results</a>
         <a href="/sites">Sites</a>
+        <a href="/notifications">Notifications</a>
         <a href="/settings" class="active">Settings</a>
     </div>
 
@@ -180,13 +187,28 @@ const settingsTemplate = `
         <div class="subtitle">Configure scanning intervals, notification thresholds, and alert services</div>
     </div>
     
-    <form method="post">
+    <form method="post" id="settings-form">
         <div class="section">
             <h2>Scanning</h2>
             <div class="form-group">
                 <label>Scan Interval (hours):</label>
                 <input type="number" name="scan_interval_hours" value="{{.ScanIntervalHours}}" min="1">
             </div>
+            <div class="form-group">
+                <label>Concurrent Checks:</label>
+                <input type="number" name="scan_concurrency" value="{{.ScanConcurrency}}" min="1">
+                <div class="help-text">How many sites are checked at the same time during a scan</div>
+            </div>
+            <div class="form-group">
+                <label>Per-Host Interval (ms):</label>
+                <input type="number" name="scan_host_interval_ms" value="{{.ScanHostIntervalMs}}" min="0">
+                <div class="help-text">Minimum time between connections to the same host, 0 for no limit</div>
+            </div>
+            <div class="form-group">
+                <label>History Retention (days):</label>
+                <input type="number" name="history_retention_days" value="{{.HistoryRetentionDays}}" min="1">
+                <div class="help-text">How long each site's scan history is kept</div>
+            </div>
         </div>
 
         <div class="section">
@@ -201,6 +223,158 @@ const settingsTemplate = `
                 <input type="number" name="dashboard_critical" value="{{.Dashboard.ColorThresholds.Critical}}" min="1">
                 <div class="help-text">Sites with certificates expiring within this many days will show as red</div>
             </div>
+            <div class="form-group">
+                <label>Scan Error Alert After (failed scans):</label>
+                <input type="number" name="scan_error_threshold" value="{{.ScanErrorThreshold}}" min="1">
+                <div class="help-text">Sites whose check fails this many scans in a row raise an error alert</div>
+            </div>
+            <div class="form-group">
+                <label>Notification Delivery Attempts:</label>
+                <input type="number" name="delivery_retry_limit" value="{{.DeliveryRetryLimit}}" min="1">
+                <div class="help-text">A notification a channel fails to deliver is retried with increasing delays, then shown as failed on the <a href="/outbox">delivery queue</a> after this many attempts</div>
+            </div>
+            <div class="form-group">
+                <label>
+                    <input type="checkbox" name="batch_notifications" {{if .BatchNotifications}}checked{{end}}>
+                    Batch alerts from each scan
+                </label>
+                <div class="help-text">Send each channel one message per severity listing every site that changed status in a scan, instead of one message per site. Useful when a wildcard certificate covers many sites</div>
+            </div>
+            <div class="form-group">
+                <label>Dashboard URL:</label>
+                <input type="url" name="dashboard_url" value="{{.Dashboard.URL}}" placeholder="https://ssl-monitor.example.com">
+                <div class="help-text">Used for links back to the dashboard in notifications. Defaults to http://localhost and the dashboard port</div>
+            </div>
+        </div>
+
+        <div class="section">
+            <h2>Reminders & Escalation</h2>
+            <div class="form-group">
+                <label>Warning Reminder (hours):</label>
+                <input type="number" name="reminder_warning_hours" value="{{.Reminders.WarningHours}}" min="0">
+                <div class="help-text">Repeat the alert this often while a site stays in warning, 0 for no reminders</div>
+            </div>
+            <div class="form-group">
+                <label>Critical Reminder (hours):</label>
+                <input type="number" name="reminder_critical_hours" value="{{.Reminders.CriticalHours}}" min="0">
+                <div class="help-text">Repeat the alert this often while a site stays critical, 0 for no reminders</div>
+            </div>
+            <div class="form-group">
+                <label>Scan Error Reminder (hours):</label>
+                <input type="number" name="reminder_error_hours" value="{{.Reminders.ErrorHours}}" min="0">
+                <div class="help-text">Repeat the alert this often while a site's checks keep failing, 0 for no reminders</div>
+            </div>
+            <div class="form-group">
+                <label>Escalate After (reminders):</label>
+                <input type="number" name="reminder_escalate_after" value="{{.Reminders.EscalateAfter}}" min="0">
+                <div class="help-text">Escalate when a site is still critical after this many reminders, 0 to never escalate</div>
+            </div>
+            <div class="form-group">
+                <label>Escalation Channels:</label>
+                <input type="text" name="reminder_escalation_channels" value="{{range $i, $channel := .Reminders.EscalationChannels}}{{if $i}}, {{end}}{{$channel}}{{end}}" placeholder="slack, teams">
+                <div class="help-text">Comma separated channels that also get escalated reminders: email, ntfy, webhook, slack, teams, discord</div>
+            </div>
+            <div class="form-group">
+                <label>Escalation Email Recipients:</label>
+                <input type="text" name="reminder_escalation_email_to" value="{{.Reminders.EscalationEmailTo}}" placeholder="manager@example.com">
+                <div class="help-text">Added to the To line of escalated emails</div>
+            </div>
+        </div>
+
+        <div class="section">
+            <h2>Digest Report</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="digest_enabled" name="digest_enabled" {{if .Digest.Enabled}}checked{{end}}>
+                    <label for="digest_enabled" class="checkbox-label">Send a scheduled digest of every certificate</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>Frequency:</label>
+                <select name="digest_frequency">
+                    <option value="weekly" {{if ne .Digest.Frequency "daily"}}selected{{end}}>Weekly</option>
+                    <option value="daily" {{if eq .Digest.Frequency "daily"}}selected{{end}}>Daily</option>
+                </select>
+            </div>
+            <div class="form-group">
+                <label>Weekday:</label>
+                <select name="digest_weekday">
+                    <option value="0" {{if eq .Digest.Weekday 0}}selected{{end}}>Sunday</option>
+                    <option value="1" {{if eq .Digest.Weekday 1}}selected{{end}}>Monday</option>
+                    <option value="2" {{if eq .Digest.Weekday 2}}selected{{end}}>Tuesday</option>
+                    <option value="3" {{if eq .Digest.Weekday 3}}selected{{end}}>Wednesday</option>
+                    <option value="4" {{if eq .Digest.Weekday 4}}selected{{end}}>Thursday</option>
+                    <option value="5" {{if eq .Digest.Weekday 5}}selected{{end}}>Friday</option>
+                    <option value="6" {{if eq .Digest.Weekday 6}}selected{{end}}>Saturday</option>
+                </select>
+                <div class="help-text">Only used for weekly digests</div>
+            </div>
+            <div class="form-group">
+                <label>Hour (0-23):</label>
+                <input type="number" name="digest_hour" value="{{.Digest.Hour}}" min="0" max="23">
+                <div class="help-text">In the server's time zone</div>
+            </div>
+            <div class="form-group">
+                <label>Digest Email Recipients:</label>
+                <input type="text" name="digest_email_to" value="{{.Digest.EmailTo}}" placeholder="management@example.com">
+                <div class="help-text">Sent through the email provider below. Leave empty to use the email notification recipients</div>
+            </div>
+            <div class="form-group">
+                <label>Other Channels:</label>
+                <input type="text" name="digest_channels" value="{{range $i, $channel := .Digest.Channels}}{{if $i}}, {{end}}{{$channel}}{{end}}" placeholder="slack">
+                <div class="help-text">Comma separated channels that also get the digest: ntfy, webhook, slack, teams, discord</div>
+            </div>
+            <button type="button" class="test-btn" onclick="sendDigest()">Send Digest Now</button>
+            <div class="help-text">Uses the saved settings</div>
+        </div>
+
+        <div class="section">
+            <h2>Quiet Hours</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="quiet_hours_enabled" name="quiet_hours_enabled" {{if .QuietHours.Enabled}}checked{{end}}>
+                    <label for="quiet_hours_enabled" class="checkbox-label">Hold notifications during quiet hours</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="quiet_hours_hold_critical" name="quiet_hours_hold_critical" {{if .QuietHours.HoldCritical}}checked{{end}}>
+                    <label for="quiet_hours_hold_critical" class="checkbox-label">Hold critical alerts too</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>From:</label>
+                <input type="time" name="quiet_hours_start" value="{{.QuietHours.Start}}">
+            </div>
+            <div class="form-group">
+                <label>Until:</label>
+                <input type="time" name="quiet_hours_end" value="{{.QuietHours.End}}">
+                <div class="help-text">Quiet hours ending before they start run past midnight</div>
+            </div>
+            <div class="form-group">
+                <label>Time Zone:</label>
+                <input type="text" name="quiet_hours_timezone" value="{{.QuietHours.Timezone}}" placeholder="Europe/London">
+                <div class="help-text">IANA time zone name. Leave empty to use the server's time zone</div>
+            </div>
+            <div class="help-text">Held notifications are queued and sent once quiet hours end. Sites can also be snoozed on the Results page, or given maintenance windows on the Sites page.</div>
+        </div>
+
+        <div class="section">
+            <h2>Notification Routes</h2>
+            <div class="help-text">Sites with a route set on the Sites page are only sent to that route's channels, with its recipients in place of the ones configured below. Sites without a route use every enabled channel. Clear a route's name to remove it.</div>
+            {{range $i, $route := .RouteRows}}
+            <div class="form-group">
+                <label>{{if $route.Name}}Route "{{$route.Name}}"{{else}}New Route{{end}}</label>
+                <input type="text" name="route_{{$i}}_name" value="{{$route.Name}}" placeholder="Name, e.g. marketing">
+                <input type="text" name="route_{{$i}}_channels" value="{{range $j, $channel := $route.Channels}}{{if $j}}, {{end}}{{$channel}}{{end}}" placeholder="Channels, e.g. email, slack">
+                <input type="text" name="route_{{$i}}_severities" value="{{range $j, $severity := $route.Severities}}{{if $j}}, {{end}}{{$severity}}{{end}}" placeholder="Severities, empty uses the channel toggles">
+                <input type="text" name="route_{{$i}}_recipient_email" value="{{index $route.Recipients "email"}}" placeholder="Email recipients">
+                <input type="text" name="route_{{$i}}_recipient_ntfy" value="{{index $route.Recipients "ntfy"}}" placeholder="NTFY topic URL">
+                <input type="text" name="route_{{$i}}_recipient_webhook" value="{{index $route.Recipients "webhook"}}" placeholder="Webhook URL">
+                <input type="text" name="route_{{$i}}_recipient_slack" value="{{index $route.Recipients "slack"}}" placeholder="Slack webhook URL">
+                <input type="text" name="route_{{$i}}_recipient_teams" value="{{index $route.Recipients "teams"}}" placeholder="Teams webhook URL">
+                <input type="text" name="route_{{$i}}_recipient_discord" value="{{index $route.Recipients "discord"}}" placeholder="Discord webhook URL">
+            </div>
+            {{end}}
+            <div class="help-text">Channels: email, ntfy, webhook, slack, teams, discord. Severities: warning, critical, error, change, resolved. Recipients left empty use the channel's own settings.</div>
         </div>
 
         <div class="section">
@@ -214,24 +388,74 @@ const settingsTemplate = `
                     <input type="checkbox" id="email_critical" name="email_enabled_critical" {{if .Notifications.Email.EnabledCritical}}checked{{end}}>
                     <label for="email_critical" class="checkbox-label">Enable for Critical</label>
                 </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="email_change" name="email_enabled_change" {{if .Notifications.Email.EnabledChange}}checked{{end}}>
+                    <label for="email_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="email_resolved" name="email_enabled_resolved" {{if .Notifications.Email.EnabledResolved}}checked{{end}}>
+                    <label for="email_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="email_error" name="email_enabled_error" {{if .Notifications.Email.EnabledError}}checked{{end}}>
+                    <label for="email_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
             </div>
             <div class="form-group">
-                <label>Server Token:</label>
-                <input type="text" name="email_server_token" value="{{.Notifications.Email.ServerToken}}">
+                <label>Provider:</label>
+                <select name="email_provider" id="email_provider" onchange="showEmailProvider()">
+                    <option value="postmark" {{if ne .Notifications.Email.Provider "smtp"}}selected{{end}}>Postmark</option>
+                    <option value="smtp" {{if eq .Notifications.Email.Provider "smtp"}}selected{{end}}>SMTP server</option>
+                </select>
             </div>
             <div class="form-group">
                 <label>From:</label>
-                <input type="email" name="email_from" value="{{.Notifications.Email.From}}">
+                <input type="text" name="email_from" value="{{.Notifications.Email.From}}">
             </div>
             <div class="form-group">
                 <label>To:</label>
-                <input type="email" name="email_to" value="{{.Notifications.Email.To}}">
+                <input type="text" name="email_to" value="{{.Notifications.Email.To}}">
+                <div class="help-text">Separate several addresses with commas</div>
             </div>
-            <div class="form-group">
-                <label>Message Stream:</label>
-                <input type="text" name="email_message_stream" value="{{.Notifications.Email.MessageStream}}">
+            <div class="provider-fields" data-provider="postmark">
+                <div class="form-group">
+                    <label>Server Token:</label>
+                    <input type="text" name="email_server_token" value="{{.Notifications.Email.ServerToken}}">
+                </div>
+                <div class="form-group">
+                    <label>Message Stream:</label>
+                    <input type="text" name="email_message_stream" value="{{.Notifications.Email.MessageStream}}">
+                </div>
+            </div>
+            <div class="provider-fields" data-provider="smtp">
+                <div class="form-group">
+                    <label>SMTP Host:</label>
+                    <input type="text" name="email_smtp_host" value="{{.Notifications.Email.SMTPHost}}" placeholder="mail.example.com">
+                </div>
+                <div class="form-group">
+                    <label>Security:</label>
+                    <select name="email_smtp_security">
+                        <option value="starttls" {{if or (eq .Notifications.Email.SMTPSecurity "starttls") (eq .Notifications.Email.SMTPSecurity "")}}selected{{end}}>STARTTLS</option>
+                        <option value="tls" {{if eq .Notifications.Email.SMTPSecurity "tls"}}selected{{end}}>Implicit TLS</option>
+                        <option value="none" {{if eq .Notifications.Email.SMTPSecurity "none"}}selected{{end}}>None</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label>Port:</label>
+                    <input type="number" name="email_smtp_port" value="{{if .Notifications.Email.SMTPPort}}{{.Notifications.Email.SMTPPort}}{{end}}" min="1" max="65535" placeholder="587">
+                    <div class="help-text">Leave empty for the usual port: 587 for STARTTLS, 465 for implicit TLS, 25 for none</div>
+                </div>
+                <div class="form-group">
+                    <label>Username:</label>
+                    <input type="text" name="email_smtp_username" value="{{.Notifications.Email.SMTPUsername}}" autocomplete="off">
+                </div>
+                <div class="form-group">
+                    <label>Password:</label>
+                    <input type="password" name="email_smtp_password" value="{{.Notifications.Email.SMTPPassword}}" autocomplete="off">
+                    <div class="help-text">Leave the username empty if the relay doesn't need authentication</div>
+                </div>
             </div>
-            <button type="button" class="test-btn" onclick="testEmail()">Test Email</button>
+            <button type="button" class="test-btn" onclick="testNotifier('email')">Test Email</button>
         </div>
 
         <div class="section">
@@ -245,47 +469,316 @@ const settingsTemplate = `
                     <input type="checkbox" id="ntfy_critical" name="ntfy_enabled_critical" {{if .Notifications.Ntfy.EnabledCritical}}checked{{end}}>
                     <label for="ntfy_critical" class="checkbox-label">Enable for Critical</label>
                 </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="ntfy_change" name="ntfy_enabled_change" {{if .Notifications.Ntfy.EnabledChange}}checked{{end}}>
+                    <label for="ntfy_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="ntfy_resolved" name="ntfy_enabled_resolved" {{if .Notifications.Ntfy.EnabledResolved}}checked{{end}}>
+                    <label for="ntfy_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="ntfy_error" name="ntfy_enabled_error" {{if .Notifications.Ntfy.EnabledError}}checked{{end}}>
+                    <label for="ntfy_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
             </div>
             <div class="form-group">
                 <label>NTFY URL:</label>
                 <input type="url" name="ntfy_url" value="{{.Notifications.Ntfy.URL}}">
             </div>
-            <button type="button" class="test-btn" onclick="testNtfy()">Test NTFY</button>
+            <button type="button" class="test-btn" onclick="testNotifier('ntfy')">Test NTFY</button>
+        </div>
+
+        <div class="section">
+            <h2>Webhook Notifications</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="webhook_warning" name="webhook_enabled_warning" {{if .Notifications.Webhook.EnabledWarning}}checked{{end}}>
+                    <label for="webhook_warning" class="checkbox-label">Enable for Warning</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="webhook_critical" name="webhook_enabled_critical" {{if .Notifications.Webhook.EnabledCritical}}checked{{end}}>
+                    <label for="webhook_critical" class="checkbox-label">Enable for Critical</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="webhook_change" name="webhook_enabled_change" {{if .Notifications.Webhook.EnabledChange}}checked{{end}}>
+                    <label for="webhook_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="webhook_resolved" name="webhook_enabled_resolved" {{if .Notifications.Webhook.EnabledResolved}}checked{{end}}>
+                    <label for="webhook_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="webhook_error" name="webhook_enabled_error" {{if .Notifications.Webhook.EnabledError}}checked{{end}}>
+                    <label for="webhook_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>Webhook URL:</label>
+                <input type="url" name="webhook_url" value="{{.Notifications.Webhook.URL}}">
+            </div>
+            <div class="form-group">
+                <label>Method:</label>
+                <select name="webhook_method">
+                    <option value="POST" {{if or (eq .Notifications.Webhook.Method "POST") (eq .Notifications.Webhook.Method "")}}selected{{end}}>POST</option>
+                    <option value="PUT" {{if eq .Notifications.Webhook.Method "PUT"}}selected{{end}}>PUT</option>
+                    <option value="PATCH" {{if eq .Notifications.Webhook.Method "PATCH"}}selected{{end}}>PATCH</option>
+                </select>
+            </div>
+            <div class="form-group">
+                <label>Headers:</label>
+                <textarea name="webhook_headers" rows="3" placeholder="Authorization: Bearer ...">{{.Notifications.Webhook.HeaderLines}}</textarea>
+                <div class="help-text">One "Name: value" per line. Content-Type defaults to application/json</div>
+            </div>
+            <div class="form-group">
+                <label>Body Template:</label>
+                <textarea name="webhook_body_template" rows="10" placeholder="Leave empty for the default JSON body">{{.Notifications.Webhook.BodyTemplate}}</textarea>
+                <div class="help-text">Go text/template with .Result, .OldStatus, .NewStatus, .Change and .Settings. Use {{"{{"}}json .Result.Name{{"}}"}} to quote values for JSON</div>
+            </div>
+            <div class="form-group">
+                <label>HMAC Secret:</label>
+                <input type="password" name="webhook_hmac_secret" value="{{.Notifications.Webhook.HMACSecret}}" autocomplete="off">
+                <div class="help-text">When set, the body is signed with HMAC-SHA256 and sent as "sha256=&lt;hex&gt;"</div>
+            </div>
+            <div class="form-group">
+                <label>Signature Header:</label>
+                <input type="text" name="webhook_hmac_header" value="{{.Notifications.Webhook.HMACHeader}}" placeholder="X-Signature-256">
+            </div>
+            <button type="button" class="test-btn" onclick="testNotifier('webhook')">Test Webhook</button>
+        </div>
+
+        <div class="section">
+            <h2>Slack Notifications</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="slack_warning" name="slack_enabled_warning" {{if .Notifications.Slack.EnabledWarning}}checked{{end}}>
+                    <label for="slack_warning" class="checkbox-label">Enable for Warning</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="slack_critical" name="slack_enabled_critical" {{if .Notifications.Slack.EnabledCritical}}checked{{end}}>
+                    <label for="slack_critical" class="checkbox-label">Enable for Critical</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="slack_change" name="slack_enabled_change" {{if .Notifications.Slack.EnabledChange}}checked{{end}}>
+                    <label for="slack_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="slack_resolved" name="slack_enabled_resolved" {{if .Notifications.Slack.EnabledResolved}}checked{{end}}>
+                    <label for="slack_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="slack_error" name="slack_enabled_error" {{if .Notifications.Slack.EnabledError}}checked{{end}}>
+                    <label for="slack_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>Webhook URL:</label>
+                <input type="url" name="slack_webhook_url" value="{{.Notifications.Slack.WebhookURL}}" placeholder="https://hooks.slack.com/services/...">
+                <div class="help-text">Create an incoming webhook in your Slack app settings</div>
+            </div>
+            <button type="button" class="test-btn" onclick="testNotifier('slack')">Test Slack</button>
+        </div>
+
+        <div class="section">
+            <h2>Microsoft Teams Notifications</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="teams_warning" name="teams_enabled_warning" {{if .Notifications.Teams.EnabledWarning}}checked{{end}}>
+                    <label for="teams_warning" class="checkbox-label">Enable for Warning</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="teams_critical" name="teams_enabled_critical" {{if .Notifications.Teams.EnabledCritical}}checked{{end}}>
+                    <label for="teams_critical" class="checkbox-label">Enable for Critical</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="teams_change" name="teams_enabled_change" {{if .Notifications.Teams.EnabledChange}}checked{{end}}>
+                    <label for="teams_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="teams_resolved" name="teams_enabled_resolved" {{if .Notifications.Teams.EnabledResolved}}checked{{end}}>
+                    <label for="teams_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="teams_error" name="teams_enabled_error" {{if .Notifications.Teams.EnabledError}}checked{{end}}>
+                    <label for="teams_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>Webhook URL:</label>
+                <input type="url" name="teams_webhook_url" value="{{.Notifications.Teams.WebhookURL}}" placeholder="https://prod-00.westeurope.logic.azure.com/workflows/...">
+                <div class="help-text">An incoming webhook or a Workflows "post to a channel when a webhook request is received" URL</div>
+            </div>
+            <button type="button" class="test-btn" onclick="testNotifier('teams')">Test Teams</button>
+        </div>
+
+        <div class="section">
+            <h2>Discord Notifications</h2>
+            <div class="notification-toggles">
+                <div class="toggle-group">
+                    <input type="checkbox" id="discord_warning" name="discord_enabled_warning" {{if .Notifications.Discord.EnabledWarning}}checked{{end}}>
+                    <label for="discord_warning" class="checkbox-label">Enable for Warning</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="discord_critical" name="discord_enabled_critical" {{if .Notifications.Discord.EnabledCritical}}checked{{end}}>
+                    <label for="discord_critical" class="checkbox-label">Enable for Critical</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="discord_change" name="discord_enabled_change" {{if .Notifications.Discord.EnabledChange}}checked{{end}}>
+                    <label for="discord_change" class="checkbox-label">Enable for Unexpected Certificate Change</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="discord_resolved" name="discord_enabled_resolved" {{if .Notifications.Discord.EnabledResolved}}checked{{end}}>
+                    <label for="discord_resolved" class="checkbox-label">Enable for Resolved (Certificate Renewed)</label>
+                </div>
+                <div class="toggle-group">
+                    <input type="checkbox" id="discord_error" name="discord_enabled_error" {{if .Notifications.Discord.EnabledError}}checked{{end}}>
+                    <label for="discord_error" class="checkbox-label">Enable for Scan Errors</label>
+                </div>
+            </div>
+            <div class="form-group">
+                <label>Webhook URL:</label>
+                <input type="url" name="discord_webhook_url" value="{{.Notifications.Discord.WebhookURL}}" placeholder="https://discord.com/api/webhooks/...">
+                <div class="help-text">Channel settings → Integrations → Webhooks</div>
+            </div>
+            <button type="button" class="test-btn" onclick="testNotifier('discord')">Test Discord</button>
+        </div>
+
+        <div class="section">
+            <h2>Message Templates</h2>
+            <div class="form-group">
+                <label>Channel:</label>
+                <select id="template_channel" onchange="showTemplate()">
+                    <option value="email">Email</option>
+                    <option value="ntfy">NTFY</option>
+                    <option value="slack">Slack</option>
+                    <option value="teams">Microsoft Teams</option>
+                    <option value="discord">Discord</option>
+                </select>
+            </div>
+            <div class="form-group">
+                <label>Severity:</label>
+                <select id="template_severity" onchange="showTemplate()">
+                    <option value="warning">Warning</option>
+                    <option value="critical">Critical</option>
+                    <option value="error">Scan Error</option>
+                    <option value="change">Certificate Change</option>
+                    <option value="resolved">Resolved</option>
+                </select>
+            </div>
+            {{range .MessageTemplateFields}}
+            <div class="template-fields" data-channel="{{.Channel}}" data-severity="{{.Severity}}" style="display: none;">
+                <div class="form-group">
+                    <label>Title:</label>
+                    <input type="text" name="template_{{.Channel}}_{{.Severity}}_title" value="{{.Title}}" style="width: 600px; max-width: 100%;">
+                </div>
+                <div class="form-group">
+                    <label>Body:</label>
+                    <textarea name="template_{{.Channel}}_{{.Severity}}_body" rows="10">{{.Body}}</textarea>
+                </div>
+            </div>
+            {{end}}
+            <div class="help-text">
+                Go templates with .Result (.Name, .URL, .DaysLeft, .ExpiryDate, .LastCheck, .Error), .Severity, .PreviousStatus,
+                .Change (.OldIssuer, .NewIssuer, .OldExpiry, .NewExpiry, .NewFingerprint), .ChangeDescription, .Failures, .NodeFailure,
+                .Reminder, .Escalated, .ExpiringCertificate, .ResolvedDescription, .AlertTitle, .AlertReference, .SiteURL and .DashboardURL.
+                Functions: date, datetime, upper, lower. Email bodies are HTML, the other channels plain text.
+                Templates matching the default aren't saved, so they follow future changes to it.
+            </div>
+            <p>
+                <button type="button" class="test-btn" onclick="insertDefaultTemplate()">Insert Default</button>
+                <button type="button" class="test-btn" onclick="previewTemplate()">Preview</button>
+            </p>
+            <div id="template_preview" style="display: none;">
+                <label>Preview (sample site):</label>
+                <div id="template_preview_title"></div>
+                <iframe id="template_preview_html" sandbox="" style="width: 100%; height: 300px; border: 1px solid var(--input-border); background: white;"></iframe>
+                <pre id="template_preview_text" style="white-space: pre-wrap;"></pre>
+            </div>
         </div>
 
         <button type="submit" class="save-btn">Save Settings</button>
     </form>
 
     <script>
-        function testEmail() {
-            // Read current form values
-            const formData = {
-                server_token: document.querySelector('[name="email_server_token"]').value,
-                from: document.querySelector('[name="email_from"]').value,
-                to: document.querySelector('[name="email_to"]').value,
-                message_stream: document.querySelector('[name="email_message_stream"]').value
-            };
-            
-            fetch('/test-email', {
+        function showEmailProvider() {
+            const provider = document.getElementById('email_provider').value;
+            document.querySelectorAll('.provider-fields').forEach(group => {
+                group.style.display = group.dataset.provider === provider ? '' : 'none';
+            });
+        }
+        showEmailProvider();
+
+        function testNotifier(channel) {
+            // Send the current form values so unsaved changes can be tested
+            const formData = new URLSearchParams(new FormData(document.getElementById('settings-form')));
+
+            fetch('/test-notification?channel=' + encodeURIComponent(channel), {
                 method: 'POST',
-                headers: {'Content-Type': 'application/json'},
-                body: JSON.stringify(formData)
+                body: formData
             })
             .then(response => response.text())
             .then(data => alert(data));
         }
-        
-        function testNtfy() {
-            // Read current form values
-            const formData = {
-                url: document.querySelector('[name="ntfy_url"]').value
+
+        function selectedTemplate() {
+            const channel = document.getElementById('template_channel').value;
+            const severity = document.getElementById('template_severity').value;
+            return {
+                channel: channel,
+                severity: severity,
+                title: document.querySelector('[name="template_' + channel + '_' + severity + '_title"]'),
+                body: document.querySelector('[name="template_' + channel + '_' + severity + '_body"]')
             };
-            
-            fetch('/test-ntfy', {
-                method: 'POST',
-                headers: {'Content-Type': 'application/json'},
-                body: JSON.stringify(formData)
-            })
+        }
+
+        function showTemplate() {
+            const channel = document.getElementById('template_channel').value;
+            const severity = document.getElementById('template_severity').value;
+            document.querySelectorAll('.template-fields').forEach(group => {
+                group.style.display = group.dataset.channel === channel && group.dataset.severity === severity ? '' : 'none';
+            });
+            previewTemplate();
+        }
+
+        function insertDefaultTemplate() {
+            const template = selectedTemplate();
+            fetch('/templates/default?channel=' + encodeURIComponent(template.channel) + '&severity=' + encodeURIComponent(template.severity))
+            .then(response => response.json())
+            .then(data => {
+                template.title.value = data.title || '';
+                template.body.value = data.body || '';
+                previewTemplate();
+            });
+        }
+
+        function previewTemplate() {
+            const template = selectedTemplate();
+            const formData = new URLSearchParams({
+                channel: template.channel,
+                severity: template.severity,
+                title: template.title.value,
+                body: template.body.value
+            });
+
+            fetch('/templates/preview', { method: 'POST', body: formData })
+            .then(response => response.json())
+            .then(data => {
+                document.getElementById('template_preview').style.display = '';
+                document.getElementById('template_preview_title').textContent = data.error ? 'Error: ' + data.error : data.title;
+                document.getElementById('template_preview_html').style.display = data.html && !data.error ? '' : 'none';
+                document.getElementById('template_preview_html').srcdoc = data.html ? data.body : '';
+                document.getElementById('template_preview_text').style.display = data.html || data.error ? 'none' : '';
+                document.getElementById('template_preview_text').textContent = data.html ? '' : data.body;
+            });
+        }
+
+        document.querySelectorAll('.template-fields input, .template-fields textarea').forEach(field => {
+            field.addEventListener('input', previewTemplate);
+        });
+        showTemplate();
+
+        function sendDigest() {
+            fetch('/send-digest', { method: 'POST' })
             .then(response => response.text())
             .then(data => alert(data));
         }
@@ -1,40 +1,67 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type NtfySettings struct {
 	EnabledWarning  bool   `json:"enabled_warning"`
 	EnabledCritical bool   `json:"enabled_critical"`
+	EnabledChange   bool   `json:"enabled_change"` // unexpected certificate changes
+	EnabledResolved bool   `json:"enabled_resolved"` // back to normal after an alert
+	EnabledError    bool   `json:"enabled_error"`    // repeated scan failures
 	URL             string `json:"url"`
 }
 
 type EmailSettings struct {
 	EnabledWarning  bool   `json:"enabled_warning"`
 	EnabledCritical bool   `json:"enabled_critical"`
-	Provider        string `json:"provider"`
+	EnabledChange   bool   `json:"enabled_change"` // unexpected certificate changes
+	EnabledResolved bool   `json:"enabled_resolved"` // back to normal after an alert
+	EnabledError    bool   `json:"enabled_error"`    // repeated scan failures
+	Provider        string `json:"provider"` // "postmark" or "smtp"
 	ServerToken     string `json:"server_token"`
 	From            string `json:"from"`
-	To              string `json:"to"`
+	To              string `json:"to"` // comma separated for smtp
 	MessageStream   string `json:"message_stream"`
+	SMTPHost        string `json:"smtp_host,omitempty"`
+	SMTPPort        int    `json:"smtp_port,omitempty"`     // 0 picks the usual port for SMTPSecurity
+	SMTPSecurity    string `json:"smtp_security,omitempty"` // "starttls", "tls" or "none"
+	SMTPUsername    string `json:"smtp_username,omitempty"`
+	SMTPPassword    string `json:"smtp_password,omitempty"`
+}
+
+// ChatWebhookSettings configures a Slack, Teams or Discord incoming webhook
+type ChatWebhookSettings struct {
+	EnabledWarning  bool   `json:"enabled_warning"`
+	EnabledCritical bool   `json:"enabled_critical"`
+	EnabledChange   bool   `json:"enabled_change"`
+	EnabledResolved bool   `json:"enabled_resolved"`
+	EnabledError    bool   `json:"enabled_error"`
+	WebhookURL      string `json:"webhook_url"`
 }
 
 type NotificationSettings struct {
-	Ntfy  NtfySettings  `json:"ntfy"`
-	Email EmailSettings `json:"email"`
+	Ntfy    NtfySettings        `json:"ntfy"`
+	Email   EmailSettings       `json:"email"`
+	Webhook WebhookSettings     `json:"webhook"`
+	Slack   ChatWebhookSettings `json:"slack"`
+	Teams   ChatWebhookSettings `json:"teams"`
+	Discord ChatWebhookSettings `json:"discord"`
 }
 
 type DashboardSettings struct {
-	Port            int `json:"port"`
+	Port            int    `json:"port"`
+	URL             string `json:"url,omitempty"` // address users reach the dashboard on, for links in notifications
 	ColorThresholds struct {
 		Warning  int `json:"warning"`
 		Critical int `json:"critical"`
@@ -42,27 +69,33 @@ type DashboardSettings struct {
 }
 
 type Settings struct {
-	ScanIntervalHours int                  `json:"scan_interval_hours"`
-	Notifications     NotificationSettings `json:"notifications"`
-	Dashboard         DashboardSettings    `json:"dashboard"`
-}
-
-type TestEmailData struct {
-	ServerToken   string `json:"server_token"`
-	From          string `json:"from"`
-	To            string `json:"to"`
-	MessageStream string `json:"message_stream"`
-}
-
-type TestNtfyData struct {
-	URL string `json:"url"`
+	ScanIntervalHours    int                  `json:"scan_interval_hours"`
+	ScanConcurrency      int                  `json:"scan_concurrency"`       // parallel certificate checks, 0 uses the default
+	ScanHostIntervalMs   int                  `json:"scan_host_interval_ms"`  // minimum gap between connections to one host, 0 disables
+	HistoryRetentionDays int                  `json:"history_retention_days"` // how long scan history is kept, 0 uses the default
+	ScanErrorThreshold   int                  `json:"scan_error_threshold"`   // consecutive failed scans before an error alert, 0 uses the default
+	DeliveryRetryLimit   int                  `json:"delivery_retry_limit"`   // attempts at a failed notification before giving up, 0 uses the default
+	BatchNotifications   bool                 `json:"batch_notifications"`    // send one message per channel and severity for each scan
+	Notifications        NotificationSettings `json:"notifications"`
+	Reminders            ReminderSettings     `json:"reminders"`
+	Digest               DigestSettings       `json:"digest"`
+	Dashboard            DashboardSettings    `json:"dashboard"`
+	Routes               []NotificationRoute  `json:"routes,omitempty"` // per-team channels for sites with a routing key
+	QuietHours           QuietHours           `json:"quiet_hours"`
+	// Templates override message wording, by channel then severity
+	Templates map[string]map[string]MessageTemplate `json:"templates,omitempty"`
 }
 
 func initializeDefaultSettings() error {
 	LogInfo("Creating default settings file")
 	
 	defaultSettings := Settings{
-		ScanIntervalHours: 24,
+		ScanIntervalHours:    24,
+		ScanConcurrency:      defaultScanConcurrency,
+		ScanHostIntervalMs:   0,
+		HistoryRetentionDays: defaultHistoryRetentionDays,
+		ScanErrorThreshold:   defaultScanErrorThreshold,
+		DeliveryRetryLimit:   defaultDeliveryRetryLimit,
 		Notifications: NotificationSettings{
 			Ntfy: NtfySettings{
 				EnabledWarning:  false,
@@ -77,7 +110,21 @@ func initializeDefaultSettings() error {
 				From:            "",
 				To:              "",
 				MessageStream:   "ssl-monitor",
+				SMTPSecurity:    smtpSecuritySTARTTLS,
 			},
+			Webhook: WebhookSettings{
+				Method:     "POST",
+				HMACHeader: defaultWebhookHMACHeader,
+			},
+		},
+		Digest: DigestSettings{
+			Frequency: digestWeekly,
+			Weekday:   int(time.Monday),
+			Hour:      8,
+		},
+		QuietHours: QuietHours{
+			Start: "22:00",
+			End:   "07:00",
 		},
 		Dashboard: DashboardSettings{
 			Port: 8080,
@@ -230,6 +277,43 @@ func saveSettingsFromForm(r *http.Request) error {
 		}
 	}
 
+	if val := r.FormValue("scan_concurrency"); val != "" {
+		if workers := parseInt(val); workers > 0 {
+			LogDebug("Updating scan concurrency to %d", workers)
+			settings.ScanConcurrency = workers
+		}
+	}
+	if val := r.FormValue("scan_host_interval_ms"); val != "" {
+		if ms := parseInt(val); ms >= 0 {
+			LogDebug("Updating per-host scan interval to %d ms", ms)
+			settings.ScanHostIntervalMs = ms
+		}
+	}
+	if val := r.FormValue("history_retention_days"); val != "" {
+		if days := parseInt(val); days > 0 {
+			LogDebug("Updating history retention to %d days", days)
+			settings.HistoryRetentionDays = days
+		}
+	}
+
+	// Alerting and delivery settings
+	if val := r.FormValue("scan_error_threshold"); val != "" {
+		if failures := parseInt(val); failures > 0 {
+			LogDebug("Updating scan error threshold to %d failures", failures)
+			settings.ScanErrorThreshold = failures
+		}
+	}
+	if val := r.FormValue("delivery_retry_limit"); val != "" {
+		if attempts := parseInt(val); attempts > 0 {
+			LogDebug("Updating delivery retry limit to %d attempts", attempts)
+			settings.DeliveryRetryLimit = attempts
+		}
+	}
+	if _, ok := r.Form["delivery_retry_limit"]; ok {
+		// An unticked checkbox isn't posted, so it's read along with its section
+		settings.BatchNotifications = r.FormValue("batch_notifications") == "on"
+	}
+
 	// Dashboard settings
 	if val := r.FormValue("dashboard_warning"); val != "" {
 		if days := parseInt(val); days > 0 {
@@ -243,185 +327,35 @@ func saveSettingsFromForm(r *http.Request) error {
 			settings.Dashboard.ColorThresholds.Critical = days
 		}
 	}
-
-	// Email settings
-	settings.Notifications.Email.EnabledWarning = r.FormValue("email_enabled_warning") == "on"
-	settings.Notifications.Email.EnabledCritical = r.FormValue("email_enabled_critical") == "on"
-	settings.Notifications.Email.ServerToken = r.FormValue("email_server_token")
-	settings.Notifications.Email.From = r.FormValue("email_from")
-	settings.Notifications.Email.To = r.FormValue("email_to")
-	settings.Notifications.Email.MessageStream = r.FormValue("email_message_stream")
-
-	// NTFY settings
-	settings.Notifications.Ntfy.EnabledWarning = r.FormValue("ntfy_enabled_warning") == "on"
-	settings.Notifications.Ntfy.EnabledCritical = r.FormValue("ntfy_enabled_critical") == "on"
-	settings.Notifications.Ntfy.URL = r.FormValue("ntfy_url")
-
-	LogDebug("Updated email notifications: warning=%v, critical=%v", 
-		settings.Notifications.Email.EnabledWarning, settings.Notifications.Email.EnabledCritical)
-	LogDebug("Updated NTFY notifications: warning=%v, critical=%v", 
-		settings.Notifications.Ntfy.EnabledWarning, settings.Notifications.Ntfy.EnabledCritical)
-
-	return saveSettings(settings)
-}
-
-func testEmailHandler(w http.ResponseWriter, r *http.Request) {
-	var emailSettings EmailSettings
-
-	// Try to parse JSON from request body
-	if r.Header.Get("Content-Type") == "application/json" {
-		LogDebug("Testing email with form values")
-		var testData TestEmailData
-		err := json.NewDecoder(r.Body).Decode(&testData)
-		if err != nil {
-			LogError("Error parsing email test data: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, "Error parsing test data")
-			return
-		}
-
-		// Use form values for testing
-		emailSettings = EmailSettings{
-			ServerToken:   testData.ServerToken,
-			From:          testData.From,
-			To:            testData.To,
-			MessageStream: testData.MessageStream,
-		}
-	} else {
-		LogDebug("Testing email with saved settings")
-		settings, err := loadSettings()
-		if err != nil {
-			LogError("Error loading settings for email test: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprint(w, "Error loading settings")
-			return
-		}
-		emailSettings = settings.Notifications.Email
-	}
-
-	// Validate required fields
-	if emailSettings.ServerToken == "" || emailSettings.From == "" || emailSettings.To == "" {
-		LogWarning("Email test failed: incomplete settings")
-		fmt.Fprint(w, "Email settings incomplete (missing server token, from, or to address)")
-		return
-	}
-
-	LogInfo("Sending test email from %s to %s", emailSettings.From, emailSettings.To)
-
-	emailData := map[string]string{
-		"From":          emailSettings.From,
-		"To":            emailSettings.To,
-		"Subject":       "SSL Monitor Test Email",
-		"HtmlBody":      "<h2>SSL Monitor Test</h2><p>If you receive this email, your email notifications are configured correctly!</p>",
-		"MessageStream": emailSettings.MessageStream,
+	if _, ok := r.Form["dashboard_url"]; ok {
+		settings.Dashboard.URL = strings.TrimRight(strings.TrimSpace(r.FormValue("dashboard_url")), "/")
 	}
 
-	jsonData, err := json.Marshal(emailData)
-	if err != nil {
-		LogError("Error preparing email JSON: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error preparing email: %s", err.Error())
-		return
-	}
+	parseReminderForm(r, &settings)
+	parseDigestForm(r, &settings)
+	parseTemplateForm(r, &settings)
+	parseRouteForm(r, &settings)
+	parseQuietHoursForm(r, &settings)
 
-	req, err := http.NewRequest("POST", "https://api.postmarkapp.com/email", bytes.NewBuffer(jsonData))
-	if err != nil {
-		LogError("Error creating email request: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error creating request: %s", err.Error())
-		return
+	// Each notification channel reads its own fields
+	for _, notifier := range notifiers {
+		notifier.ParseForm(r, &settings)
 	}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Postmark-Server-Token", emailSettings.ServerToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		LogError("Error sending test email: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error sending email: %s", err.Error())
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		LogInfo("Test email sent successfully")
-		fmt.Fprint(w, "Test email sent successfully!")
-	} else {
-		LogWarning("Postmark returned status code %d for test email", resp.StatusCode)
-		fmt.Fprintf(w, "Postmark returned status code: %d", resp.StatusCode)
-	}
+	return saveSettings(settings)
 }
 
-func testNtfyHandler(w http.ResponseWriter, r *http.Request) {
-	var ntfyURL string
-
-	// Try to parse JSON from request body (new approach)
-	if r.Header.Get("Content-Type") == "application/json" {
-		LogDebug("Testing NTFY with form values")
-		var testData TestNtfyData
-		err := json.NewDecoder(r.Body).Decode(&testData)
-		if err != nil {
-			LogError("Error parsing NTFY test data: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, "Error parsing test data")
-			return
-		}
-		ntfyURL = testData.URL
-	} else {
-		LogDebug("Testing NTFY with saved settings")
-		// Fallback to existing settings (old approach)
-		settings, err := loadSettings()
-		if err != nil {
-			LogError("Error loading settings for NTFY test: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprint(w, "Error loading settings")
-			return
-		}
-		ntfyURL = settings.Notifications.Ntfy.URL
+// dashboardURL is the base address for links back to the dashboard
+func dashboardURL(settings Settings) string {
+	if settings.Dashboard.URL != "" {
+		return settings.Dashboard.URL
 	}
+	return fmt.Sprintf("http://localhost:%d", settings.Dashboard.Port)
+}
 
-	if ntfyURL == "" {
-		LogWarning("NTFY test failed: no URL configured")
-		fmt.Fprint(w, "NTFY URL not configured")
-		return
-	}
-
-	LogInfo("Sending test NTFY notification to %s", ntfyURL)
-
-	// Rest remains the same, but use ntfyURL variable
-	message := "SSL Monitor test notification - if you see this, NTFY is working correctly!"
-	req, err := http.NewRequest("POST", ntfyURL, strings.NewReader(message))
-	if err != nil {
-		LogError("Error creating NTFY request: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error creating request: %s", err.Error())
-		return
-	}
-
-	req.Header.Set("Title", "SSL Monitor Test")
-	req.Header.Set("Priority", "default")
-	req.Header.Set("Tags", "test,ssl-monitor")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		LogError("Error sending test NTFY: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Error sending notification: %s", err.Error())
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		LogInfo("Test NTFY notification sent successfully")
-		fmt.Fprint(w, "NTFY test notification sent successfully!")
-	} else {
-		LogWarning("NTFY returned status code %d for test notification", resp.StatusCode)
-		fmt.Fprintf(w, "NTFY returned status code: %d", resp.StatusCode)
-	}
+// siteDashboardURL links to a site's detail page
+func siteDashboardURL(settings Settings, siteURL string) string {
+	return dashboardURL(settings) + "/results/site?url=" + url.QueryEscape(siteURL)
 }
 
 func parseInt(s string) int {
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -65,6 +64,17 @@ func TestDefaultSettingsStructure(t *testing.T) {
 		t.Errorf("Expected scan interval 24, got %d", settings.ScanIntervalHours)
 	}
 
+	if settings.ScanConcurrency != defaultScanConcurrency {
+		t.Errorf("Expected scan concurrency %d, got %d", defaultScanConcurrency, settings.ScanConcurrency)
+	}
+
+	if settings.ScanHostIntervalMs != 0 {
+		t.Errorf("Expected per-host interval disabled by default, got %d", settings.ScanHostIntervalMs)
+	}
+	if settings.HistoryRetentionDays != defaultHistoryRetentionDays {
+		t.Errorf("Expected history retention %d by default, got %d", defaultHistoryRetentionDays, settings.HistoryRetentionDays)
+	}
+
 	if settings.Dashboard.Port != 8080 {
 		t.Errorf("Expected dashboard port 8080, got %d", settings.Dashboard.Port)
 	}
@@ -229,110 +239,112 @@ func TestSaveAndLoadSettings(t *testing.T) {
 	}
 }
 
-func TestTestEmailHandlerWithJSON(t *testing.T) {
-	// Create test data
-	testData := TestEmailData{
-		ServerToken:   "test-token",
-		From:          "test@example.com",
-		To:            "recipient@example.com",
-		MessageStream: "test-stream",
-	}
+func postTestNotification(t *testing.T, channel string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
 
-	jsonData, _ := json.Marshal(testData)
+	req := httptest.NewRequest("POST", "/test-notification?channel="+channel, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	testNotificationHandler(w, req)
+	return w
+}
 
-	// Create request
-	req := httptest.NewRequest("POST", "/test-email", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+func TestTestNotificationHandlerEmail(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
 
-	w := httptest.NewRecorder()
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Postmark-Server-Token") != "test-token" {
+			t.Errorf("Expected server token from the form, got %q", r.Header.Get("X-Postmark-Server-Token"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
 
-	// Call handler
-	testEmailHandler(w, req)
+	originalURL := postmarkAPIURL
+	postmarkAPIURL = server.URL
+	defer func() { postmarkAPIURL = originalURL }()
 
-	// Note: This will fail because we don't have a valid Postmark token,
-	// but we can test that it doesn't crash and processes the JSON correctly
-	if w.Code == http.StatusBadRequest {
-		t.Error("Handler should not return 400 for valid JSON")
-	}
+	w := postTestNotification(t, "email", url.Values{
+		"email_server_token":   {"test-token"},
+		"email_from":           {"test@example.com"},
+		"email_to":             {"recipient@example.com"},
+		"email_message_stream": {"test-stream"},
+	})
 
-	// The response should contain some indication it tried to send
-	body := w.Body.String()
-	if body == "Error parsing test data" {
-		t.Error("Handler failed to parse valid JSON data")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
-}
-
-func TestTestNtfyHandlerWithJSON(t *testing.T) {
-	// Create test data
-	testData := TestNtfyData{
-		URL: "https://ntfy.sh/test-topic",
+	if !strings.Contains(w.Body.String(), "sent successfully") {
+		t.Errorf("Expected success message, got %q", w.Body.String())
 	}
+	if received["To"] != "recipient@example.com" || received["MessageStream"] != "test-stream" {
+		t.Errorf("Test email not built from form values: %v", received)
+	}
+}
 
-	jsonData, _ := json.Marshal(testData)
+func TestTestNotificationHandlerNtfy(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
 
-	// Create request
-	req := httptest.NewRequest("POST", "/test-ntfy", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+	var title string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title = r.Header.Get("Title")
+	}))
+	defer server.Close()
 
-	w := httptest.NewRecorder()
+	w := postTestNotification(t, "ntfy", url.Values{"ntfy_url": {server.URL + "/test-topic"}})
 
-	// Call handler
-	testNtfyHandler(w, req)
-
-	// Note: This will likely fail because the test topic doesn't exist,
-	// but we can test that it doesn't crash and processes the JSON correctly
-	if w.Code == http.StatusBadRequest {
-		t.Error("Handler should not return 400 for valid JSON")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
-
-	// The response should contain some indication it tried to send
-	body := w.Body.String()
-	if body == "Error parsing test data" {
-		t.Error("Handler failed to parse valid JSON data")
+	if title != "SSL Monitor Test" {
+		t.Errorf("Expected test notification title, got %q", title)
 	}
 }
 
-func TestTestEmailHandlerMissingFields(t *testing.T) {
-	// Create incomplete test data
-	testData := TestEmailData{
-		ServerToken: "test-token",
-		// Missing From and To fields
-	}
-
-	jsonData, _ := json.Marshal(testData)
-
-	req := httptest.NewRequest("POST", "/test-email", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+func TestTestNotificationHandlerEmailMissingFields(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
 
-	w := httptest.NewRecorder()
-	testEmailHandler(w, req)
+	// Missing From and To fields
+	w := postTestNotification(t, "email", url.Values{"email_server_token": {"test-token"}})
 
-	body := w.Body.String()
-	if !strings.Contains(body, "incomplete") {
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "incomplete") {
 		t.Error("Handler should indicate incomplete settings")
 	}
 }
 
-func TestTestNtfyHandlerMissingURL(t *testing.T) {
-	// Create test data with empty URL
-	testData := TestNtfyData{
-		URL: "",
-	}
-
-	jsonData, _ := json.Marshal(testData)
-
-	req := httptest.NewRequest("POST", "/test-ntfy", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+func TestTestNotificationHandlerNtfyMissingURL(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
 
-	w := httptest.NewRecorder()
-	testNtfyHandler(w, req)
+	w := postTestNotification(t, "ntfy", url.Values{"ntfy_url": {""}})
 
-	body := w.Body.String()
-	if !strings.Contains(body, "not configured") {
+	if !strings.Contains(w.Body.String(), "not configured") {
 		t.Error("Handler should indicate URL not configured")
 	}
 }
 
+func TestTestNotificationHandlerUnknownChannel(t *testing.T) {
+	w := postTestNotification(t, "pager", url.Values{})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown channel, got %d", w.Code)
+	}
+}
+
 func TestSaveSettingsFromForm(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
@@ -352,6 +364,10 @@ func TestSaveSettingsFromForm(t *testing.T) {
 	// Create form data
 	formData := url.Values{}
 	formData.Set("scan_interval_hours", "48")
+	formData.Set("scan_concurrency", "20")
+	formData.Set("scan_host_interval_ms", "250")
+	formData.Set("history_retention_days", "365")
+	formData.Set("scan_error_threshold", "5")
 	formData.Set("dashboard_warning", "30")
 	formData.Set("dashboard_critical", "5")
 	formData.Set("email_enabled_warning", "on")
@@ -383,6 +399,20 @@ func TestSaveSettingsFromForm(t *testing.T) {
 		t.Errorf("Expected scan interval 48, got %d", settings.ScanIntervalHours)
 	}
 
+	if settings.ScanConcurrency != 20 {
+		t.Errorf("Expected scan concurrency 20, got %d", settings.ScanConcurrency)
+	}
+
+	if settings.ScanHostIntervalMs != 250 {
+		t.Errorf("Expected per-host interval 250, got %d", settings.ScanHostIntervalMs)
+	}
+	if settings.HistoryRetentionDays != 365 {
+		t.Errorf("Expected history retention 365, got %d", settings.HistoryRetentionDays)
+	}
+	if settings.ScanErrorThreshold != 5 {
+		t.Errorf("Expected scan error threshold 5, got %d", settings.ScanErrorThreshold)
+	}
+
 	if settings.Dashboard.ColorThresholds.Warning != 30 {
 		t.Errorf("Expected warning threshold 30, got %d", settings.Dashboard.ColorThresholds.Warning)
 	}
@@ -470,3 +500,24 @@ func TestSettingsJSONSerialization(t *testing.T) {
 		t.Errorf("Dashboard port mismatch after JSON round-trip")
 	}
 }
+
+func TestSettingsHandlerGET(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
+
+	req := httptest.NewRequest("GET", "/settings", nil)
+	w := httptest.NewRecorder()
+	settingsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{"Email Notifications", "NTFY Notifications", "Webhook Notifications", "Slack Notifications", "Microsoft Teams Notifications", "Discord Notifications", `name="webhook_body_template"`, "Message Templates", `name="template_email_warning_body"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected settings page to contain %q", expected)
+		}
+	}
+}
@@ -123,7 +123,7 @@ const sitesTemplate = `
             font-weight: bold;
             color: var(--text-color);
         }
-        .form-group input {
+        .form-group input, .form-group select {
             width: 100%;
             padding: 8px 12px;
             border: 1px solid var(--input-border);
@@ -233,7 +233,7 @@ const sitesTemplate = `
             gap: 10px;
             align-items: center;
         }
-        .edit-form input {
+        .edit-form input, .edit-form select {
             padding: 4px 8px;
             border: 1px solid var(--input-border);
             border-radius: 4px;
@@ -267,6 +267,7 @@ const sitesTemplate = `
     <div class="nav">
         <a href="/results">Results</a>
         <a href="/sites" class="active">Sites</a>
+        <a href="/notifications">Notifications</a>
         <a href="/settings">Settings</a>
     </div>
 
@@ -286,7 +287,28 @@ const sitesTemplate = `
                 </div>
                 <div class="form-group">
                     <label for="url">URL:</label>
-                    <input type="text" id="url" name="url" placeholder="e.g., google.com" required>
+                    <input type="text" id="url" name="url" placeholder="e.g., google.com or mail.example.com:8443" required>
+                </div>
+                <div class="form-group">
+                    <label for="protocol">Protocol:</label>
+                    <select id="protocol" name="protocol">
+                        {{range .Protocols}}
+                        <option value="{{.Name}}">{{.Label}} ({{.DefaultPort}})</option>
+                        {{end}}
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="sni">SNI (optional):</label>
+                    <input type="text" id="sni" name="sni" placeholder="defaults to the URL host">
+                </div>
+                <div class="form-group">
+                    <label for="route">Route (optional):</label>
+                    <input type="text" id="route" name="route" list="routes" placeholder="global channels">
+                    <datalist id="routes">
+                        {{range .Routes}}
+                        <option value="{{.}}">
+                        {{end}}
+                    </datalist>
                 </div>
                 <div>
                     <button type="submit" class="btn btn-primary">Add Site</button>
@@ -296,7 +318,7 @@ const sitesTemplate = `
     </div>
 
     <div class="sites-list">
-        {{if eq (len .) 0}}
+        {{if eq (len .Sites) 0}}
             <div class="no-sites">
                 <h3>No sites configured</h3>
                 <p>Add your first site above to start monitoring SSL certificates.</p>
@@ -312,11 +334,36 @@ const sitesTemplate = `
                     </tr>
                 </thead>
                 <tbody>
-                    {{range $index, $site := .}}
-                    <tr id="row-{{$index}}">
+                    {{range $index, $site := .Sites}}
+                    <tr id="row-{{$index}}" data-sni="{{.SNI}}" data-protocol="{{.Protocol}}" data-route="{{.Route}}">
                         <td>
                             <div class="site-name" id="name-{{$index}}">{{.Name}}</div>
                             <div class="site-url" id="url-{{$index}}">{{.URL}}</div>
+                            {{if .Protocol}}<div class="site-url">Protocol: {{.Protocol}}</div>{{end}}
+                            {{if .SNI}}<div class="site-url">SNI: {{.SNI}}</div>{{end}}
+                            {{if .Route}}<div class="site-url">Route: {{.Route}}</div>{{end}}
+                            {{range .UpcomingMaintenance}}
+                            <div class="site-url">Maintenance: {{.Start.Format "2006-01-02 15:04"}} to {{.End.Format "2006-01-02 15:04"}}{{if .Note}} ({{.Note}}){{end}}</div>
+                            {{end}}
+                            <details class="maintenance">
+                                <summary class="site-url">Schedule maintenance</summary>
+                                <form method="post">
+                                    <input type="hidden" name="action" value="maintenance">
+                                    <input type="hidden" name="index" value="{{$index}}">
+                                    <input type="datetime-local" name="start" required>
+                                    <input type="datetime-local" name="end" required>
+                                    <input type="text" name="note" placeholder="Note (optional)">
+                                    <button type="submit" class="btn btn-secondary">Schedule</button>
+                                </form>
+                                {{if .Maintenance}}
+                                <form method="post" class="inline-form">
+                                    <input type="hidden" name="action" value="clear_maintenance">
+                                    <input type="hidden" name="index" value="{{$index}}">
+                                    <button type="submit" class="btn btn-secondary">Clear maintenance</button>
+                                </form>
+                                {{end}}
+                                <div class="site-url">Notifications are held during the window and sent when it ends</div>
+                            </details>
                         </td>
                         <td>
                             {{if .Enabled}}
@@ -364,6 +411,8 @@ const sitesTemplate = `
             
             const currentName = nameEl.textContent;
             const currentUrl = urlEl.textContent;
+            const currentSni = row.dataset.sni;
+            const currentRoute = row.dataset.route;
             
             row.classList.add('edit-row');
             
@@ -371,7 +420,16 @@ const sitesTemplate = `
                 '<div class="edit-form">' +
                 '<input type="text" id="edit-name-' + index + '" value="' + currentName + '" placeholder="Site name">' +
                 '<input type="text" id="edit-url-' + index + '" value="' + currentUrl + '" placeholder="URL">' +
+                '<input type="text" id="edit-sni-' + index + '" value="' + currentSni + '" placeholder="SNI (optional)">' +
+                '<input type="text" id="edit-route-' + index + '" value="' + currentRoute + '" placeholder="Route (optional)" list="routes">' +
                 '</div>';
+
+            // Reuse the add form's protocol options for the edit row
+            const protocolSelect = document.getElementById('protocol').cloneNode(true);
+            protocolSelect.id = 'edit-protocol-' + index;
+            protocolSelect.removeAttribute('name');
+            protocolSelect.value = row.dataset.protocol;
+            row.cells[0].querySelector('.edit-form').appendChild(protocolSelect);
             
             row.cells[3].innerHTML = 
                 '<button type="button" class="btn btn-primary" onclick="saveEdit(' + index + ')">Save</button> ' +
@@ -381,6 +439,9 @@ const sitesTemplate = `
         function saveEdit(index) {
             const nameInput = document.getElementById('edit-name-' + index);
             const urlInput = document.getElementById('edit-url-' + index);
+            const sniInput = document.getElementById('edit-sni-' + index);
+            const routeInput = document.getElementById('edit-route-' + index);
+            const protocolSelect = document.getElementById('edit-protocol-' + index);
             
             if (!nameInput.value.trim() || !urlInput.value.trim()) {
                 alert('Please fill in both name and URL');
@@ -394,7 +455,10 @@ const sitesTemplate = `
                 '<input type="hidden" name="action" value="edit">' +
                 '<input type="hidden" name="index" value="' + index + '">' +
                 '<input type="hidden" name="name" value="' + nameInput.value + '">' +
-                '<input type="hidden" name="url" value="' + urlInput.value + '">';
+                '<input type="hidden" name="url" value="' + urlInput.value + '">' +
+                '<input type="hidden" name="sni" value="' + sniInput.value + '">' +
+                '<input type="hidden" name="route" value="' + routeInput.value + '">' +
+                '<input type="hidden" name="protocol" value="' + protocolSelect.value + '">';
             
             document.body.appendChild(form);
             form.submit();
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,6 +14,14 @@ import (
 	"time"
 )
 
+var errInvalidSite = errors.New("invalid site")
+
+type SitesPageData struct {
+	Sites     []Site
+	Protocols []SiteProtocol
+	Routes    []string // configured notification routes, suggested for the route field
+}
+
 // Add this function to sites.go
 
 func initializeDefaultSites() error {
@@ -56,9 +66,28 @@ func loadSites() ([]Site, error) {
 		return nil, err
 	}
 
+	migrateSiteAddresses(sitesList.Sites)
 	return sitesList.Sites, nil
 }
 
+// migrateSiteAddresses fills in Host and Port for sites saved before they were parsed from the URL
+func migrateSiteAddresses(sites []Site) {
+	for i := range sites {
+		if sites[i].Host != "" {
+			continue
+		}
+
+		_, host, port, err := parseSiteURL(sites[i].URL, defaultPortForProtocol(sites[i].Protocol))
+		if err != nil {
+			LogWarning("Could not parse address for site %s (%s): %v", sites[i].Name, sites[i].URL, err)
+			continue
+		}
+
+		sites[i].Host = host
+		sites[i].Port = port
+	}
+}
+
 func saveSites(sites []Site) error {
 	sitesList := SitesList{
 		Sites:        sites,
@@ -80,13 +109,13 @@ func sitesHandler(w http.ResponseWriter, r *http.Request) {
 		case "add":
 			err := addSite(r)
 			if err != nil {
-				http.Error(w, "Error adding site: "+err.Error(), http.StatusInternalServerError)
+				http.Error(w, "Error adding site: "+err.Error(), siteErrorStatus(err))
 				return
 			}
 		case "edit":
 			err := editSite(r)
 			if err != nil {
-				http.Error(w, "Error editing site: "+err.Error(), http.StatusInternalServerError)
+				http.Error(w, "Error editing site: "+err.Error(), siteErrorStatus(err))
 				return
 			}
 		case "delete":
@@ -101,6 +130,18 @@ func sitesHandler(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Error toggling site: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
+		case "maintenance":
+			err := scheduleMaintenance(r)
+			if err != nil {
+				http.Error(w, "Error scheduling maintenance: "+err.Error(), siteErrorStatus(err))
+				return
+			}
+		case "clear_maintenance":
+			err := clearMaintenance(r)
+			if err != nil {
+				http.Error(w, "Error clearing maintenance: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
 		}
 
 		// Redirect to prevent re-submission on refresh
@@ -114,25 +155,154 @@ func sitesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pageData := SitesPageData{
+		Sites:     sites,
+		Protocols: siteProtocols,
+	}
+	if settings, err := loadSettings(); err != nil {
+		LogWarning("Error loading settings for route names: %v", err)
+	} else {
+		pageData.Routes = settings.RouteNames()
+	}
+
 	parsedTemplate := template.Must(template.New("sites").Parse(sitesTemplate))
-	parsedTemplate.Execute(w, sites)
+	parsedTemplate.Execute(w, pageData)
 }
 
-// Case-insensitive protocol removal
-func stripProtocol(url string) string {
-	// Convert to lowercase for comparison
-	lowerURL := strings.ToLower(url)
-	
-	if strings.HasPrefix(lowerURL, "https://") {
-		return url[8:] // Remove "https://" (8 characters)
+func siteErrorStatus(err error) int {
+	if errors.Is(err, errInvalidSite) {
+		return http.StatusBadRequest
 	}
-	if strings.HasPrefix(lowerURL, "http://") {
-		return url[7:] // Remove "http://" (7 characters)
+	return http.StatusInternalServerError
+}
+
+// Case-insensitive scheme removal, e.g. "https://" or "HTTP://"
+func stripProtocol(url string) string {
+	if index := strings.Index(url, "://"); index >= 0 {
+		return url[index+3:]
 	}
-	
+
 	return url
 }
 
+// parseSiteURL accepts a bare host, host:port, an IPv6 literal (with or without
+// brackets) or a full URL with a path, and returns the address shown to users
+// along with the host and port to connect to. defaultPort is used when the
+// input doesn't name a port.
+func parseSiteURL(raw string, defaultPort int) (string, string, int, error) {
+	address := stripProtocol(strings.TrimSpace(raw))
+
+	// Drop any path, query or fragment
+	if index := strings.IndexAny(address, "/?#"); index >= 0 {
+		address = address[:index]
+	}
+	// Drop any user info
+	if index := strings.LastIndex(address, "@"); index >= 0 {
+		address = address[index+1:]
+	}
+
+	if address == "" {
+		return "", "", 0, fmt.Errorf("%w: no host in %q", errInvalidSite, raw)
+	}
+
+	host := address
+	port := defaultPort
+
+	switch {
+	case strings.HasPrefix(address, "["):
+		end := strings.Index(address, "]")
+		if end < 0 {
+			return "", "", 0, fmt.Errorf("%w: missing ']' in %q", errInvalidSite, raw)
+		}
+		host = address[1:end]
+		rest := address[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", "", 0, fmt.Errorf("%w: unexpected %q after IPv6 address", errInvalidSite, rest)
+			}
+			parsedPort, err := parsePort(rest[1:])
+			if err != nil {
+				return "", "", 0, err
+			}
+			port = parsedPort
+		}
+		if net.ParseIP(host) == nil {
+			return "", "", 0, fmt.Errorf("%w: %q is not an IP address", errInvalidSite, host)
+		}
+	case strings.Count(address, ":") > 1:
+		// Bare IPv6 literal, which can't carry a port without brackets
+		if net.ParseIP(address) == nil {
+			return "", "", 0, fmt.Errorf("%w: %q is not a valid IPv6 address", errInvalidSite, address)
+		}
+	case strings.Contains(address, ":"):
+		splitHost, splitPort, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", "", 0, fmt.Errorf("%w: %v", errInvalidSite, err)
+		}
+		parsedPort, err := parsePort(splitPort)
+		if err != nil {
+			return "", "", 0, err
+		}
+		host = splitHost
+		port = parsedPort
+	}
+
+	if net.ParseIP(host) == nil && !isValidHostname(host) {
+		return "", "", 0, fmt.Errorf("%w: %q is not a valid hostname", errInvalidSite, host)
+	}
+
+	return address, strings.ToLower(host), port, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%w: port %q must be between 1 and 65535", errInvalidSite, value)
+	}
+	return port, nil
+}
+
+func isValidHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			isAlphaNum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+			if !isAlphaNum && c != '-' && c != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func parseProtocol(value string) (string, error) {
+	protocol := strings.ToLower(strings.TrimSpace(value))
+	if protocol == "tls" {
+		protocol = ""
+	}
+	if protocol != "" {
+		if _, ok := starttlsDefaultPorts[protocol]; !ok {
+			return "", fmt.Errorf("%w: unsupported protocol %q", errInvalidSite, value)
+		}
+	}
+	return protocol, nil
+}
+
+func parseSNI(value string) (string, error) {
+	sni := strings.TrimSpace(value)
+	if sni != "" && !isValidHostname(sni) {
+		return "", fmt.Errorf("%w: %q is not a valid SNI hostname", errInvalidSite, sni)
+	}
+	return strings.ToLower(sni), nil
+}
+
 func addSite(r *http.Request) error {
 	err := r.ParseForm()
 	if err != nil {
@@ -146,7 +316,20 @@ func addSite(r *http.Request) error {
 		return nil // Ignore empty submissions
 	}
 
-	url = stripProtocol(url)
+	protocol, err := parseProtocol(r.FormValue("protocol"))
+	if err != nil {
+		return err
+	}
+
+	url, host, port, err := parseSiteURL(url, defaultPortForProtocol(protocol))
+	if err != nil {
+		return err
+	}
+
+	sni, err := parseSNI(r.FormValue("sni"))
+	if err != nil {
+		return err
+	}
 
 	sites, err := loadSites()
 	if err != nil {
@@ -154,10 +337,15 @@ func addSite(r *http.Request) error {
 	}
 
 	newSite := Site{
-		Name:    name,
-		URL:     url,
-		Enabled: true,
-		Added:   time.Now(),
+		Name:     name,
+		URL:      url,
+		Host:     host,
+		Port:     port,
+		SNI:      sni,
+		Protocol: protocol,
+		Route:    parseRouteKey(r.FormValue("route")),
+		Enabled:  true,
+		Added:    time.Now(),
 	}
 
 	sites = append(sites, newSite)
@@ -183,7 +371,20 @@ func editSite(r *http.Request) error {
 		return nil // Ignore empty submissions
 	}
 
-	url = stripProtocol(url)
+	protocol, err := parseProtocol(r.FormValue("protocol"))
+	if err != nil {
+		return err
+	}
+
+	url, host, port, err := parseSiteURL(url, defaultPortForProtocol(protocol))
+	if err != nil {
+		return err
+	}
+
+	sni, err := parseSNI(r.FormValue("sni"))
+	if err != nil {
+		return err
+	}
 
 	sites, err := loadSites()
 	if err != nil {
@@ -196,6 +397,11 @@ func editSite(r *http.Request) error {
 
 	sites[index].Name = name
 	sites[index].URL = url
+	sites[index].Host = host
+	sites[index].Port = port
+	sites[index].SNI = sni
+	sites[index].Protocol = protocol
+	sites[index].Route = parseRouteKey(r.FormValue("route"))
 
 	return saveSites(sites)
 }
@@ -250,3 +456,81 @@ func toggleSite(r *http.Request) error {
 	sites[index].Enabled = !sites[index].Enabled
 	return saveSites(sites)
 }
+
+// UpcomingMaintenance lists the site's maintenance windows that haven't ended
+func (s Site) UpcomingMaintenance() []MaintenanceWindow {
+	now := time.Now()
+	var windows []MaintenanceWindow
+	for _, window := range s.Maintenance {
+		if window.End.After(now) {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// scheduleMaintenance adds a maintenance window to a site, dropping any that
+// have already ended
+func scheduleMaintenance(r *http.Request) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	index, err := strconv.Atoi(r.FormValue("index"))
+	if err != nil {
+		return err
+	}
+
+	start, err := time.ParseInLocation(datetimeLocalFormat, r.FormValue("start"), time.Local)
+	if err != nil {
+		return fmt.Errorf("%w: invalid maintenance start", errInvalidSite)
+	}
+	end, err := time.ParseInLocation(datetimeLocalFormat, r.FormValue("end"), time.Local)
+	if err != nil {
+		return fmt.Errorf("%w: invalid maintenance end", errInvalidSite)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("%w: maintenance must end after it starts", errInvalidSite)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(sites) {
+		return nil // Invalid index
+	}
+
+	windows := append(sites[index].UpcomingMaintenance(), MaintenanceWindow{Start: start, End: end, Note: strings.TrimSpace(r.FormValue("note"))})
+	sites[index].Maintenance = windows
+
+	LogInfo("Scheduled maintenance for %s from %s to %s", sites[index].URL, start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	return saveSites(sites)
+}
+
+// clearMaintenance removes every maintenance window from a site
+func clearMaintenance(r *http.Request) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	index, err := strconv.Atoi(r.FormValue("index"))
+	if err != nil {
+		return err
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(sites) {
+		return nil // Invalid index
+	}
+
+	sites[index].Maintenance = nil
+	return saveSites(sites)
+}
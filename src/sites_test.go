@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -773,3 +774,277 @@ func TestSitesWorkflow(t *testing.T) {
 		t.Errorf("Expected second site name 'GitHub', got %q", finalSites[1].Name)
 	}
 }
+
+func TestParseSiteURL(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedDisplay string
+		expectedHost    string
+		expectedPort    int
+	}{
+		{"example.com", "example.com", "example.com", 443},
+		{"https://example.com/path?q=1", "example.com", "example.com", 443},
+		{"example.com:8443", "example.com:8443", "example.com", 8443},
+		{"https://Example.COM:8443/login", "Example.COM:8443", "example.com", 8443},
+		{"[2001:db8::1]:443", "[2001:db8::1]:443", "2001:db8::1", 443},
+		{"[2001:db8::1]", "[2001:db8::1]", "2001:db8::1", 443},
+		{"2001:db8::1", "2001:db8::1", "2001:db8::1", 443},
+		{"https://[2001:db8::1]:8443/", "[2001:db8::1]:8443", "2001:db8::1", 8443},
+		{"192.0.2.10:993", "192.0.2.10:993", "192.0.2.10", 993},
+		{"https://user@example.com", "example.com", "example.com", 443},
+	}
+
+	for _, test := range tests {
+		display, host, port, err := parseSiteURL(test.input, 443)
+		if err != nil {
+			t.Errorf("parseSiteURL(%q) returned error: %v", test.input, err)
+			continue
+		}
+		if display != test.expectedDisplay {
+			t.Errorf("parseSiteURL(%q) display = %q, expected %q", test.input, display, test.expectedDisplay)
+		}
+		if host != test.expectedHost {
+			t.Errorf("parseSiteURL(%q) host = %q, expected %q", test.input, host, test.expectedHost)
+		}
+		if port != test.expectedPort {
+			t.Errorf("parseSiteURL(%q) port = %d, expected %d", test.input, port, test.expectedPort)
+		}
+	}
+}
+
+func TestParseSiteURLInvalid(t *testing.T) {
+	invalid := []string{
+		"https://",
+		"example.com:0",
+		"example.com:99999",
+		"example.com:https",
+		"[2001:db8::1",
+		"[2001:db8::1]8443",
+		"[not-an-ip]:443",
+		"2001:db8::zz",
+		"exa mple.com",
+		"-example.com",
+	}
+
+	for _, input := range invalid {
+		_, _, _, err := parseSiteURL(input, 443)
+		if err == nil {
+			t.Errorf("parseSiteURL(%q) should return an error", input)
+		}
+	}
+}
+
+func TestAddSiteWithPortAndSNI(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	formData := url.Values{}
+	formData.Set("name", "Internal")
+	formData.Set("url", "https://10.0.0.5:8443/status")
+	formData.Set("sni", "internal.example.com")
+
+	req := httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := addSite(req)
+	if err != nil {
+		t.Fatalf("addSite() failed: %v", err)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("Failed to load sites: %v", err)
+	}
+
+	if len(sites) != 1 {
+		t.Fatalf("Expected 1 site, got %d", len(sites))
+	}
+
+	site := sites[0]
+	if site.URL != "10.0.0.5:8443" {
+		t.Errorf("Expected URL '10.0.0.5:8443', got %q", site.URL)
+	}
+	if site.Host != "10.0.0.5" || site.Port != 8443 {
+		t.Errorf("Expected host 10.0.0.5 port 8443, got %q port %d", site.Host, site.Port)
+	}
+	if site.SNI != "internal.example.com" {
+		t.Errorf("Expected SNI 'internal.example.com', got %q", site.SNI)
+	}
+}
+
+func TestSitesHandlerPOSTAddInvalidURL(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	formData := url.Values{}
+	formData.Set("action", "add")
+	formData.Set("name", "Broken")
+	formData.Set("url", "example.com:notaport")
+
+	req := httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	sitesHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid URL, got %d", w.Code)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("Failed to load sites: %v", err)
+	}
+	if len(sites) != 0 {
+		t.Errorf("Invalid URL should not create a site, got %d sites", len(sites))
+	}
+}
+
+func TestLoadSitesMigratesAddress(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	// sites.json written before Host and Port existed
+	legacyJSON := `{
+  "sites": [
+    {"name": "Google", "url": "google.com", "enabled": true, "added": "2025-06-06T10:00:00Z"},
+    {"name": "Alt Port", "url": "example.com:8443", "enabled": true, "added": "2025-06-06T10:00:00Z"}
+  ],
+  "last_modified": "2025-06-06T15:30:00Z"
+}`
+	sitesFilePath := filepath.Join(dataDirPath, "sites.json")
+	err := os.WriteFile(sitesFilePath, []byte(legacyJSON), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write legacy sites file: %v", err)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("loadSites() failed: %v", err)
+	}
+
+	if sites[0].Host != "google.com" || sites[0].Port != 443 {
+		t.Errorf("Expected google.com:443, got %s:%d", sites[0].Host, sites[0].Port)
+	}
+	if sites[1].Host != "example.com" || sites[1].Port != 8443 {
+		t.Errorf("Expected example.com:8443, got %s:%d", sites[1].Host, sites[1].Port)
+	}
+	if sites[0].URL != "google.com" {
+		t.Errorf("Migration should not change the URL, got %q", sites[0].URL)
+	}
+}
+
+func TestAddSiteWithProtocol(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	formData := url.Values{}
+	formData.Set("name", "Mail")
+	formData.Set("url", "mail.example.com")
+	formData.Set("protocol", "submission")
+
+	req := httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := addSite(req)
+	if err != nil {
+		t.Fatalf("addSite() failed: %v", err)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("Failed to load sites: %v", err)
+	}
+
+	if len(sites) != 1 {
+		t.Fatalf("Expected 1 site, got %d", len(sites))
+	}
+	if sites[0].Protocol != "submission" {
+		t.Errorf("Expected protocol 'submission', got %q", sites[0].Protocol)
+	}
+	if sites[0].Port != 587 {
+		t.Errorf("Expected submission default port 587, got %d", sites[0].Port)
+	}
+
+	// Unsupported protocols are rejected
+	formData.Set("protocol", "gopher")
+	req = httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err = addSite(req)
+	if err == nil {
+		t.Error("addSite() should reject an unsupported protocol")
+	}
+}
+
+func TestAddSiteWithRoute(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	formData := url.Values{}
+	formData.Set("name", "Campaign")
+	formData.Set("url", "campaign.example.com")
+	formData.Set("route", " Marketing ")
+
+	req := httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := addSite(req)
+	if err != nil {
+		t.Fatalf("addSite() failed: %v", err)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("Failed to load sites: %v", err)
+	}
+	if len(sites) != 1 || sites[0].Route != "marketing" {
+		t.Errorf("Expected the normalised route 'marketing', got %+v", sites)
+	}
+}
+
+func TestScheduleMaintenance(t *testing.T) {
+	cleanup := setupSitesTestDir(t)
+	defer cleanup()
+
+	err := saveSites([]Site{{
+		Name:        "Shop",
+		URL:         "shop.example.com",
+		Enabled:     true,
+		Maintenance: []MaintenanceWindow{{Start: time.Now().Add(-48 * time.Hour), End: time.Now().Add(-24 * time.Hour)}},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to save sites: %v", err)
+	}
+
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	formData := url.Values{}
+	formData.Set("action", "maintenance")
+	formData.Set("index", "0")
+	formData.Set("start", start.Format(datetimeLocalFormat))
+	formData.Set("end", start.Add(2*time.Hour).Format(datetimeLocalFormat))
+	formData.Set("note", "Load balancer migration")
+
+	req := httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := scheduleMaintenance(req); err != nil {
+		t.Fatalf("scheduleMaintenance() failed: %v", err)
+	}
+
+	sites, err := loadSites()
+	if err != nil {
+		t.Fatalf("Failed to load sites: %v", err)
+	}
+	windows := sites[0].Maintenance
+	if len(windows) != 1 || !windows[0].Start.Equal(start) || windows[0].Note != "Load balancer migration" {
+		t.Errorf("Expected only the new window, got %+v", windows)
+	}
+
+	// A window that ends before it starts is rejected
+	formData.Set("end", start.Add(-time.Hour).Format(datetimeLocalFormat))
+	req = httptest.NewRequest("POST", "/sites", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := scheduleMaintenance(req); !errors.Is(err, errInvalidSite) {
+		t.Errorf("Expected errInvalidSite, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP connection security
+const (
+	smtpSecuritySTARTTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	smtpSecurityTLS      = "tls"      // implicit TLS from the start, usually port 465
+	smtpSecurityNone     = "none"     // no encryption, only sensible for a local relay
+)
+
+const smtpTimeout = 30 * time.Second
+
+// smtpAccepted is the reply to a message's data, the only one net/smtp
+// treats as delivered
+const smtpAccepted = 250
+
+// smtpRootCAs verifies the relay's certificate, nil uses the system roots. Tests swap it.
+var smtpRootCAs *x509.CertPool
+
+func smtpPort(emailSettings EmailSettings) int {
+	if emailSettings.SMTPPort > 0 {
+		return emailSettings.SMTPPort
+	}
+	switch emailSettings.SMTPSecurity {
+	case smtpSecurityTLS:
+		return 465
+	case smtpSecurityNone:
+		return 25
+	default:
+		return 587
+	}
+}
+
+// splitAddresses turns a comma separated To field into individual addresses
+func splitAddresses(list string) ([]string, error) {
+	parsed, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, len(parsed))
+	for i, address := range parsed {
+		addresses[i] = address.Address
+	}
+	return addresses, nil
+}
+
+var (
+	htmlBlockPattern = regexp.MustCompile(`(?i)</p>|</h[1-6]>|</?ul>|</?ol>|</table>`)
+	htmlLinePattern  = regexp.MustCompile(`(?i)<br\s*/?>|</li>|</tr>|</div>`)
+	htmlItemPattern  = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	blankLines       = regexp.MustCompile(`\n{3,}`)
+)
+
+// plainTextFromHTML is a rough text rendering of the simple HTML our emails use,
+// for the text/plain part
+func plainTextFromHTML(htmlBody string) string {
+	// Line breaks in the source don't matter in HTML, only the tags do
+	text := strings.Join(strings.Fields(htmlBody), " ")
+	text = htmlBlockPattern.ReplaceAllString(text, "\n\n")
+	text = htmlLinePattern.ReplaceAllString(text, "\n")
+	text = htmlItemPattern.ReplaceAllString(text, "- ")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
+
+// newMessageID makes a unique Message-ID in the sender's domain. from may
+// include a display name.
+func newMessageID(from string) string {
+	random := make([]byte, 12)
+	rand.Read(random)
+
+	domain := "ssl-monitor"
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s.%s@%s>", strconv.FormatInt(time.Now().UnixNano(), 36), hex.EncodeToString(random), domain)
+}
+
+func writeQuotedPrintablePart(writer *multipart.Writer, contentType string, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	encoder := quotedprintable.NewWriter(part)
+	if _, err := encoder.Write([]byte(body)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// buildMultipartEmail renders a multipart/alternative message with plain text
+// and HTML versions of the body. inReplyTo, if set, threads it under that
+// earlier Message-ID.
+func buildMultipartEmail(from string, to string, subject string, messageID string, inReplyTo string, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	err := writeQuotedPrintablePart(writer, "text/plain", plainTextFromHTML(htmlBody))
+	if err != nil {
+		return nil, err
+	}
+	err = writeQuotedPrintablePart(writer, "text/html", htmlBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: %s\r\n", messageID)
+	if inReplyTo != "" {
+		fmt.Fprintf(&message, "In-Reply-To: %s\r\n", inReplyTo)
+		fmt.Fprintf(&message, "References: %s\r\n", inReplyTo)
+	}
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// dialSMTP connects to the relay, upgrading or wrapping the connection in TLS
+// as configured
+func dialSMTP(emailSettings EmailSettings) (*smtp.Client, error) {
+	host := emailSettings.SMTPHost
+	address := net.JoinHostPort(host, strconv.Itoa(smtpPort(emailSettings)))
+	tlsConfig := &tls.Config{ServerName: host, RootCAs: smtpRootCAs}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if emailSettings.SMTPSecurity == smtpSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if emailSettings.SMTPSecurity == smtpSecuritySTARTTLS || emailSettings.SMTPSecurity == "" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("%s does not advertise STARTTLS", address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// sendSMTPEmail delivers one message through the configured relay and returns
+// the relay's reply code once it accepted the message
+func sendSMTPEmail(emailSettings EmailSettings, subject string, htmlBody string, messageID string, inReplyTo string) (int, error) {
+	recipients, err := splitAddresses(emailSettings.To)
+	if err != nil {
+		return 0, fmt.Errorf("invalid To address: %w", err)
+	}
+	sender, err := mail.ParseAddress(emailSettings.From)
+	if err != nil {
+		return 0, fmt.Errorf("invalid From address: %w", err)
+	}
+
+	message, err := buildMultipartEmail(emailSettings.From, emailSettings.To, subject, messageID, inReplyTo, htmlBody)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := dialSMTP(emailSettings)
+	if err != nil {
+		LogError("SMTP connection error: %v", err)
+		return 0, err
+	}
+	defer client.Close()
+
+	if emailSettings.SMTPUsername != "" {
+		auth := smtp.PlainAuth("", emailSettings.SMTPUsername, emailSettings.SMTPPassword, emailSettings.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			return 0, fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(sender.Address); err != nil {
+		return 0, err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return 0, fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	LogDebug("SMTP message %s accepted by %s", messageID, emailSettings.SMTPHost)
+	if err := client.Quit(); err != nil {
+		return 0, err
+	}
+	return smtpAccepted, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal relay that records the envelope and message
+type fakeSMTPServer struct {
+	port int
+
+	mu         sync.Mutex
+	commands   []string
+	auth       string
+	recipients []string
+	data       string
+	usedTLS    bool
+}
+
+func (s *fakeSMTPServer) record(command string) {
+	s.mu.Lock()
+	s.commands = append(s.commands, command)
+	s.mu.Unlock()
+}
+
+// startFakeSMTPServer serves one session. security decides whether the
+// connection starts in TLS ("tls") or offers STARTTLS ("starttls").
+func startFakeSMTPServer(t *testing.T, security string) *fakeSMTPServer {
+	t.Helper()
+
+	certificate, cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	originalRoots := smtpRootCAs
+	smtpRootCAs = pool
+	t.Cleanup(func() { smtpRootCAs = originalRoots })
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if security == smtpSecurityTLS {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeSMTPServer{port: listener.Addr().(*net.TCPAddr).Port, usedTLS: security == smtpSecurityTLS}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		// conn is replaced after STARTTLS, so close whichever is current
+		defer func() { conn.Close() }()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			server.record(line)
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch verb {
+			case "EHLO":
+				extensions := "250-mail.example.com\r\n250-AUTH PLAIN\r\n"
+				if security == smtpSecuritySTARTTLS && !server.usedTLS {
+					extensions += "250-STARTTLS\r\n"
+				}
+				io.WriteString(conn, extensions+"250 8BITMIME\r\n")
+			case "STARTTLS":
+				io.WriteString(conn, "220 Ready to start TLS\r\n")
+				tlsConn := tls.Server(conn, tlsConfig)
+				if tlsConn.Handshake() != nil {
+					return
+				}
+				conn = tlsConn
+				reader = bufio.NewReader(conn)
+				server.mu.Lock()
+				server.usedTLS = true
+				server.mu.Unlock()
+			case "AUTH":
+				decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+				server.mu.Lock()
+				server.auth = string(decoded)
+				server.mu.Unlock()
+				io.WriteString(conn, "235 Authentication successful\r\n")
+			case "MAIL":
+				io.WriteString(conn, "250 OK\r\n")
+			case "RCPT":
+				server.mu.Lock()
+				server.recipients = append(server.recipients, line)
+				server.mu.Unlock()
+				io.WriteString(conn, "250 OK\r\n")
+			case "DATA":
+				io.WriteString(conn, "354 End data with <CR><LF>.<CR><LF>\r\n")
+				data, err := readUntil(reader, "\r\n.\r\n")
+				if err != nil {
+					return
+				}
+				server.mu.Lock()
+				server.data = data
+				server.mu.Unlock()
+				io.WriteString(conn, "250 Queued\r\n")
+			case "QUIT":
+				io.WriteString(conn, "221 Bye\r\n")
+				return
+			default:
+				io.WriteString(conn, "502 Not implemented\r\n")
+			}
+		}
+	}()
+
+	return server
+}
+
+func TestSendSMTPEmail(t *testing.T) {
+	tests := []struct {
+		security string
+		username string
+	}{
+		{smtpSecuritySTARTTLS, "relay-user"},
+		{smtpSecurityTLS, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.security, func(t *testing.T) {
+			server := startFakeSMTPServer(t, tt.security)
+
+			status, err := sendSMTPEmail(EmailSettings{
+				Provider:     emailProviderSMTP,
+				From:         "SSL Monitor <monitor@example.com>",
+				To:           "ops@example.com, oncall@example.com",
+				SMTPHost:     "127.0.0.1",
+				SMTPPort:     server.port,
+				SMTPSecurity: tt.security,
+				SMTPUsername: tt.username,
+				SMTPPassword: "relay-password",
+			}, "SSL Certificate Warning: Example", "<h2>Warning</h2><p>Expires in <strong>5</strong> days &amp; counting</p>",
+				"<alert.2@example.com>", "<alert.1@example.com>")
+			if err != nil {
+				t.Fatalf("sendSMTPEmail returned error: %v", err)
+			}
+			if status != smtpAccepted {
+				t.Errorf("Expected status %d, got %d", smtpAccepted, status)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			if !server.usedTLS {
+				t.Error("Expected the session to be encrypted")
+			}
+			if len(server.recipients) != 2 {
+				t.Errorf("Expected 2 recipients, got %v", server.recipients)
+			}
+			if tt.username != "" && server.auth != "\x00relay-user\x00relay-password" {
+				t.Errorf("Unexpected AUTH PLAIN credentials %q", server.auth)
+			}
+			if tt.username == "" && server.auth != "" {
+				t.Error("Expected no authentication without a username")
+			}
+
+			message, err := mail.ReadMessage(strings.NewReader(server.data))
+			if err != nil {
+				t.Fatalf("Failed to parse sent message: %v", err)
+			}
+			if message.Header.Get("Message-ID") != "<alert.2@example.com>" {
+				t.Errorf("Unexpected Message-ID %q", message.Header.Get("Message-ID"))
+			}
+			if message.Header.Get("In-Reply-To") != "<alert.1@example.com>" || message.Header.Get("References") != "<alert.1@example.com>" {
+				t.Errorf("Expected the message to reply to the earlier alert, got %q", message.Header.Get("In-Reply-To"))
+			}
+
+			mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/alternative" {
+				t.Fatalf("Expected multipart/alternative, got %q", message.Header.Get("Content-Type"))
+			}
+			reader := multipart.NewReader(message.Body, params["boundary"])
+			var types []string
+			var plain string
+			for {
+				part, err := reader.NextPart()
+				if err != nil {
+					break
+				}
+				types = append(types, strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0])
+				body, _ := io.ReadAll(part)
+				if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+					plain = string(body)
+				}
+			}
+			if strings.Join(types, ",") != "text/plain,text/html" {
+				t.Errorf("Expected plain text and HTML parts, got %v", types)
+			}
+			if !strings.Contains(plain, "Expires in 5 days & counting") {
+				t.Errorf("Unexpected plain text part %q", plain)
+			}
+		})
+	}
+}
+
+func TestSendSMTPEmail_NoSTARTTLS(t *testing.T) {
+	// A server that only speaks plain SMTP must not be used when STARTTLS is required
+	server := startFakeSMTPServer(t, smtpSecurityNone)
+
+	_, err := sendSMTPEmail(EmailSettings{
+		From:         "monitor@example.com",
+		To:           "ops@example.com",
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     server.port,
+		SMTPSecurity: smtpSecuritySTARTTLS,
+	}, "Subject", "<p>Body</p>", "<id@example.com>", "")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Expected STARTTLS error, got %v", err)
+	}
+}
+
+func TestPlainTextFromHTML(t *testing.T) {
+	html := `
+<h2>SSL Certificate Warning</h2>
+<p>The certificate for <strong>Example</strong> is expiring.</p>
+<ul>
+<li><strong>Days remaining:</strong> 5</li>
+<li><strong>Expiry date:</strong> 2025-01-01</li>
+</ul>`
+
+	expected := "SSL Certificate Warning\n\nThe certificate for Example is expiring.\n\n- Days remaining: 5\n- Expiry date: 2025-01-01\n"
+	if text := plainTextFromHTML(html); text != expected {
+		t.Errorf("plainTextFromHTML = %q, want %q", text, expected)
+	}
+}
+
+func TestEmailTestHonoursProvider(t *testing.T) {
+	var settings Settings
+	settings.Notifications.Email = EmailSettings{
+		Provider: emailProviderSMTP,
+		From:     "monitor@example.com",
+		To:       "ops@example.com",
+	}
+
+	err := emailNotifier{}.Test(settings)
+	if err == nil || !strings.Contains(err.Error(), "SMTP host") {
+		t.Errorf("Expected missing SMTP host error, got %v", err)
+	}
+
+	server := startFakeSMTPServer(t, smtpSecuritySTARTTLS)
+	settings.Notifications.Email.SMTPHost = "127.0.0.1"
+	settings.Notifications.Email.SMTPPort = server.port
+
+	if err := (emailNotifier{}).Test(settings); err != nil {
+		t.Fatalf("Test returned error: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !strings.Contains(server.data, "SSL Monitor Test") {
+		t.Error("Expected the test email to go through the SMTP relay")
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+type SiteProtocol struct {
+	Name        string // value stored in Site.Protocol, empty for direct TLS
+	Label       string
+	DefaultPort int
+}
+
+// siteProtocols lists the protocols offered on the sites page, in display order
+var siteProtocols = []SiteProtocol{
+	{Name: "", Label: "TLS (HTTPS)", DefaultPort: defaultTLSPort},
+	{Name: "smtp", Label: "SMTP STARTTLS", DefaultPort: 25},
+	{Name: "submission", Label: "SMTP Submission STARTTLS", DefaultPort: 587},
+	{Name: "imap", Label: "IMAP STARTTLS", DefaultPort: 143},
+	{Name: "pop3", Label: "POP3 STLS", DefaultPort: 110},
+	{Name: "ftp", Label: "FTP AUTH TLS", DefaultPort: 21},
+	{Name: "ldap", Label: "LDAP StartTLS", DefaultPort: 389},
+	{Name: "xmpp", Label: "XMPP STARTTLS", DefaultPort: 5222},
+	{Name: "postgres", Label: "PostgreSQL", DefaultPort: 5432},
+	{Name: "mysql", Label: "MySQL", DefaultPort: 3306},
+}
+
+var starttlsDefaultPorts = func() map[string]int {
+	ports := make(map[string]int)
+	for _, protocol := range siteProtocols {
+		if protocol.Name != "" {
+			ports[protocol.Name] = protocol.DefaultPort
+		}
+	}
+	return ports
+}()
+
+func defaultPortForProtocol(protocol string) int {
+	if port, ok := starttlsDefaultPorts[protocol]; ok {
+		return port
+	}
+	return defaultTLSPort
+}
+
+// startTLS speaks just enough of protocol over conn to ask the server to
+// switch to TLS. On success the caller can start the TLS handshake on conn.
+func startTLS(conn net.Conn, protocol string, serverName string) error {
+	reader := bufio.NewReader(conn)
+
+	switch protocol {
+	case "smtp", "submission":
+		return startTLSSMTP(conn, reader)
+	case "imap":
+		return startTLSIMAP(conn, reader)
+	case "pop3":
+		return startTLSPOP3(conn, reader)
+	case "ftp":
+		return startTLSFTP(conn, reader)
+	case "ldap":
+		return startTLSLDAP(conn, reader)
+	case "xmpp":
+		return startTLSXMPP(conn, reader, serverName)
+	case "postgres":
+		return startTLSPostgres(conn, reader)
+	case "mysql":
+		return startTLSMySQL(conn, reader)
+	default:
+		return fmt.Errorf("unsupported protocol %q", protocol)
+	}
+}
+
+func writeLine(conn net.Conn, line string) error {
+	_, err := io.WriteString(conn, line+"\r\n")
+	return err
+}
+
+// readNumericReply reads an SMTP or FTP style reply, following "250-" continuation
+// lines, and checks the final code matches expected
+func readNumericReply(reader *bufio.Reader, expected string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+
+		if len(line) < 3 {
+			return lines, fmt.Errorf("malformed reply %q", line)
+		}
+		if len(line) == 3 || line[3] == ' ' {
+			if line[:3] != expected {
+				return lines, fmt.Errorf("unexpected reply %q", line)
+			}
+			return lines, nil
+		}
+	}
+}
+
+func startTLSSMTP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := readNumericReply(reader, "220"); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+
+	if err := writeLine(conn, "EHLO ssl-monitor"); err != nil {
+		return err
+	}
+	lines, err := readNumericReply(reader, "250")
+	if err != nil {
+		return fmt.Errorf("EHLO: %w", err)
+	}
+
+	supported := false
+	for _, line := range lines {
+		if len(line) > 4 && strings.EqualFold(strings.TrimSpace(line[4:]), "STARTTLS") {
+			supported = true
+		}
+	}
+	if !supported {
+		return errors.New("server does not advertise STARTTLS")
+	}
+
+	if err := writeLine(conn, "STARTTLS"); err != nil {
+		return err
+	}
+	if _, err := readNumericReply(reader, "220"); err != nil {
+		return fmt.Errorf("STARTTLS: %w", err)
+	}
+	return nil
+}
+
+func startTLSFTP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := readNumericReply(reader, "220"); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+
+	if err := writeLine(conn, "AUTH TLS"); err != nil {
+		return err
+	}
+	if _, err := readNumericReply(reader, "234"); err != nil {
+		return fmt.Errorf("AUTH TLS: %w", err)
+	}
+	return nil
+}
+
+func startTLSIMAP(conn net.Conn, reader *bufio.Reader) error {
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+
+	if err := writeLine(conn, "a001 STARTTLS"); err != nil {
+		return err
+	}
+
+	// Skip any untagged responses until the tagged completion
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+		if strings.HasPrefix(line, "a001 ") {
+			if !strings.HasPrefix(line, "a001 OK") {
+				return fmt.Errorf("STARTTLS refused: %q", strings.TrimSpace(line))
+			}
+			return nil
+		}
+	}
+}
+
+func startTLSPOP3(conn net.Conn, reader *bufio.Reader) error {
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+
+	if err := writeLine(conn, "STLS"); err != nil {
+		return err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("STLS: %w", err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("STLS refused: %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// readUntil reads from reader until one of the markers appears, returning everything read
+func readUntil(reader *bufio.Reader, markers ...string) (string, error) {
+	var buffer bytes.Buffer
+	for buffer.Len() < 64*1024 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return buffer.String(), err
+		}
+		buffer.WriteByte(b)
+
+		for _, marker := range markers {
+			if bytes.HasSuffix(buffer.Bytes(), []byte(marker)) {
+				return buffer.String(), nil
+			}
+		}
+	}
+	return buffer.String(), errors.New("response too large")
+}
+
+func startTLSXMPP(conn net.Conn, reader *bufio.Reader, serverName string) error {
+	header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", serverName)
+	if _, err := io.WriteString(conn, header); err != nil {
+		return err
+	}
+
+	features, err := readUntil(reader, "</stream:features>")
+	if err != nil {
+		return fmt.Errorf("stream features: %w", err)
+	}
+	if !strings.Contains(features, "<starttls") {
+		return errors.New("server does not advertise STARTTLS")
+	}
+
+	if _, err := io.WriteString(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+
+	response, err := readUntil(reader, "/>", "</proceed>", "</failure>")
+	if err != nil {
+		return fmt.Errorf("STARTTLS: %w", err)
+	}
+	if !strings.Contains(response, "<proceed") {
+		return fmt.Errorf("STARTTLS refused: %q", response)
+	}
+	return nil
+}
+
+// LDAP ExtendedRequest for the StartTLS OID 1.3.6.1.4.1.1466.20037 with message ID 1
+var ldapStartTLSRequest = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+
+// readBERElement reads one BER encoded element, returning its tag and contents
+func readBERElement(reader io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		lengthBytes := int(header[1] & 0x7f)
+		if lengthBytes == 0 || lengthBytes > 3 {
+			return 0, nil, fmt.Errorf("unsupported BER length encoding 0x%02x", header[1])
+		}
+		encoded := make([]byte, lengthBytes)
+		if _, err := io.ReadFull(reader, encoded); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range encoded {
+			length = length<<8 | int(b)
+		}
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return 0, nil, err
+	}
+	return header[0], content, nil
+}
+
+func startTLSLDAP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	tag, message, err := readBERElement(reader)
+	if err != nil {
+		return fmt.Errorf("extended response: %w", err)
+	}
+	if tag != 0x30 {
+		return fmt.Errorf("unexpected LDAP message tag 0x%02x", tag)
+	}
+
+	body := bytes.NewReader(message)
+	if _, _, err := readBERElement(body); err != nil { // message ID
+		return fmt.Errorf("message ID: %w", err)
+	}
+	tag, response, err := readBERElement(body)
+	if err != nil {
+		return fmt.Errorf("extended response: %w", err)
+	}
+	if tag != 0x78 {
+		return fmt.Errorf("unexpected LDAP response tag 0x%02x", tag)
+	}
+
+	tag, resultCode, err := readBERElement(bytes.NewReader(response))
+	if err != nil {
+		return fmt.Errorf("result code: %w", err)
+	}
+	if tag != 0x0a || len(resultCode) != 1 {
+		return errors.New("malformed LDAP result code")
+	}
+	if resultCode[0] != 0 {
+		return fmt.Errorf("StartTLS refused with LDAP result code %d", resultCode[0])
+	}
+	return nil
+}
+
+// PostgreSQL SSLRequest: length 8 followed by the magic code 80877103
+var postgresSSLRequest = []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f}
+
+func startTLSPostgres(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := conn.Write(postgresSSLRequest); err != nil {
+		return err
+	}
+
+	response, err := reader.ReadByte()
+	if err != nil {
+		return fmt.Errorf("SSLRequest: %w", err)
+	}
+	if response != 'S' {
+		return errors.New("server does not support SSL")
+	}
+	return nil
+}
+
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+func startTLSMySQL(conn net.Conn, reader *bufio.Reader) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+
+	if len(payload) == 0 || payload[0] == 0xff {
+		return errors.New("server returned an error instead of a handshake")
+	}
+	if payload[0] != 10 {
+		return fmt.Errorf("unsupported handshake protocol version %d", payload[0])
+	}
+
+	// Skip the null terminated server version, connection ID, first auth data part and filler
+	versionEnd := bytes.IndexByte(payload[1:], 0)
+	if versionEnd < 0 {
+		return errors.New("malformed handshake")
+	}
+	offset := 1 + versionEnd + 1 + 4 + 8 + 1
+	if len(payload) < offset+2 {
+		return errors.New("malformed handshake")
+	}
+	capabilities := binary.LittleEndian.Uint16(payload[offset:])
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+
+	// SSLRequest packet: capability flags, max packet size, character set and 23 reserved bytes
+	request := make([]byte, 4+32)
+	request[0] = 32
+	request[3] = header[3] + 1
+	binary.LittleEndian.PutUint32(request[4:], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[8:], 16*1024*1024)
+	request[12] = 45 // utf8mb4_general_ci
+
+	_, err := conn.Write(request)
+	return err
+}
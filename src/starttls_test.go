@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectLine(reader *bufio.Reader, prefix string) bool {
+	line, err := reader.ReadString('\n')
+	return err == nil && strings.HasPrefix(line, prefix)
+}
+
+func fakeSMTP(conn net.Conn, reader *bufio.Reader) bool {
+	io.WriteString(conn, "220-mail.example.com ESMTP\r\n220 ready\r\n")
+	if !expectLine(reader, "EHLO") {
+		return false
+	}
+	io.WriteString(conn, "250-mail.example.com\r\n250-PIPELINING\r\n250-STARTTLS\r\n250 8BITMIME\r\n")
+	if !expectLine(reader, "STARTTLS") {
+		return false
+	}
+	io.WriteString(conn, "220 2.0.0 Ready to start TLS\r\n")
+	return true
+}
+
+func fakeIMAP(conn net.Conn, reader *bufio.Reader) bool {
+	io.WriteString(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] Dovecot ready.\r\n")
+	if !expectLine(reader, "a001 STARTTLS") {
+		return false
+	}
+	io.WriteString(conn, "* NOTE something untagged\r\na001 OK Begin TLS negotiation now.\r\n")
+	return true
+}
+
+func fakePOP3(conn net.Conn, reader *bufio.Reader) bool {
+	io.WriteString(conn, "+OK Dovecot ready.\r\n")
+	if !expectLine(reader, "STLS") {
+		return false
+	}
+	io.WriteString(conn, "+OK Begin TLS negotiation now.\r\n")
+	return true
+}
+
+func fakeFTP(conn net.Conn, reader *bufio.Reader) bool {
+	io.WriteString(conn, "220-Welcome\r\n220 FTP ready\r\n")
+	if !expectLine(reader, "AUTH TLS") {
+		return false
+	}
+	io.WriteString(conn, "234 AUTH TLS successful\r\n")
+	return true
+}
+
+func fakeXMPP(conn net.Conn, reader *bufio.Reader) bool {
+	if _, err := readUntil(reader, "version='1.0'>"); err != nil {
+		return false
+	}
+	io.WriteString(conn, "<?xml version='1.0'?><stream:stream from='localhost' id='1' version='1.0' "+
+		"xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>"+
+		"<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+	if _, err := readUntil(reader, "/>"); err != nil {
+		return false
+	}
+	io.WriteString(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+	return true
+}
+
+func fakeLDAP(conn net.Conn, reader *bufio.Reader) bool {
+	request := make([]byte, len(ldapStartTLSRequest))
+	if _, err := io.ReadFull(reader, request); err != nil || string(request) != string(ldapStartTLSRequest) {
+		return false
+	}
+	// ExtendedResponse: message ID 1, resultCode success, empty matchedDN and diagnosticMessage
+	conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+	return true
+}
+
+func fakePostgres(conn net.Conn, reader *bufio.Reader) bool {
+	request := make([]byte, 8)
+	if _, err := io.ReadFull(reader, request); err != nil || string(request) != string(postgresSSLRequest) {
+		return false
+	}
+	conn.Write([]byte{'S'})
+	return true
+}
+
+func fakeMySQL(conn net.Conn, reader *bufio.Reader) bool {
+	// Protocol 10 handshake with the SSL capability
+	payload := []byte{10}
+	payload = append(payload, "8.0.36\x00"...)
+	payload = append(payload, 1, 0, 0, 0)    // connection ID
+	payload = append(payload, "abcdefgh"...) // auth plugin data part 1
+	payload = append(payload, 0)             // filler
+	payload = binary.LittleEndian.AppendUint16(payload, mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	payload = append(payload, 45, 2, 0, 0, 0) // charset, status, upper capabilities
+	header := []byte{byte(len(payload)), 0, 0, 0}
+	conn.Write(append(header, payload...))
+
+	// Read straight from conn, the client sends its ClientHello right behind the SSLRequest
+	request := make([]byte, 36)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return false
+	}
+	flags := binary.LittleEndian.Uint32(request[4:])
+	return request[0] == 32 && request[3] == 1 && flags&mysqlClientSSL != 0
+}
+
+func TestCheckCertificate_STARTTLS(t *testing.T) {
+	tests := []struct {
+		protocol string
+		server   func(net.Conn, *bufio.Reader) bool
+	}{
+		{"smtp", fakeSMTP},
+		{"submission", fakeSMTP},
+		{"imap", fakeIMAP},
+		{"pop3", fakePOP3},
+		{"ftp", fakeFTP},
+		{"xmpp", fakeXMPP},
+		{"ldap", fakeLDAP},
+		{"postgres", fakePostgres},
+		{"mysql", fakeMySQL},
+		{"", nil},
+	}
+
+	expiry := time.Now().Add(45 * 24 * time.Hour).Truncate(time.Second)
+	certificate, cert := newTestCertificate(t, expiry)
+	trustTestCertificate(t, cert)
+
+	for _, tt := range tests {
+		t.Run("protocol "+tt.protocol, func(t *testing.T) {
+			port := startFakeServer(t, certificate, tt.server)
+
+			site := Site{
+				Name:     "Fake " + tt.protocol,
+				URL:      "127.0.0.1",
+				Host:     "127.0.0.1",
+				Port:     port,
+				Protocol: tt.protocol,
+				Enabled:  true,
+			}
+
+			result := checkCertificate(site)
+			if result.Error != "" {
+				t.Fatalf("Expected no error, got %q", result.Error)
+			}
+			if !result.ExpiryDate.Equal(expiry) {
+				t.Errorf("Expected expiry %v, got %v", expiry, result.ExpiryDate)
+			}
+			if result.DaysLeft < 44 || result.DaysLeft > 45 {
+				t.Errorf("Expected about 45 days left, got %d", result.DaysLeft)
+			}
+		})
+	}
+}
+
+func TestCheckCertificate_STARTTLSRefused(t *testing.T) {
+	certificate, cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	trustTestCertificate(t, cert)
+
+	port := startFakeServer(t, certificate, func(conn net.Conn, reader *bufio.Reader) bool {
+		io.WriteString(conn, "220 ready\r\n")
+		expectLine(reader, "EHLO")
+		io.WriteString(conn, "250-mail.example.com\r\n250 8BITMIME\r\n")
+		return false
+	})
+
+	result := checkCertificate(Site{Name: "No STARTTLS", Host: "127.0.0.1", Port: port, Protocol: "smtp"})
+	if !strings.Contains(result.Error, "does not advertise STARTTLS") {
+		t.Errorf("Expected STARTTLS not advertised error, got %q", result.Error)
+	}
+}
+
+func TestDefaultPortForProtocol(t *testing.T) {
+	tests := map[string]int{
+		"":           443,
+		"smtp":       25,
+		"submission": 587,
+		"imap":       143,
+		"pop3":       110,
+		"ftp":        21,
+		"ldap":       389,
+		"xmpp":       5222,
+		"postgres":   5432,
+		"mysql":      3306,
+		"unknown":    443,
+	}
+
+	for protocol, expected := range tests {
+		if port := defaultPortForProtocol(protocol); port != expected {
+			t.Errorf("defaultPortForProtocol(%q) = %d, expected %d", protocol, port, expected)
+		}
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	valid := map[string]string{
+		"":      "",
+		"tls":   "",
+		"SMTP":  "smtp",
+		" ldap": "ldap",
+	}
+	for input, expected := range valid {
+		protocol, err := parseProtocol(input)
+		if err != nil {
+			t.Errorf("parseProtocol(%q) returned error: %v", input, err)
+		}
+		if protocol != expected {
+			t.Errorf("parseProtocol(%q) = %q, expected %q", input, protocol, expected)
+		}
+	}
+
+	if _, err := parseProtocol("gopher"); err == nil {
+		t.Error("parseProtocol should reject unsupported protocols")
+	}
+}
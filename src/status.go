@@ -16,13 +16,16 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Initialize counters for status types
 	criticalCount := 0
+	errorCount := 0
 	warningCount := 0
 
-	// Count sites in critical and warning statuses
+	// Count sites in critical, error and warning statuses
 	for _, history := range state.NotificationHistory {
 		switch history.LastStatus {
 		case "critical":
 			criticalCount++
+		case "error":
+			errorCount++
 		case "warning":
 			warningCount++
 		}
@@ -32,6 +35,9 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	if criticalCount > 0 {
 		w.Header().Set("Content-Type", "text/plain")
 		fmt.Fprint(w, "critical")
+	} else if errorCount > 0 {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "error")
 	} else if warningCount > 0 {
 		w.Header().Set("Content-Type", "text/plain")
 		fmt.Fprint(w, "warning")
@@ -41,5 +47,5 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log the status check
-	LogDebug("Status endpoint accessed: critical=%d, warning=%d", criticalCount, warningCount)
+	LogDebug("Status endpoint accessed: critical=%d, error=%d, warning=%d", criticalCount, errorCount, warningCount)
 }
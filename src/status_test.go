@@ -133,6 +133,42 @@ func TestStatusHandler(t *testing.T) {
 			expectedStatus: "warning",
 			expectedCode:   http.StatusOK,
 		},
+		{
+			name: "site failing scans - should return error",
+			notificationState: NotificationState{
+				NotificationHistory: map[string]NotificationHistory{
+					"warning-site.com": {
+						LastStatus: "warning",
+						LastScan:   time.Now().Add(-12 * time.Hour),
+					},
+					"down-site.com": {
+						LastStatus:          "error",
+						LastScan:            time.Now().Add(-1 * time.Hour),
+						ConsecutiveFailures: 3,
+						LastError:           "connection refused",
+					},
+				},
+			},
+			expectedStatus: "error",
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name: "error and critical - should return critical",
+			notificationState: NotificationState{
+				NotificationHistory: map[string]NotificationHistory{
+					"down-site.com": {
+						LastStatus: "error",
+						LastScan:   time.Now().Add(-1 * time.Hour),
+					},
+					"critical-site.com": {
+						LastStatus: "critical",
+						LastScan:   time.Now().Add(-1 * time.Hour),
+					},
+				},
+			},
+			expectedStatus: "critical",
+			expectedCode:   http.StatusOK,
+		},
 		{
 			name: "multiple critical sites - should return critical",
 			notificationState: NotificationState{
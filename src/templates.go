@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MessageTemplate overrides the wording of one channel's message for one
+// severity. An empty field keeps the default.
+type MessageTemplate struct {
+	Title string `json:"title,omitempty"` // email subject, or the ntfy and chat title
+	Body  string `json:"body,omitempty"`  // HTML for email, plain text for the others
+}
+
+// templateChannels can have their own template for each of the
+// notificationSeverities. The webhook has its body template instead.
+var templateChannels = []string{"email", "ntfy", "slack", "teams", "discord"}
+
+// TemplateData is what message templates are executed with
+type TemplateData struct {
+	Severity            string      // "warning", "critical", "error", "change" or "resolved"
+	PreviousStatus      string      // status before this notification
+	Result              CertResult  // latest scan result for the site
+	Change              *CertChange // the certificate change, for "change"
+	ChangeDescription   string      // e.g. "is now issued by a different CA", for "change"
+	Failures            int         // consecutive failed scans, for "error"
+	NodeFailure         bool        // for "error", only some of the site's nodes failed
+	Reminder            int         // reminder number, 0 for the first alert
+	Escalated           bool
+	ExpiringCertificate string // the chain element that expires first, e.g. "leaf certificate"
+	ResolvedDescription string // how the site recovered, for "resolved"
+	AlertTitle          string // title of the alert being resolved, for "resolved"
+	AlertReference      string // this channel's reference to the incident's first alert
+	SiteURL             string // the site's page on the dashboard
+	DashboardURL        string
+}
+
+var messageTemplateFuncs = map[string]interface{}{
+	"date":     func(t time.Time) string { return t.Format("2006-01-02") },
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+const emailDetailsTemplate = `<ul>
+<li><strong>Days remaining:</strong> {{.Result.DaysLeft}}</li>
+<li><strong>Expiry date:</strong> {{date .Result.ExpiryDate}}</li>
+<li><strong>Expiring certificate:</strong> {{.ExpiringCertificate}}</li>
+<li><strong>Checked:</strong> {{datetime .Result.LastCheck}}</li>
+</ul>`
+
+// defaultMessageTemplates holds the built-in wording. Slack, Teams and Discord
+// share the "chat" templates.
+var defaultMessageTemplates = map[string]map[string]MessageTemplate{
+	"email": {
+		"warning": {
+			Title: `SSL Certificate Warning: {{.Result.Name}}`,
+			Body: `<h2>SSL Certificate Warning</h2>
+<p>The SSL certificate for <strong>{{.Result.Name}}</strong> ({{.Result.URL}}) is approaching expiration.</p>
+` + emailDetailsTemplate + `
+<p>Please renew the certificate soon to avoid service interruption.</p>`,
+		},
+		"critical": {
+			Title: `SSL Certificate Critical: {{.Result.Name}}`,
+			Body: `<h2>🚨 SSL Certificate Critical Warning</h2>
+<p>The SSL certificate for <strong>{{.Result.Name}}</strong> ({{.Result.URL}}) is expiring very soon!</p>
+` + emailDetailsTemplate + `
+<p><strong>Action required immediately</strong> to prevent service interruption.</p>`,
+		},
+		"error": {
+			Title: `SSL Certificate Check Failing: {{.Result.Name}}`,
+			Body: `<h2>SSL Certificate Check Failing</h2>
+<p>The SSL certificate for <strong>{{.Result.Name}}</strong> ({{.Result.URL}}) could not be checked {{if .NodeFailure}}on some of its addresses {{end}}on the last {{.Failures}} scans.</p>
+<ul>
+<li><strong>Error:</strong> {{.Result.Error}}</li>
+<li><strong>Checked:</strong> {{datetime .Result.LastCheck}}</li>
+</ul>
+{{if .NodeFailure}}<p>The other addresses were checked, so expiry alerts continue. Check the failing nodes are up, or remove their DNS records.</p>{{else}}<p>The site may be down, or its certificate may be invalid. Expiry alerts can't be sent until it can be checked again.</p>{{end}}`,
+		},
+		"change": {
+			Title: `SSL Certificate Changed: {{.Change.Name}}`,
+			Body: `<h2>SSL Certificate Changed</h2>
+<p>The SSL certificate for <strong>{{.Change.Name}}</strong> ({{.Change.URL}}) {{.ChangeDescription}}.</p>
+<ul>
+<li><strong>Old issuer:</strong> {{.Change.OldIssuer}}</li>
+<li><strong>New issuer:</strong> {{.Change.NewIssuer}}</li>
+<li><strong>Old expiry date:</strong> {{date .Change.OldExpiry}}</li>
+<li><strong>New expiry date:</strong> {{date .Change.NewExpiry}}</li>
+<li><strong>New fingerprint:</strong> {{.Change.NewFingerprint}}</li>
+<li><strong>Detected:</strong> {{datetime .Change.DetectedAt}}</li>
+</ul>
+<p>If this change was not planned, please check who replaced the certificate.</p>`,
+		},
+		"resolved": {
+			Title: `SSL Certificate Resolved: {{.Result.Name}}`,
+			Body: `<h2>✅ SSL Certificate Resolved</h2>
+<p>The SSL certificate for <strong>{{.Result.Name}}</strong> ({{.Result.URL}}) {{.ResolvedDescription}}.</p>
+<ul>
+<li><strong>Days remaining:</strong> {{.Result.DaysLeft}}</li>
+<li><strong>New expiry date:</strong> {{date .Result.ExpiryDate}}</li>
+<li><strong>Checked:</strong> {{datetime .Result.LastCheck}}</li>
+</ul>
+<p>No further action is needed.</p>`,
+		},
+	},
+	"ntfy": {
+		"warning": {
+			Title: `SSL Warning: {{.Result.Name}}`,
+			Body:  `Certificate for {{.Result.URL}} expires in {{.Result.DaysLeft}} days ({{date .Result.ExpiryDate}}).{{if .Result.ExpiringChainIndex}} The expiring certificate is the {{.ExpiringCertificate}}.{{end}}`,
+		},
+		"critical": {
+			Title: `🚨 SSL Critical: {{.Result.Name}}`,
+			Body:  `URGENT: Certificate for {{.Result.URL}} expires in {{.Result.DaysLeft}} days ({{date .Result.ExpiryDate}})!{{if .Result.ExpiringChainIndex}} The expiring certificate is the {{.ExpiringCertificate}}.{{end}}`,
+		},
+		"error": {
+			Title: `SSL Check Failing: {{.Result.Name}}`,
+			Body:  `Certificate for {{.Result.URL}} could not be checked {{if .NodeFailure}}on some of its addresses {{end}}on the last {{.Failures}} scans: {{.Result.Error}}`,
+		},
+		"change": {
+			Title: `SSL Changed: {{.Change.Name}}`,
+			Body:  `Certificate for {{.Change.URL}} {{.ChangeDescription}}. Issuer: {{.Change.OldIssuer}} -> {{.Change.NewIssuer}}. Expiry: {{date .Change.OldExpiry}} -> {{date .Change.NewExpiry}}`,
+		},
+		"resolved": {
+			Title: `SSL Resolved: {{.Result.Name}}`,
+			Body:  `Certificate for {{.Result.URL}} {{.ResolvedDescription}} and expires in {{.Result.DaysLeft}} days ({{date .Result.ExpiryDate}}). Resolves "{{.AlertTitle}}"{{if .AlertReference}} (message {{.AlertReference}}){{end}}.`,
+		},
+	},
+	"chat": {
+		"warning": {
+			Title: `SSL Certificate Warning: {{.Result.Name}}`,
+			Body:  `The certificate for {{.Result.URL}} is approaching expiration.`,
+		},
+		"critical": {
+			Title: `🚨 SSL Certificate Critical: {{.Result.Name}}`,
+			Body:  `The certificate for {{.Result.URL}} is expiring very soon. Action required immediately.`,
+		},
+		"error": {
+			Title: `SSL Certificate Check Failing: {{.Result.Name}}`,
+			Body:  `The certificate for {{.Result.URL}} could not be checked {{if .NodeFailure}}on some of its addresses {{end}}on the last {{.Failures}} scans.`,
+		},
+		"change": {
+			Title: `SSL Certificate Changed: {{.Change.Name}}`,
+			Body:  `The certificate for {{.Change.URL}} {{.ChangeDescription}}.`,
+		},
+		"resolved": {
+			Title: `✅ SSL Certificate Resolved: {{.Result.Name}}`,
+			Body:  `The certificate for {{.Result.URL}} {{.ResolvedDescription}}.`,
+		},
+	},
+}
+
+func defaultMessageTemplate(channel string, severity string) MessageTemplate {
+	if channel == "slack" || channel == "teams" || channel == "discord" {
+		channel = "chat"
+	}
+	return defaultMessageTemplates[channel][severity]
+}
+
+// messageTemplate is the saved template for a channel and severity, with the
+// default filling in any empty field
+func messageTemplate(settings Settings, channel string, severity string) MessageTemplate {
+	tmpl := defaultMessageTemplate(channel, severity)
+	saved := settings.Templates[channel][severity]
+	if strings.TrimSpace(saved.Title) != "" {
+		tmpl.Title = saved.Title
+	}
+	if strings.TrimSpace(saved.Body) != "" {
+		tmpl.Body = saved.Body
+	}
+	return tmpl
+}
+
+// executeMessageTemplate renders a template. Email bodies are HTML and have
+// their values escaped, everything else is plain text.
+func executeMessageTemplate(channel string, tmpl MessageTemplate, data TemplateData) (string, string, error) {
+	parsedTitle, err := template.New("title").Funcs(messageTemplateFuncs).Parse(tmpl.Title)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid title template: %w", err)
+	}
+	var title bytes.Buffer
+	if err := parsedTitle.Execute(&title, data); err != nil {
+		return "", "", fmt.Errorf("error rendering title template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if channel == "email" {
+		parsedBody, err := htmltemplate.New("body").Funcs(messageTemplateFuncs).Parse(tmpl.Body)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid body template: %w", err)
+		}
+		err = parsedBody.Execute(&body, data)
+		if err != nil {
+			return "", "", fmt.Errorf("error rendering body template: %w", err)
+		}
+	} else {
+		parsedBody, err := template.New("body").Funcs(messageTemplateFuncs).Parse(tmpl.Body)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid body template: %w", err)
+		}
+		err = parsedBody.Execute(&body, data)
+		if err != nil {
+			return "", "", fmt.Errorf("error rendering body template: %w", err)
+		}
+	}
+
+	return strings.TrimSpace(title.String()), strings.TrimSpace(body.String()), nil
+}
+
+// renderMessage renders the title and body for a notification on one channel.
+// A broken saved template is logged and the default used instead, so the alert
+// still goes out.
+func renderMessage(settings Settings, channel string, data TemplateData) (string, string) {
+	title, body, err := executeMessageTemplate(channel, messageTemplate(settings, channel, data.Severity), data)
+	if err != nil {
+		LogError("Error rendering %s %s template, using the default: %v", channel, data.Severity, err)
+		title, body, _ = executeMessageTemplate(channel, defaultMessageTemplate(channel, data.Severity), data)
+	}
+	return title, body
+}
+
+func newTemplateData(notification Notification, settings Settings, channel string) TemplateData {
+	data := TemplateData{
+		Severity:            notification.Severity,
+		PreviousStatus:      notification.PreviousStatus,
+		Result:              notification.Result,
+		Change:              notification.Change,
+		Failures:            notification.Failures,
+		NodeFailure:         notification.NodeFailure,
+		Reminder:            notification.Reminder,
+		Escalated:           notification.Escalated,
+		ExpiringCertificate: expiringElementDescription(notification.Result),
+		AlertReference:      notification.AlertReferences[channel],
+		SiteURL:             siteDashboardURL(settings, notification.Result.URL),
+		DashboardURL:        dashboardURL(settings),
+	}
+	if notification.Change != nil {
+		data.ChangeDescription = describeCertChange(*notification.Change)
+	}
+	if notification.Severity == "resolved" {
+		data.ResolvedDescription = resolvedDescription(notification.PreviousStatus)
+
+		// Channels without threads quote the alert they resolve
+		alert := data
+		alert.Severity = notification.PreviousStatus
+		alertTemplate := MessageTemplate{Title: messageTemplate(settings, channel, notification.PreviousStatus).Title}
+		data.AlertTitle, _, _ = executeMessageTemplate(channel, alertTemplate, alert)
+	}
+	return data
+}
+
+// sampleNotification is what template previews are rendered against
+func sampleNotification(severity string, now time.Time) Notification {
+	result := CertResult{
+		URL:        "example.com",
+		Name:       "Example Site",
+		ExpiryDate: now.AddDate(0, 0, 5),
+		DaysLeft:   5,
+		LastCheck:  now,
+	}
+	notification := Notification{Severity: severity, PreviousStatus: "normal", Result: result}
+
+	switch severity {
+	case "warning":
+		notification.Result.ExpiryDate = now.AddDate(0, 0, 20)
+		notification.Result.DaysLeft = 20
+	case "error":
+		notification.Result = CertResult{URL: result.URL, Name: result.Name, LastCheck: now, Error: "dial tcp 93.184.216.34:443: connect: connection refused"}
+		notification.Failures = 3
+	case "change":
+		notification.Change = &CertChange{
+			URL:            result.URL,
+			Name:           result.Name,
+			DetectedAt:     now,
+			Kind:           changeIssuerChanged,
+			Unexpected:     true,
+			OldFingerprint: "AA:BB:CC:DD",
+			NewFingerprint: "11:22:33:44",
+			OldExpiry:      now.AddDate(0, 0, 30),
+			NewExpiry:      now.AddDate(0, 0, 90),
+			OldIssuer:      "CN=R11,O=Let's Encrypt,C=US",
+			NewIssuer:      "CN=Unknown CA",
+		}
+	case "resolved":
+		notification.PreviousStatus = "critical"
+		notification.Result.ExpiryDate = now.AddDate(0, 0, 89)
+		notification.Result.DaysLeft = 89
+		notification.AlertReferences = map[string]string{"email": "<alert@example.com>", "ntfy": "hwQ2YpKdmg"}
+	}
+	return notification
+}
+
+// MessageTemplateField is one channel and severity's template on the settings page
+type MessageTemplateField struct {
+	Channel  string
+	Severity string
+	Title    string
+	Body     string
+}
+
+// MessageTemplateFields lists every template with its saved wording, or the
+// default where nothing is saved
+func (s Settings) MessageTemplateFields() []MessageTemplateField {
+	var fields []MessageTemplateField
+	for _, channel := range templateChannels {
+		for _, severity := range notificationSeverities {
+			tmpl := messageTemplate(s, channel, severity)
+			fields = append(fields, MessageTemplateField{channel, severity, tmpl.Title, tmpl.Body})
+		}
+	}
+	return fields
+}
+
+func validTemplateTarget(channel string, severity string) bool {
+	return containsString(templateChannels, channel) && containsString(notificationSeverities, severity)
+}
+
+// defaultTemplateHandler returns the built-in template for a channel and
+// severity, for the settings page's "insert default" button
+func defaultTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	severity := r.URL.Query().Get("severity")
+	if !validTemplateTarget(channel, severity) {
+		http.Error(w, "Unknown channel or severity", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(defaultMessageTemplate(channel, severity))
+}
+
+// templatePreview is the rendered sample returned to the settings page
+type templatePreview struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	HTML  bool   `json:"html"`
+	Error string `json:"error,omitempty"`
+}
+
+// previewTemplateHandler renders the posted template against a sample site
+func previewTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.FormValue("channel")
+	severity := r.FormValue("severity")
+	if !validTemplateTarget(channel, severity) {
+		http.Error(w, "Unknown channel or severity", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		LogError("Error loading settings for template preview: %v", err)
+		http.Error(w, "Error loading settings", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl := defaultMessageTemplate(channel, severity)
+	if title := r.FormValue("title"); strings.TrimSpace(title) != "" {
+		tmpl.Title = title
+	}
+	if body := r.FormValue("body"); strings.TrimSpace(body) != "" {
+		tmpl.Body = body
+	}
+
+	data := newTemplateData(sampleNotification(severity, time.Now()), settings, channel)
+	preview := templatePreview{HTML: channel == "email"}
+	preview.Title, preview.Body, err = executeMessageTemplate(channel, tmpl, data)
+	if err != nil {
+		preview.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// parseTemplateForm copies the template fields from the settings form. Fields
+// left empty, or matching the default, aren't saved.
+func parseTemplateForm(r *http.Request, settings *Settings) {
+	for _, channel := range templateChannels {
+		for _, severity := range notificationSeverities {
+			prefix := "template_" + channel + "_" + severity
+			if _, ok := r.Form[prefix+"_title"]; !ok {
+				continue
+			}
+
+			defaults := defaultMessageTemplate(channel, severity)
+			var saved MessageTemplate
+			if title := r.FormValue(prefix + "_title"); strings.TrimSpace(title) != defaults.Title {
+				saved.Title = strings.TrimSpace(title)
+			}
+			if body := strings.ReplaceAll(r.FormValue(prefix+"_body"), "\r\n", "\n"); strings.TrimSpace(body) != defaults.Body {
+				saved.Body = strings.TrimSpace(body)
+			}
+
+			setMessageTemplate(settings, channel, severity, saved)
+		}
+	}
+}
+
+func setMessageTemplate(settings *Settings, channel string, severity string, tmpl MessageTemplate) {
+	if tmpl == (MessageTemplate{}) {
+		delete(settings.Templates[channel], severity)
+		if len(settings.Templates[channel]) == 0 {
+			delete(settings.Templates, channel)
+		}
+		return
+	}
+
+	if settings.Templates == nil {
+		settings.Templates = make(map[string]map[string]MessageTemplate)
+	}
+	if settings.Templates[channel] == nil {
+		settings.Templates[channel] = make(map[string]MessageTemplate)
+	}
+	settings.Templates[channel][severity] = tmpl
+}
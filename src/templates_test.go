@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultMessageTemplates(t *testing.T) {
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	for _, channel := range templateChannels {
+		for _, severity := range notificationSeverities {
+			data := newTemplateData(sampleNotification(severity, now), Settings{}, channel)
+			title, body, err := executeMessageTemplate(channel, defaultMessageTemplate(channel, severity), data)
+			if err != nil {
+				t.Errorf("Default %s %s template failed: %v", channel, severity, err)
+				continue
+			}
+			if !strings.Contains(title, "Example Site") || !strings.Contains(body, "example.com") {
+				t.Errorf("Unexpected default %s %s message %q: %q", channel, severity, title, body)
+			}
+		}
+	}
+}
+
+func TestDefaultNtfyBodiesNameTheExpiringElement(t *testing.T) {
+	result := CertResult{URL: "example.com", Name: "Example", DaysLeft: 5, ExpiryDate: time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC),
+		Chain: []CertDetails{{}, {}}, ExpiringChainIndex: 1}
+	for _, severity := range []string{"warning", "critical"} {
+		_, message, _, _ := ntfyMessage(Notification{Severity: severity, Result: result}, Settings{})
+		if !strings.Contains(message, "(2025-01-09). The expiring") && !strings.Contains(message, "(2025-01-09)! The expiring") {
+			t.Errorf("Expected the %s sentences to be separated, got %q", severity, message)
+		}
+	}
+}
+
+func TestRenderMessageOverrides(t *testing.T) {
+	notification := Notification{
+		Severity: "critical",
+		Result:   CertResult{URL: "example.com", Name: "<Example>", DaysLeft: 3, ExpiryDate: time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC)},
+	}
+
+	var settings Settings
+	setMessageTemplate(&settings, "email", "critical", MessageTemplate{Body: `<p>{{.Result.Name}} expires {{date .Result.ExpiryDate}}</p>`})
+	setMessageTemplate(&settings, "slack", "critical", MessageTemplate{Title: `{{upper .Severity}}: {{.Result.Name}}`})
+
+	subject, body := renderMessage(settings, "email", newTemplateData(notification, settings, "email"))
+	if subject != "SSL Certificate Critical: <Example>" {
+		t.Errorf("Expected the default subject, got %q", subject)
+	}
+	if body != "<p>&lt;Example&gt; expires 2025-01-09</p>" {
+		t.Errorf("Expected an escaped custom email body, got %q", body)
+	}
+
+	message := chatMessageFor(notification, settings, "slack")
+	if message.Title != "CRITICAL: <Example>" || !strings.Contains(message.Text, "expiring very soon") {
+		t.Errorf("Expected the custom title with the default text, got %+v", message)
+	}
+	if discord := chatMessageFor(notification, settings, "discord"); !strings.HasPrefix(discord.Title, "🚨 SSL Certificate Critical") {
+		t.Errorf("Other channels should keep the default, got %q", discord.Title)
+	}
+
+	// A broken template falls back to the default rather than dropping the alert
+	setMessageTemplate(&settings, "ntfy", "critical", MessageTemplate{Title: `{{.Result.Nope}}`})
+	title, _, priority, _ := ntfyMessage(notification, settings)
+	if title != "🚨 SSL Critical: <Example>" || priority != "urgent" {
+		t.Errorf("Expected the default ntfy title, got %q (%s)", title, priority)
+	}
+}
+
+func TestParseTemplateForm(t *testing.T) {
+	defaults := defaultMessageTemplate("ntfy", "warning")
+	form := url.Values{
+		"template_ntfy_warning_title":   {defaults.Title},
+		"template_ntfy_warning_body":    {"{{.Result.Name}} is expiring\r\n"},
+		"template_email_change_title":   {defaultMessageTemplate("email", "change").Title},
+		"template_email_change_body":    {""},
+		"template_teams_resolved_title": {"Fixed: {{.Result.Name}}"},
+		"template_teams_resolved_body":  {defaultMessageTemplate("teams", "resolved").Body},
+	}
+	r := httptest.NewRequest("POST", "/settings", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ParseForm()
+
+	var settings Settings
+	setMessageTemplate(&settings, "email", "change", MessageTemplate{Title: "Old"})
+	setMessageTemplate(&settings, "slack", "error", MessageTemplate{Title: "Kept"})
+	parseTemplateForm(r, &settings)
+
+	expected := map[string]map[string]MessageTemplate{
+		"ntfy":  {"warning": {Body: "{{.Result.Name}} is expiring"}},
+		"teams": {"resolved": {Title: "Fixed: {{.Result.Name}}"}},
+		"slack": {"error": {Title: "Kept"}},
+	}
+	got, _ := json.Marshal(settings.Templates)
+	want, _ := json.Marshal(expected)
+	if string(got) != string(want) {
+		t.Errorf("Expected templates %s, got %s", want, got)
+	}
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	originalDataPath := dataDirPath
+	dataDirPath = t.TempDir()
+	defer func() { dataDirPath = originalDataPath }()
+	setupMinimalSettingsFile(t)
+
+	w := httptest.NewRecorder()
+	defaultTemplateHandler(w, httptest.NewRequest("GET", "/templates/default?channel=discord&severity=error", nil))
+	var tmpl MessageTemplate
+	json.NewDecoder(w.Body).Decode(&tmpl)
+	if tmpl != defaultMessageTemplates["chat"]["error"] {
+		t.Errorf("Expected the chat default, got %+v", tmpl)
+	}
+
+	w = httptest.NewRecorder()
+	defaultTemplateHandler(w, httptest.NewRequest("GET", "/templates/default?channel=webhook&severity=error", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a channel without templates, got %d", w.Code)
+	}
+
+	preview := func(form url.Values) templatePreview {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/templates/preview", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		previewTemplateHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var result templatePreview
+		json.NewDecoder(w.Body).Decode(&result)
+		return result
+	}
+
+	result := preview(url.Values{"channel": {"email"}, "severity": {"resolved"}, "body": {"<b>{{.Result.DaysLeft}} days</b>"}})
+	if !result.HTML || result.Body != "<b>89 days</b>" || result.Title != "SSL Certificate Resolved: Example Site" {
+		t.Errorf("Unexpected preview %+v", result)
+	}
+
+	result = preview(url.Values{"channel": {"ntfy"}, "severity": {"warning"}, "title": {"{{.Result.Name"}})
+	if result.Error == "" || result.HTML {
+		t.Errorf("Expected a template error, got %+v", result)
+	}
+}